@@ -1,6 +1,10 @@
 package api
 
-import "net/http"
+import (
+	"net/http"
+
+	internalAPI "github.com/moznion/logglily/internal/api"
+)
 
 // Client is an interface that represents the API client of Loggly.
 type Client interface {
@@ -8,3 +12,22 @@ type Client interface {
 	LogAsBulk(body []byte) (*http.Response, error)
 	SetHTTPClient(client *http.Client)
 }
+
+// NewClient creates the API client that sends to the base URL instead of loggly, e.g.
+//
+//	l := logger.NewSyncLogger(tags, token, false)
+//	l.APIClient = api.NewClient(tags, token, "http://localhost:8080") // `logglily proxy`
+//
+// The base URL must serve the same routes as loggly: /inputs/{token}/tag/{tags}/ and /bulk/{token}/tag/{tags}/.
+func NewClient(tags []string, token string, baseURL string) Client {
+	return internalAPI.NewSimpleClientWithBaseURL(tags, token, baseURL)
+}
+
+// MarkRequest returns the request that is marked as the request that sends the logs.
+// The outbound logging (e.g. httplog.Transport) doesn't log the marked requests.
+//
+// Please mark the requests in the custom Client that sends the logs to somewhere other than loggly,
+// or the outbound logging logs its own logging recursively.
+func MarkRequest(req *http.Request) *http.Request {
+	return internalAPI.MarkInternalRequest(req)
+}
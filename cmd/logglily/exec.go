@@ -0,0 +1,222 @@
+package main
+
+import (
+	"errors"
+	"io"
+	"os"
+	"os/exec"
+	"os/signal"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/moznion/logglily/logger"
+)
+
+// runExec runs the command and ships its stdout and stderr line by line, e.g.
+//
+//	logglily exec -tag cron -- ./backup.sh --full
+//
+// Each line has `stream` ("stdout" or "stderr"), `pid` and `command` fields.
+// The signals that logglily receives are forwarded to the command, and the final event that has the exit code,
+// the duration and the resource usage is sent after the command exits.
+// This exits with the exit status of the command after flushing the buffered events.
+func runExec(c *cli, args []string) int {
+	fs := c.newFlagSet("logglily exec")
+	conn := c.connectionFlags(fs)
+	messageKey := fs.String("message-key", "message", "key to wrap the plain text lines under")
+	noJSON := fs.Bool("no-json", false, "don't parse JSON lines; send every line as plain text")
+	multiline := fs.Bool("multiline", false, "join the indented lines (e.g. stack traces) into the previous line")
+	flushInterval := fs.Int("flush-interval", defaultFlushIntervalMS, "interval to flush the buffer in milliseconds")
+	tee := fs.Bool("tee", false, "also write the output of the command into stdout and stderr of logglily")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if err := conn.validate(); err != nil {
+		c.errorf("%s", err)
+		return 2
+	}
+	if fs.NArg() == 0 {
+		c.errorf("no command is given; e.g. logglily exec -- command args...")
+		return 2
+	}
+
+	l, err := c.newAsyncBulkLogger(conn)
+	if err != nil {
+		c.errorf("%s", err)
+		return 1
+	}
+	tracker := &deliveryTracker{}
+	log := func(message logger.Message) {
+		tracker.sent()
+		result, err := l.LogBatch([]logger.Message{message})
+		if err != nil {
+			c.errorf("failed to log: %s", err)
+			tracker.fail(1)
+			return
+		}
+		tracker.track(result)
+	}
+	stopFlushing := startFlushing(l, tracker, *flushInterval)
+
+	command := strings.Join(fs.Args(), " ")
+	cmd := exec.Command(fs.Arg(0), fs.Args()[1:]...)
+	cmd.Stdin = c.stdin
+	streams, err := startCommand(cmd)
+	if err != nil {
+		status := startFailureStatus(err)
+		c.errorf("failed to run %s: %s", fs.Arg(0), err)
+		log(logger.Message{
+			"level":     "error",
+			"message":   "failed to run the command",
+			"command":   command,
+			"error":     err.Error(),
+			"exit_code": status,
+		})
+		stopFlushing()
+		tracker.track(l.Shutdown())
+		tracker.report(c)
+		return status
+	}
+	startedAt := time.Now()
+	stopForwarding := forwardSignals(cmd.Process, isTerminal(c.stdin))
+
+	var wg sync.WaitGroup
+	for _, s := range []struct {
+		name   string
+		reader io.Reader
+		tee    io.Writer
+	}{
+		{name: "stdout", reader: streams[0], tee: c.stdout},
+		{name: "stderr", reader: streams[1], tee: c.stderr},
+	} {
+		w := logger.NewWriter(logger.SinkFunc(func(message logger.Message) error {
+			log(message)
+			return nil
+		}))
+		w.SetMessageKey(*messageKey)
+		w.SetJSONParsing(!*noJSON)
+		w.SetFields(logger.Message{"stream": s.name, "pid": cmd.Process.Pid, "command": command})
+		if *multiline {
+			w.SetMultiline(logger.IndentedLines)
+		}
+
+		reader := s.reader
+		if *tee {
+			reader = io.TeeReader(reader, s.tee)
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			io.Copy(w, reader)
+			w.Close()
+		}()
+	}
+
+	// the pipes must be read completely before waiting the command
+	wg.Wait()
+	waitErr := cmd.Wait()
+	duration := time.Since(startedAt)
+	stopForwarding()
+
+	var exitErr *exec.ExitError
+	if waitErr != nil && !errors.As(waitErr, &exitErr) {
+		c.errorf("failed to wait %s: %s", fs.Arg(0), waitErr)
+	}
+
+	status := exitStatus(cmd.ProcessState)
+	final := logger.Message{
+		"level":          "info",
+		"message":        "the command exited",
+		"command":        command,
+		"pid":            cmd.Process.Pid,
+		"exit_code":      status,
+		"duration_ms":    float64(duration) / float64(time.Millisecond),
+		"user_time_ms":   float64(cmd.ProcessState.UserTime()) / float64(time.Millisecond),
+		"system_time_ms": float64(cmd.ProcessState.SystemTime()) / float64(time.Millisecond),
+	}
+	if status != 0 {
+		final["level"] = "error"
+	}
+	addResourceUsage(final, cmd.ProcessState)
+	log(final)
+
+	stopFlushing()
+	tracker.track(l.Shutdown())
+	tracker.report(c)
+	return status
+}
+
+// startCommand starts the command with the pipes of stdout and stderr.
+func startCommand(cmd *exec.Cmd) ([2]io.Reader, error) {
+	var streams [2]io.Reader
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return streams, err
+	}
+	stderr, err := cmd.StderrPipe()
+	if err != nil {
+		return streams, err
+	}
+	if err := cmd.Start(); err != nil {
+		return streams, err
+	}
+	streams[0], streams[1] = stdout, stderr
+	return streams, nil
+}
+
+// isTerminal reports whether the reader is the terminal.
+func isTerminal(r io.Reader) bool {
+	f, ok := r.(*os.File)
+	if !ok {
+		return false
+	}
+	info, err := f.Stat()
+	if err != nil || info.Mode()&os.ModeCharDevice == 0 {
+		return false
+	}
+	// the null device is the character device as well, e.g. when this runs from cron
+	null, err := os.Stat(os.DevNull)
+	return err != nil || !os.SameFile(info, null)
+}
+
+// startFailureStatus returns the exit status for the command that cannot be started, as like as shells.
+func startFailureStatus(err error) int {
+	if errors.Is(err, exec.ErrNotFound) || errors.Is(err, os.ErrNotExist) {
+		return 127
+	}
+	return 126
+}
+
+// forwardSignals forwards the signals to the process, and returns the function to stop that.
+//
+// If `interactive` is true, the terminal signals (e.g. Ctrl-C) are not forwarded,
+// because the terminal sends them to the process as well; or the process receives them twice.
+// Those are still caught, so that this command ships the rest of the output after the process exits.
+func forwardSignals(p *os.Process, interactive bool) func() {
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, forwardedSignals...)
+
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		for {
+			select {
+			case sig := <-sigCh:
+				if interactive && terminalSignals[sig] {
+					continue
+				}
+				forwardSignal(p, sig)
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	return func() {
+		signal.Stop(sigCh)
+		close(done)
+		<-stopped
+	}
+}
@@ -0,0 +1,54 @@
+//go:build !windows
+// +build !windows
+
+package main
+
+import (
+	"os"
+	"runtime"
+	"syscall"
+
+	"github.com/moznion/logglily/logger"
+)
+
+var forwardedSignals = []os.Signal{
+	syscall.SIGINT,
+	syscall.SIGTERM,
+	syscall.SIGHUP,
+	syscall.SIGQUIT,
+	syscall.SIGUSR1,
+	syscall.SIGUSR2,
+}
+
+// terminalSignals are the signals that the terminal sends to the whole foreground process group by Ctrl-C and Ctrl-\.
+var terminalSignals = map[os.Signal]bool{
+	syscall.SIGINT:  true,
+	syscall.SIGQUIT: true,
+}
+
+func forwardSignal(p *os.Process, sig os.Signal) {
+	p.Signal(sig)
+}
+
+// exitStatus returns the exit status of the process; that is 128+N if the process is killed by the signal N, as like as shells.
+func exitStatus(state *os.ProcessState) int {
+	if ws, ok := state.Sys().(syscall.WaitStatus); ok && ws.Signaled() {
+		return 128 + int(ws.Signal())
+	}
+	return state.ExitCode()
+}
+
+// addResourceUsage adds the signal that killed the process and the maximum resident set size in bytes.
+func addResourceUsage(message logger.Message, state *os.ProcessState) {
+	if ws, ok := state.Sys().(syscall.WaitStatus); ok && ws.Signaled() {
+		message["signal"] = ws.Signal().String()
+	}
+	if ru, ok := state.SysUsage().(*syscall.Rusage); ok {
+		maxRSS := int64(ru.Maxrss)
+		if runtime.GOOS != "darwin" && runtime.GOOS != "ios" {
+			// Maxrss is in kilobytes except on macOS
+			maxRSS *= 1024
+		}
+		message["max_rss_bytes"] = maxRSS
+	}
+}
@@ -0,0 +1,27 @@
+//go:build windows
+// +build windows
+
+package main
+
+import (
+	"os"
+
+	"github.com/moznion/logglily/logger"
+)
+
+var forwardedSignals = []os.Signal{os.Interrupt}
+
+// terminalSignals are the signals that the console sends to all processes attached to that by Ctrl-C.
+var terminalSignals = map[os.Signal]bool{os.Interrupt: true}
+
+// forwardSignal kills the process, because Windows cannot send the interrupt to the other process.
+func forwardSignal(p *os.Process, sig os.Signal) {
+	p.Kill()
+}
+
+func exitStatus(state *os.ProcessState) int {
+	return state.ExitCode()
+}
+
+func addResourceUsage(message logger.Message, state *os.ProcessState) {
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"text/tabwriter"
+
+	"github.com/moznion/logglily/retrieval"
+)
+
+// runFields prints the fields that loggly has indexed, or the top values of the field, e.g.
+//
+//	logglily fields -q 'tag:web' -from -1h              # the fields of the events of web
+//	logglily fields json.level -q 'tag:web' -from -1h   # the top values of json.level
+//
+// The flags can follow the field name.
+func runFields(c *cli, args []string) int {
+	fs := c.newFlagSet("logglily fields")
+	rc := c.retrievalFlags(fs)
+	query := fs.String("q", "*", "query to narrow down the events")
+	from := fs.String("from", "-24h", "start of the time range, e.g. -1h or 2018-01-05T17:11:25Z")
+	until := fs.String("until", "now", "end of the time range")
+	size := fs.Int("size", 0, "maximum number of the fields or the values (0 means the default of loggly)")
+	output := fs.String("output", "table", "output format; table or json")
+	positional, err := parseInterspersed(fs, args)
+	if err != nil {
+		return 2
+	}
+	if len(positional) > 1 {
+		c.errorf("only one field can be given")
+		return 2
+	}
+	if *output != "table" && *output != "json" {
+		c.errorf("unknown output format: %s", *output)
+		return 2
+	}
+	client, err := c.newRetrievalClient(rc)
+	if err != nil {
+		c.errorf("%s", err)
+		return 2
+	}
+
+	q := retrieval.FieldsQuery{Query: *query, From: *from, Until: *until, FacetSize: *size}
+	var result interface{}
+	var header string
+	var terms []retrieval.Term
+	var total int
+	if len(positional) == 0 {
+		fields, err := client.Fields(q)
+		if err != nil {
+			c.errorf("%s", err)
+			return 1
+		}
+		result, header, terms, total = fields, "FIELD", fields.Fields, fields.TotalEvents
+	} else {
+		values, err := client.FieldValues(positional[0], q)
+		if err != nil {
+			c.errorf("%s", err)
+			return 1
+		}
+		result, header, terms, total = values, "VALUE", values.Values, values.TotalEvents
+	}
+
+	if *output == "json" {
+		b, err := json.Marshal(result)
+		if err != nil {
+			c.errorf("%s", err)
+			return 1
+		}
+		fmt.Fprintf(c.stdout, "%s\n", b)
+		return 0
+	}
+
+	// the count is shown with the ratio to the events, so the fields that are missing in some events stand out
+	w := tabwriter.NewWriter(c.stdout, 0, 8, 2, ' ', 0)
+	fmt.Fprintf(w, "%s\tCOUNT\tRATIO\n", header)
+	for _, term := range terms {
+		ratio := 0.0
+		if total > 0 {
+			ratio = float64(term.Count) / float64(total) * 100
+		}
+		fmt.Fprintf(w, "%s\t%d\t%.1f%%\n", term.Term, term.Count, ratio)
+	}
+	w.Flush()
+	return 0
+}
@@ -0,0 +1,204 @@
+// logglily is the command-line tool to ship the logs into loggly.
+//
+// Usage:
+//
+//	some-command | logglily [flags]                 # send each line of stdin
+//	logglily send [flags] key=value ...             # send one event
+//	logglily tail [flags] pattern[:tags] ...        # follow the files
+//	logglily exec [flags] -- command args ...       # run the command and send its output
+//	logglily relay [flags]                          # relay the syslog messages
+//	logglily proxy [flags]                          # serve the local proxy of the loggly HTTP API
+//	logglily replay [flags] file ...                # resend the dead-letter files
+//	logglily search [flags] query                   # search the events
+//	logglily tail -q query [flags]                  # follow the events of the query
+//	logglily fields [flags] [field]                 # list the indexed fields or the values of the field
+//
+// The token and the tags can be given by the flags or LOGGLY_TOKEN and LOGGLY_TAG environment variables.
+// search, tail -q and fields read the events by the API token; that and the subdomain of the account can be given by
+// LOGGLY_API_TOKEN and LOGGLY_SUBDOMAIN.
+// Please run `logglily help` for the details.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/moznion/logglily/api"
+	"github.com/moznion/logglily/retrieval"
+)
+
+const (
+	tokenEnv     = "LOGGLY_TOKEN"
+	tagEnv       = "LOGGLY_TAG"
+	subdomainEnv = "LOGGLY_SUBDOMAIN"
+	apiTokenEnv  = "LOGGLY_API_TOKEN"
+)
+
+// subcommand is a subcommand of logglily; run returns the exit status.
+type subcommand struct {
+	summary string
+	run     func(c *cli, args []string) int
+}
+
+// subcommands are the subcommands except the default one (reading stdin).
+var subcommands = map[string]subcommand{
+	"exec":   {summary: "run the command and send its stdout and stderr", run: runExec},
+	"fields": {summary: "list the fields that loggly has indexed, or the top values of a field", run: runFields},
+	"proxy":  {summary: "serve the local proxy that re-batches the events of the loggly HTTP API", run: runProxy},
+	"relay":  {summary: "listen for the syslog messages and send them", run: runRelay},
+	"replay": {summary: "resend the records of the dead-letter files", run: runReplay},
+	"search": {summary: "search the events of loggly", run: runSearch},
+	"send":   {summary: "send one event that consists of key=value pairs", run: runSend},
+	"tail":   {summary: "follow the files and send the lines, or follow the events of the query (-q)", run: runTail},
+}
+
+// cli holds the environment of the command; that is replaced in the tests.
+type cli struct {
+	stdin  io.Reader
+	stdout io.Writer
+	stderr io.Writer
+	getenv func(key string) string
+
+	// apiClient replaces the API client of the loggers if it is not nil
+	apiClient api.Client
+	// retrievalBaseURL replaces the base URL of the retrieval API if it is not empty
+	retrievalBaseURL string
+}
+
+func main() {
+	c := &cli{
+		stdin:  os.Stdin,
+		stdout: os.Stdout,
+		stderr: os.Stderr,
+		getenv: os.Getenv,
+	}
+	os.Exit(c.run(os.Args[1:]))
+}
+
+func (c *cli) run(args []string) int {
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		name := args[0]
+		if name == "help" {
+			c.usage()
+			return 0
+		}
+		if cmd, ok := subcommands[name]; ok {
+			return cmd.run(c, args[1:])
+		}
+		fmt.Fprintf(c.stderr, "logglily: unknown subcommand %q\n\n", name)
+		c.usage()
+		return 2
+	}
+	return runPipe(c, args)
+}
+
+func (c *cli) usage() {
+	fmt.Fprintln(c.stderr, "Usage:")
+	fmt.Fprintln(c.stderr, "  logglily [flags]              read lines from stdin and send them")
+
+	names := make([]string, 0, len(subcommands))
+	for name := range subcommands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(c.stderr, "  logglily %-20s %s\n", name+" [flags]", subcommands[name].summary)
+	}
+	fmt.Fprintln(c.stderr, "\nPlease run `logglily <subcommand> -h` for the flags.")
+}
+
+func (c *cli) errorf(format string, args ...interface{}) {
+	fmt.Fprintf(c.stderr, "logglily: "+format+"\n", args...)
+}
+
+// newFlagSet creates the flag set that reports the errors into stderr.
+func (c *cli) newFlagSet(name string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(c.stderr)
+	return fs
+}
+
+// connection is the common settings to connect loggly.
+type connection struct {
+	token    string
+	tags     string
+	insecure bool
+}
+
+// connectionFlags defines the common flags; the defaults are taken from the environment variables.
+func (c *cli) connectionFlags(fs *flag.FlagSet) *connection {
+	conn := &connection{}
+	fs.StringVar(&conn.token, "token", c.getenv(tokenEnv), "customer token of loggly (env: "+tokenEnv+")")
+	fs.StringVar(&conn.tags, "tag", c.getenv(tagEnv), "comma-separated tags (env: "+tagEnv+")")
+	fs.BoolVar(&conn.insecure, "insecure", false, "use HTTP instead of HTTPS")
+	return conn
+}
+
+func (conn *connection) validate() error {
+	if conn.token == "" {
+		return fmt.Errorf("token is required; please give -token flag or %s environment variable", tokenEnv)
+	}
+	return nil
+}
+
+func (conn *connection) tagList() []string {
+	var tags []string
+	for _, tag := range strings.Split(conn.tags, ",") {
+		if tag = strings.TrimSpace(tag); tag != "" {
+			tags = append(tags, tag)
+		}
+	}
+	return tags
+}
+
+// retrievalConnection is the settings to call the retrieval API.
+type retrievalConnection struct {
+	subdomain string
+	apiToken  string
+}
+
+// retrievalFlags defines the flags of the retrieval API; the defaults are taken from the environment variables.
+func (c *cli) retrievalFlags(fs *flag.FlagSet) *retrievalConnection {
+	rc := &retrievalConnection{}
+	fs.StringVar(&rc.subdomain, "subdomain", c.getenv(subdomainEnv), "subdomain of the account, i.e. SUBDOMAIN.loggly.com (env: "+subdomainEnv+")")
+	fs.StringVar(&rc.apiToken, "api-token", c.getenv(apiTokenEnv), "API token to read the events (env: "+apiTokenEnv+")")
+	return rc
+}
+
+func (c *cli) newRetrievalClient(rc *retrievalConnection) (*retrieval.Client, error) {
+	if rc.apiToken == "" {
+		return nil, fmt.Errorf("API token is required; please give -api-token flag or %s environment variable", apiTokenEnv)
+	}
+	if c.retrievalBaseURL != "" {
+		return retrieval.NewClientWithBaseURL(c.retrievalBaseURL, rc.apiToken), nil
+	}
+	if rc.subdomain == "" {
+		return nil, fmt.Errorf("subdomain is required; please give -subdomain flag or %s environment variable", subdomainEnv)
+	}
+	return retrieval.NewClient(rc.subdomain, rc.apiToken), nil
+}
+
+// parseInterspersed parses the flags that can follow the positional arguments, e.g. `search 'query' -from -1h`,
+// and returns the positional arguments. The arguments after "--" are positional.
+func parseInterspersed(fs *flag.FlagSet, args []string) ([]string, error) {
+	var positional []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			return nil, err
+		}
+		consumed := len(args) - fs.NArg()
+		if consumed > 0 && args[consumed-1] == "--" {
+			return append(positional, fs.Args()...), nil
+		}
+		args = fs.Args()
+		if len(args) == 0 {
+			return positional, nil
+		}
+		positional = append(positional, args[0])
+		args = args[1:]
+	}
+}
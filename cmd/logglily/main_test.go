@@ -0,0 +1,435 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/moznion/logglily/internal/api"
+	"github.com/moznion/logglily/retrieval"
+	"github.com/moznion/logglily/retrieval/retrievaltest"
+	"github.com/moznion/logglily/tailer"
+)
+
+func newTestCLI(stdin string, env map[string]string) (*cli, *api.RecordingClient, *bytes.Buffer) {
+	client := &api.RecordingClient{}
+	stderr := &bytes.Buffer{}
+	return &cli{
+		stdin:     strings.NewReader(stdin),
+		stdout:    &bytes.Buffer{},
+		stderr:    stderr,
+		getenv:    func(key string) string { return env[key] },
+		apiClient: client,
+	}, client, stderr
+}
+
+func TestPipeShouldSendLinesOfStdin(t *testing.T) {
+	c, client, stderr := newTestCLI("plain line\n{\"n\":9007199254740993, \"message\":\"json\"}\n\ntrailing", map[string]string{tokenEnv: "token"})
+
+	if status := c.run([]string{"-message-key", "msg"}); status != 0 {
+		t.Fatalf("status == %d, stderr: %s", status, stderr)
+	}
+
+	if len(client.Bulks()) != 1 {
+		t.Fatalf("bulks == %q", client.Bulks())
+	}
+	got := strings.Split(client.Bulks()[0], "\n")
+	// the JSON object is sent as it is; the keys and the large integer are kept
+	expected := []string{`{"msg":"plain line"}`, `{"n":9007199254740993,"message":"json"}`, `{"msg":"trailing"}`}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("lines == %q but wants %q", got, expected)
+	}
+}
+
+func TestPipeShouldReportFailures(t *testing.T) {
+	c, client, stderr := newTestCLI("a\nb\nc\n", map[string]string{tokenEnv: "token"})
+	client.Status = http.StatusForbidden
+
+	if status := c.run(nil); status != 1 {
+		t.Errorf("status == %d but wants 1", status)
+	}
+	if !strings.Contains(stderr.String(), "3 of 3 events failed to be sent") {
+		t.Errorf("stderr == %s", stderr)
+	}
+}
+
+func TestPipeShouldRequireToken(t *testing.T) {
+	c, _, stderr := newTestCLI("a\n", nil)
+	if status := c.run(nil); status != 2 {
+		t.Errorf("status == %d but wants 2", status)
+	}
+	if !strings.Contains(stderr.String(), "token is required") {
+		t.Errorf("stderr == %s", stderr)
+	}
+}
+
+func TestSendShouldSendKeyValues(t *testing.T) {
+	c, client, stderr := newTestCLI("", map[string]string{tokenEnv: "token", tagEnv: "cron"})
+
+	status := c.run([]string{"send", "-tag", "cron,backup", "message=backup finished", "duration=42", "ok=true", `detail={"files":3}`, "id=9007199254740993"})
+	if status != 0 {
+		t.Fatalf("status == %d, stderr: %s", status, stderr)
+	}
+
+	if !strings.Contains(client.Events()[0], `"id":9007199254740993`) {
+		t.Errorf("the large integer should be kept: %s", client.Events()[0])
+	}
+	var got map[string]interface{}
+	json.Unmarshal([]byte(client.Events()[0]), &got)
+	expected := map[string]interface{}{
+		"message":  "backup finished",
+		"duration": float64(42),
+		"ok":       true,
+		"detail":   map[string]interface{}{"files": float64(3)},
+		"id":       float64(9007199254740993),
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("got == %v but wants %v", got, expected)
+	}
+}
+
+func TestSendShouldRejectMalformedArguments(t *testing.T) {
+	for _, args := range [][]string{{"send"}, {"send", "novalue"}, {"send", "=value"}} {
+		c, _, _ := newTestCLI("", map[string]string{tokenEnv: "token"})
+		if status := c.run(args); status != 2 {
+			t.Errorf("status == %d but wants 2 (args: %v)", status, args)
+		}
+	}
+}
+
+func TestConnectionShouldSplitTags(t *testing.T) {
+	conn := &connection{tags: " a, b,,c "}
+	if got := conn.tagList(); !reflect.DeepEqual(got, []string{"a", "b", "c"}) {
+		t.Errorf("tags == %v", got)
+	}
+}
+
+func TestUnknownSubcommandShouldFail(t *testing.T) {
+	c, _, stderr := newTestCLI("", nil)
+	if status := c.run([]string{"unknown"}); status != 2 {
+		t.Errorf("status == %d but wants 2", status)
+	}
+	if !strings.Contains(stderr.String(), "send [flags]") {
+		t.Errorf("usage should list subcommands: %s", stderr)
+	}
+}
+
+func TestTailShouldShipFilesOnce(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "logglily")
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "app.log")
+	ioutil.WriteFile(path, []byte("1\n2\n"), 0644)
+	checkpoint := filepath.Join(dir, "checkpoint.json")
+
+	c, client, stderr := newTestCLI("", map[string]string{tokenEnv: "token"})
+	if status := c.run([]string{"tail", "-once", "-checkpoint", checkpoint, path + ":app"}); status != 0 {
+		t.Fatalf("status == %d, stderr: %s", status, stderr)
+	}
+	expected := []string{`{"file":"` + path + `","message":"1"}` + "\n" + `{"file":"` + path + `","message":"2"}`}
+	if !reflect.DeepEqual(client.Bulks(), expected) {
+		t.Errorf("bulks == %q but wants %q", client.Bulks(), expected)
+	}
+
+	// resumes from the checkpoint
+	c, client, _ = newTestCLI("", map[string]string{tokenEnv: "token"})
+	if status := c.run([]string{"tail", "-once", "-checkpoint", checkpoint, path}); status != 0 {
+		t.Fatalf("status == %d", status)
+	}
+	if len(client.Bulks()) != 0 {
+		t.Errorf("bulks == %q but wants nothing", client.Bulks())
+	}
+}
+
+func TestTailShouldRequireFiles(t *testing.T) {
+	c, _, _ := newTestCLI("", map[string]string{tokenEnv: "token"})
+	if status := c.run([]string{"tail"}); status != 2 {
+		t.Errorf("status == %d but wants 2", status)
+	}
+}
+
+func TestParseFileArg(t *testing.T) {
+	for arg, expected := range map[string]tailer.FileConfig{
+		"/var/log/*.log":         {Pattern: "/var/log/*.log"},
+		"/var/log/*.log:app,web": {Pattern: "/var/log/*.log", Tags: []string{"app", "web"}},
+		`C:\logs\app.log`:        {Pattern: `C:\logs\app.log`},
+		`C:\logs\app.log:app`:    {Pattern: `C:\logs\app.log`, Tags: []string{"app"}},
+	} {
+		if got := parseFileArg(arg); !reflect.DeepEqual(got, expected) {
+			t.Errorf("parseFileArg(%q) == %#v but wants %#v", arg, got, expected)
+		}
+	}
+}
+
+func TestReplayShouldResendDeadLetters(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "logglily")
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "failed.ndjson")
+	ioutil.WriteFile(path, []byte(`{"version":1,"tags":["app"],"payload":{"n":1}}`+"\nbroken\n"), 0644)
+
+	c, client, stderr := newTestCLI("", map[string]string{tokenEnv: "token"})
+	if status := c.run([]string{"replay", "-rate", "0", path}); status != 0 {
+		t.Fatalf("status == %d, stderr: %s", status, stderr)
+	}
+	if expected := []string{`{"n":1}`}; !reflect.DeepEqual(client.Bulks(), expected) {
+		t.Errorf("bulks == %q but wants %q", client.Bulks(), expected)
+	}
+	if !strings.Contains(stderr.String(), "1 sent, 1 rejected") {
+		t.Errorf("unexpected summary: %s", stderr)
+	}
+	if b, err := ioutil.ReadFile(path + ".rejected"); err != nil || !strings.Contains(string(b), `"payload":"broken"`) {
+		t.Errorf("rejected file == %q, %v", b, err)
+	}
+}
+
+func TestSearchShouldPrintEvents(t *testing.T) {
+	s := retrievaltest.NewServer("api-token")
+	defer s.Close()
+	now := time.Now()
+	s.AddEvents(
+		retrievaltest.NewEvent(now.Add(-2*time.Hour), `{"level":"error","message":"old"}`, "web"),
+		retrievaltest.NewEvent(now.Add(-20*time.Minute), `{"level":"error","message":"first"}`, "web"),
+		retrievaltest.NewEvent(now.Add(-10*time.Minute), "plain\nerror", "web"),
+	)
+
+	c, _, stderr := newTestCLI("", map[string]string{apiTokenEnv: "api-token"})
+	c.retrievalBaseURL = s.URL
+	if status := c.run([]string{"search", "error", "--from", "-1h", "-order", "asc"}); status != 0 {
+		t.Fatalf("status == %d, stderr: %s", status, stderr)
+	}
+	var ids []string
+	for _, line := range strings.Split(strings.TrimSpace(c.stdout.(*bytes.Buffer).String()), "\n") {
+		var e retrieval.Event
+		if err := json.Unmarshal([]byte(line), &e); err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, e.ID)
+	}
+	if expected := []string{"event-2", "event-3"}; !reflect.DeepEqual(ids, expected) {
+		t.Errorf("ids == %q but wants %q", ids, expected)
+	}
+	if q := s.Queries()[0]; q.Query != "error" || q.From != "-1h" || q.Order != retrieval.OrderAsc {
+		t.Errorf("unexpected query: %+v", q)
+	}
+
+	c, _, stderr = newTestCLI("", map[string]string{apiTokenEnv: "api-token"})
+	c.retrievalBaseURL = s.URL
+	if status := c.run([]string{"search", "-output", "table", "-limit", "1", "tag:web"}); status != 0 {
+		t.Fatalf("status == %d, stderr: %s", status, stderr)
+	}
+	lines := strings.Split(strings.TrimSpace(c.stdout.(*bytes.Buffer).String()), "\n")
+	if len(lines) != 2 || !strings.HasPrefix(lines[0], "TIME") || !strings.HasSuffix(lines[1], "web   plain error") {
+		t.Errorf("unexpected table: %q", lines)
+	}
+	if !strings.Contains(stderr.String(), "1 of 3 events are printed") {
+		t.Errorf("unexpected stderr: %s", stderr)
+	}
+}
+
+func TestSearchShouldRequireAPIToken(t *testing.T) {
+	c, _, stderr := newTestCLI("", map[string]string{})
+	if status := c.run([]string{"search", "*"}); status != 2 || !strings.Contains(stderr.String(), apiTokenEnv) {
+		t.Errorf("status == %d, stderr: %s", status, stderr)
+	}
+}
+
+func TestQueryTailerShouldPrintNewEventsOnce(t *testing.T) {
+	s := retrievaltest.NewServer("api-token")
+	defer s.Close()
+	now := time.Now()
+	s.AddEvents(
+		retrievaltest.NewEvent(now.Add(-2*time.Minute), "too old"),
+		retrievaltest.NewEvent(now.Add(-30*time.Second), "first"),
+	)
+
+	stdout := &bytes.Buffer{}
+	printer, _ := newEventPrinter(stdout, "json")
+	tailer := newQueryTailer(s.Client(), "*", time.Minute, printer)
+	if n, err := tailer.poll(); n != 1 || err != nil {
+		t.Fatalf("first poll printed %d events: %v", n, err)
+	}
+
+	// the event that is indexed late is printed, and the printed one is not
+	s.AddEvents(retrievaltest.NewEvent(now.Add(-10*time.Second), "late"))
+	if n, err := tailer.poll(); n != 1 || err != nil {
+		t.Fatalf("second poll printed %d events: %v", n, err)
+	}
+	if n, err := tailer.poll(); n != 0 || err != nil {
+		t.Fatalf("third poll printed %d events: %v", n, err)
+	}
+
+	var messages []string
+	for _, line := range strings.Split(strings.TrimSpace(stdout.String()), "\n") {
+		var e retrieval.Event
+		json.Unmarshal([]byte(line), &e)
+		messages = append(messages, e.LogMsg)
+	}
+	if expected := []string{"first", "late"}; !reflect.DeepEqual(messages, expected) {
+		t.Errorf("messages == %q but wants %q", messages, expected)
+	}
+}
+
+func TestTailWithQueryShouldRejectFiles(t *testing.T) {
+	c, _, _ := newTestCLI("", map[string]string{apiTokenEnv: "api-token"})
+	if status := c.run([]string{"tail", "-q", "*", "app.log"}); status != 2 {
+		t.Errorf("status == %d but wants 2", status)
+	}
+}
+
+func TestParseInterspersed(t *testing.T) {
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	from := fs.String("from", "", "")
+	args, err := parseInterspersed(fs, []string{"tag:web", "--from", "-1h", "error", "--", "-literal"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *from != "-1h" || !reflect.DeepEqual(args, []string{"tag:web", "error", "-literal"}) {
+		t.Errorf("from == %q, args == %q", *from, args)
+	}
+}
+
+func TestFieldsShouldPrintFieldsAndValues(t *testing.T) {
+	s := retrievaltest.NewServer("api-token")
+	defer s.Close()
+	now := time.Now()
+	s.AddEvents(
+		retrievaltest.NewEvent(now.Add(-20*time.Minute), `{"level":"error","user":"alice"}`, "web"),
+		retrievaltest.NewEvent(now.Add(-10*time.Minute), `{"level":"error"}`, "web"),
+		retrievaltest.NewEvent(now.Add(-5*time.Minute), `{"level":"info"}`, "batch"),
+	)
+
+	c, _, stderr := newTestCLI("", map[string]string{apiTokenEnv: "api-token"})
+	c.retrievalBaseURL = s.URL
+	if status := c.run([]string{"fields", "-q", "tag:web", "-from", "-1h"}); status != 0 {
+		t.Fatalf("status == %d, stderr: %s", status, stderr)
+	}
+	expected := "FIELD       COUNT  RATIO\n" +
+		"json.level  2      100.0%\n" +
+		"logtype     2      100.0%\n" +
+		"tag         2      100.0%\n" +
+		"json.user   1      50.0%\n"
+	if got := c.stdout.(*bytes.Buffer).String(); got != expected {
+		t.Errorf("got\n%s\nbut wants\n%s", got, expected)
+	}
+
+	c, _, stderr = newTestCLI("", map[string]string{apiTokenEnv: "api-token"})
+	c.retrievalBaseURL = s.URL
+	if status := c.run([]string{"fields", "json.level", "-output", "json"}); status != 0 {
+		t.Fatalf("status == %d, stderr: %s", status, stderr)
+	}
+	expected = `{"json.level":[{"term":"error","count":2},{"term":"info","count":1}],"total_events":3,"unique_field_count":2}` + "\n"
+	if got := c.stdout.(*bytes.Buffer).String(); got != expected {
+		t.Errorf("got %s but wants %s", got, expected)
+	}
+}
+
+// TestHelperProcess is not a real test; that is the command that exec subcommand runs in the tests.
+func TestHelperProcess(t *testing.T) {
+	if os.Getenv("LOGGLILY_HELPER_PROCESS") != "1" {
+		return
+	}
+	fmt.Fprintln(os.Stdout, "out")
+	fmt.Fprintln(os.Stderr, `{"message":"err","n":1}`)
+	os.Exit(3)
+}
+
+func TestExecShouldShipOutputAndFinalEvent(t *testing.T) {
+	os.Setenv("LOGGLILY_HELPER_PROCESS", "1")
+	defer os.Unsetenv("LOGGLILY_HELPER_PROCESS")
+
+	c, client, stderr := newTestCLI("", map[string]string{tokenEnv: "token"})
+	status := c.run([]string{"exec", "--", os.Args[0], "-test.run=^TestHelperProcess$"})
+	if status != 3 {
+		t.Fatalf("status == %d but wants 3, stderr: %s", status, stderr)
+	}
+
+	var events []map[string]interface{}
+	for _, bulk := range client.Bulks() {
+		for _, line := range strings.Split(bulk, "\n") {
+			var event map[string]interface{}
+			if err := json.Unmarshal([]byte(line), &event); err != nil {
+				t.Fatal(err)
+			}
+			events = append(events, event)
+		}
+	}
+
+	streams := map[string]map[string]interface{}{}
+	var final map[string]interface{}
+	for _, event := range events {
+		if stream, ok := event["stream"].(string); ok {
+			streams[stream] = event
+		} else {
+			final = event
+		}
+	}
+	if streams["stdout"]["message"] != "out" || streams["stderr"]["message"] != "err" || streams["stderr"]["n"] != float64(1) {
+		t.Errorf("streams == %v", streams)
+	}
+	if streams["stdout"]["pid"] == nil || !strings.Contains(streams["stdout"]["command"].(string), "TestHelperProcess") {
+		t.Errorf("stdout event == %v", streams["stdout"])
+	}
+	if final == nil || final["exit_code"] != float64(3) || final["level"] != "error" || final["duration_ms"] == nil {
+		t.Errorf("final event == %v", final)
+	}
+	if rss, ok := final["max_rss_bytes"].(float64); runtime.GOOS != "windows" && (!ok || rss < 1024*1024) {
+		t.Errorf("max_rss_bytes should be in bytes: %v", final)
+	}
+}
+
+func TestIsTerminalShouldRejectNonTerminals(t *testing.T) {
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	defer w.Close()
+	null, err := os.Open(os.DevNull)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer null.Close()
+
+	for _, reader := range []io.Reader{strings.NewReader(""), r, null} {
+		if isTerminal(reader) {
+			t.Errorf("%T should not be the terminal", reader)
+		}
+	}
+}
+
+func TestExecShouldReportCommandNotFound(t *testing.T) {
+	c, client, _ := newTestCLI("", map[string]string{tokenEnv: "token"})
+	if status := c.run([]string{"exec", "--", "logglily-no-such-command"}); status != 127 {
+		t.Errorf("status == %d but wants 127", status)
+	}
+	if len(client.Bulks()) != 1 || !strings.Contains(client.Bulks()[0], `"exit_code":127`) {
+		t.Errorf("bulks == %q", client.Bulks())
+	}
+}
+
+func TestExecShouldRequireCommand(t *testing.T) {
+	c, _, _ := newTestCLI("", map[string]string{tokenEnv: "token"})
+	if status := c.run([]string{"exec"}); status != 2 {
+		t.Errorf("status == %d but wants 2", status)
+	}
+}
+
+func TestRelayShouldRequireAddress(t *testing.T) {
+	c, _, stderr := newTestCLI("", map[string]string{tokenEnv: "token"})
+	if status := c.run([]string{"relay"}); status != 2 {
+		t.Errorf("status == %d but wants 2", status)
+	}
+	if !strings.Contains(stderr.String(), "no address to listen on") {
+		t.Errorf("stderr == %s", stderr)
+	}
+}
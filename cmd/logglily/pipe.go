@@ -0,0 +1,188 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/moznion/logglily/logger"
+)
+
+const (
+	defaultBulkByteSize    = 5 * 1024 * 1024
+	defaultFlushIntervalMS = 1000
+)
+
+// runPipe reads the lines from stdin and sends them through AsyncBulkLogger.
+// JSON object lines are sent as they are (only compacted), and the other lines are wrapped under the message key.
+func runPipe(c *cli, args []string) int {
+	fs := c.newFlagSet("logglily")
+	conn := c.connectionFlags(fs)
+	messageKey := fs.String("message-key", "message", "key to wrap the plain text lines under")
+	noJSON := fs.Bool("no-json", false, "don't parse JSON lines; send every line as plain text")
+	multiline := fs.Bool("multiline", false, "join the indented lines (e.g. stack traces) into the previous line")
+	flushInterval := fs.Int("flush-interval", defaultFlushIntervalMS, "interval to flush the buffer in milliseconds")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if err := conn.validate(); err != nil {
+		c.errorf("%s", err)
+		return 2
+	}
+
+	l, err := c.newAsyncBulkLogger(conn)
+	if err != nil {
+		c.errorf("%s", err)
+		return 1
+	}
+
+	tracker := &deliveryTracker{}
+	w := logger.NewWriter(logger.SinkFunc(func(message logger.Message) error {
+		tracker.sent()
+		// the events are buffered on this goroutine so that they keep the order of the lines
+		var result *logger.AsyncBulkResult
+		var err error
+		line, _ := message[*messageKey].(string)
+		if raw := rawJSONObject(line); raw != nil && !*noJSON {
+			result, err = l.LogRawBatch([][]byte{raw})
+		} else {
+			result, err = l.LogBatch([]logger.Message{message})
+		}
+		if err != nil {
+			c.errorf("failed to log: %s", err)
+			tracker.fail(1)
+			return nil
+		}
+		tracker.track(result)
+		return nil
+	}))
+	w.SetMessageKey(*messageKey)
+	// the JSON lines are detected by rawJSONObject() instead of Writer
+	w.SetJSONParsing(false)
+	if *multiline {
+		w.SetMultiline(logger.IndentedLines)
+	}
+
+	stopFlushing := startFlushing(l, tracker, *flushInterval)
+	_, readErr := io.Copy(w, c.stdin)
+	stopFlushing()
+
+	w.Close()
+	tracker.track(l.Shutdown())
+
+	if readErr != nil {
+		c.errorf("failed to read stdin: %s", readErr)
+	}
+	return tracker.report(c)
+}
+
+// rawJSONObject returns the compacted line if that is a JSON object, or nil.
+// The object is sent as it is, because decoding that into Message rounds the large integers and loses the order of the keys.
+func rawJSONObject(line string) []byte {
+	trimmed := []byte(strings.TrimSpace(line))
+	if len(trimmed) == 0 || trimmed[0] != '{' || !json.Valid(trimmed) {
+		return nil
+	}
+	buf := &bytes.Buffer{}
+	if err := json.Compact(buf, trimmed); err != nil {
+		return nil
+	}
+	return buf.Bytes()
+}
+
+// startFlushing flushes the logger periodically, and returns the function to stop that.
+// The periodic flushing of the logger itself is disabled, because that discards the results.
+func startFlushing(l *logger.AsyncBulkLogger, tracker *deliveryTracker, intervalMillis int) func() {
+	if intervalMillis <= 0 {
+		return func() {}
+	}
+
+	stop := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		ticker := time.NewTicker(time.Duration(intervalMillis) * time.Millisecond)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				tracker.track(l.Flush())
+			case <-stop:
+				return
+			}
+		}
+	}()
+
+	return func() {
+		close(stop)
+		<-stopped
+	}
+}
+
+func (c *cli) newAsyncBulkLogger(conn *connection) (*logger.AsyncBulkLogger, error) {
+	l, err := logger.NewAsyncBulkLogger(conn.tagList(), conn.token, !conn.insecure, defaultBulkByteSize, 0)
+	if err != nil {
+		return nil, err
+	}
+	if c.apiClient != nil {
+		l.APIClient = c.apiClient
+	}
+	return l, nil
+}
+
+// deliveryTracker counts the events and the failures that are notified through the results asynchronously.
+type deliveryTracker struct {
+	mu       sync.Mutex
+	wg       sync.WaitGroup
+	total    int
+	failed   int
+	firstErr error
+}
+
+func (t *deliveryTracker) sent() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.total++
+}
+
+func (t *deliveryTracker) fail(n int) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.failed += n
+}
+
+func (t *deliveryTracker) track(result *logger.AsyncBulkResult) {
+	t.wg.Add(1)
+	go func() {
+		defer t.wg.Done()
+		err := <-result.AsyncErrChan
+		failed := <-result.FailedMessagesChan
+
+		t.mu.Lock()
+		defer t.mu.Unlock()
+		t.failed += len(failed)
+		if err != nil && t.firstErr == nil {
+			t.firstErr = err
+		}
+	}()
+}
+
+// report waits for all results, and reports the failures. This returns the exit status.
+func (t *deliveryTracker) report(c *cli) int {
+	t.wg.Wait()
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.failed == 0 {
+		return 0
+	}
+	if t.firstErr != nil {
+		c.errorf("%d of %d events failed to be sent: %s", t.failed, t.total, t.firstErr)
+	} else {
+		c.errorf("%d of %d events failed to be sent", t.failed, t.total)
+	}
+	return 1
+}
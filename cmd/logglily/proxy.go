@@ -0,0 +1,73 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/moznion/logglily/api"
+	"github.com/moznion/logglily/proxy"
+)
+
+// runProxy serves the local proxy that is compatible with the loggly HTTP API until it is interrupted, e.g.
+//
+//	logglily proxy -listen 127.0.0.1:8080
+//
+// The clients send the events to http://127.0.0.1:8080/inputs/{token}/tag/{tags}/ instead of loggly.
+// Please see proxy package for the details.
+func runProxy(c *cli, args []string) int {
+	fs := c.newFlagSet("logglily proxy")
+	listen := fs.String("listen", "127.0.0.1:8080", "address to listen on")
+	insecure := fs.Bool("insecure", false, "use HTTP instead of HTTPS to send to loggly")
+	flushInterval := fs.Int("flush-interval", defaultFlushIntervalMS, "interval to flush the buffers in milliseconds")
+	idleTimeout := fs.Duration("idle-timeout", 5*time.Minute, "duration to keep the logger of the token and the tags that receives nothing")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	p := proxy.NewProxy(!*insecure)
+	p.SetIdleTimeout(*idleTimeout)
+	var errMu sync.Mutex
+	p.SetErrorHandler(func(err error) {
+		errMu.Lock()
+		defer errMu.Unlock()
+		c.errorf("%s", err)
+	})
+	if c.apiClient != nil {
+		p.SetAPIClient(func(token string, tags []string) api.Client {
+			return c.apiClient
+		})
+	}
+
+	srv := &http.Server{Addr: *listen, Handler: p}
+	failed := make(chan error, 1)
+	go func() {
+		if err := srv.ListenAndServe(); err != http.ErrServerClosed {
+			failed <- err
+		}
+	}()
+	stopFlushing := startTicker(*flushInterval, p.Flush)
+
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(sigCh)
+
+	status := 0
+	select {
+	case <-sigCh:
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		srv.Shutdown(ctx)
+		cancel()
+	case err := <-failed:
+		c.errorf("%s", err)
+		status = 1
+	}
+
+	stopFlushing()
+	p.Close()
+	return status
+}
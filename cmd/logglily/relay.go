@@ -0,0 +1,117 @@
+package main
+
+import (
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/moznion/logglily/relay"
+)
+
+// runRelay listens for the syslog messages and forwards them until it is interrupted, e.g.
+//
+//	logglily relay -udp :514 -tcp :514 -unix /var/run/logglily.sock
+//
+// Please see relay package for the formats and the backpressure.
+func runRelay(c *cli, args []string) int {
+	fs := c.newFlagSet("logglily relay")
+	conn := c.connectionFlags(fs)
+	udpAddr := fs.String("udp", "", "UDP address to listen on, e.g. :514")
+	tcpAddr := fs.String("tcp", "", "TCP address to listen on, e.g. :514")
+	unixPath := fs.String("unix", "", "path of the Unix datagram socket to listen on (like /dev/log)")
+	unixStreamPath := fs.String("unix-stream", "", "path of the Unix stream socket to listen on")
+	queueSize := fs.Int("queue-size", 10000, "number of the messages that can be queued before forwarding")
+	flushInterval := fs.Int("flush-interval", defaultFlushIntervalMS, "interval to flush the buffer in milliseconds")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if err := conn.validate(); err != nil {
+		c.errorf("%s", err)
+		return 2
+	}
+
+	var listeners [][2]string
+	for _, l := range [][2]string{{"udp", *udpAddr}, {"tcp", *tcpAddr}, {"unixgram", *unixPath}, {"unix", *unixStreamPath}} {
+		if l[1] != "" {
+			listeners = append(listeners, l)
+		}
+	}
+	if len(listeners) == 0 {
+		c.errorf("no address to listen on is given; please give -udp, -tcp, -unix or -unix-stream")
+		return 2
+	}
+
+	l, err := c.newAsyncBulkLogger(conn)
+	if err != nil {
+		c.errorf("%s", err)
+		return 1
+	}
+	r := relay.NewRelay(l, *queueSize)
+	var errMu sync.Mutex
+	r.SetErrorHandler(func(err error) {
+		errMu.Lock()
+		defer errMu.Unlock()
+		c.errorf("%s", err)
+	})
+	// the periodic flushing of the logger itself is disabled because that discards the errors
+	stopFlushing := startTicker(*flushInterval, r.Flush)
+
+	failed := make(chan error, len(listeners))
+	for _, listener := range listeners {
+		network, address := listener[0], listener[1]
+		go func() {
+			if err := r.ListenAndServe(network, address); err != relay.ErrClosed {
+				failed <- err
+			}
+		}()
+	}
+
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(sigCh)
+
+	status := 0
+	select {
+	case <-sigCh:
+	case err := <-failed:
+		c.errorf("%s", err)
+		status = 1
+	}
+
+	stopFlushing()
+	r.Close()
+	if dropped := r.Dropped(); dropped > 0 {
+		c.errorf("%d datagrams were dropped because the queue was full", dropped)
+	}
+	return status
+}
+
+// startTicker calls the function periodically, and returns the function to stop that.
+func startTicker(intervalMillis int, f func()) func() {
+	if intervalMillis <= 0 {
+		return func() {}
+	}
+
+	stop := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		ticker := time.NewTicker(time.Duration(intervalMillis) * time.Millisecond)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				f()
+			case <-stop:
+				return
+			}
+		}
+	}()
+
+	return func() {
+		close(stop)
+		<-stopped
+	}
+}
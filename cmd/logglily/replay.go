@@ -0,0 +1,82 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/moznion/logglily/api"
+	"github.com/moznion/logglily/deadletter"
+)
+
+// runReplay resends the records of the dead-letter files, e.g.
+//
+//	logglily replay -rate 500 /var/spool/app/failed.ndjson
+//
+// The progress of FILE is saved into FILE.progress, and the rejected records are appended into FILE.rejected by default;
+// the replay resumes from the progress when it is run again. -tag is used for the records that have no tags.
+// Please see deadletter package for the format of the file.
+func runReplay(c *cli, args []string) int {
+	fs := c.newFlagSet("logglily replay")
+	conn := c.connectionFlags(fs)
+	rate := fs.Float64("rate", 100, "maximum number of the messages to send per second (0 means unlimited)")
+	batchSize := fs.Int("batch-size", 1000, "maximum number of the messages in a bulk request")
+	maxRetries := fs.Int("max-retries", 5, "number of the retries for the temporary failures; 0 disables the retries")
+	rejected := fs.String("rejected", "", "path of the file to append the rejected records into (default FILE.rejected)")
+	progress := fs.String("progress", "", "path of the progress file (default FILE.progress)")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if err := conn.validate(); err != nil {
+		c.errorf("%s", err)
+		return 2
+	}
+	if fs.NArg() == 0 {
+		c.errorf("no file is given")
+		return 2
+	}
+	if fs.NArg() > 1 && (*rejected != "" || *progress != "") {
+		c.errorf("-rejected and -progress cannot be given for the multiple files")
+		return 2
+	}
+
+	config := deadletter.ReplayConfig{
+		Token:       conn.token,
+		Insecure:    conn.insecure,
+		DefaultTags: conn.tagList(),
+		Rate:        *rate,
+		BatchSize:   *batchSize,
+		MaxRetries:  *maxRetries,
+		NoRetry:     *maxRetries <= 0,
+	}
+	if c.apiClient != nil {
+		config.APIClient = func(tags []string) api.Client {
+			return c.apiClient
+		}
+	}
+	replayer, err := deadletter.NewReplayer(config)
+	if err != nil {
+		c.errorf("%s", err)
+		return 2
+	}
+
+	status := 0
+	for _, path := range fs.Args() {
+		rejectedPath, progressPath := path+".rejected", path+".progress"
+		if *rejected != "" {
+			rejectedPath = *rejected
+		}
+		if *progress != "" {
+			progressPath = *progress
+		}
+
+		stats, err := replayer.Replay(path, progressPath, rejectedPath)
+		fmt.Fprintf(c.stderr, "logglily: %s: %d sent, %d rejected\n", path, stats.Sent, stats.Rejected)
+		if stats.Rejected > 0 {
+			c.errorf("the rejected records are in %s", rejectedPath)
+		}
+		if err != nil {
+			c.errorf("failed to replay %s: %s", path, err)
+			status = 1
+		}
+	}
+	return status
+}
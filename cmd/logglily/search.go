@@ -0,0 +1,184 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/moznion/logglily/retrieval"
+)
+
+// runSearch searches the events and prints them, e.g.
+//
+//	logglily search 'tag:web AND json.level:error' -from -1h -output table
+//
+// The flags can follow the query. The events are printed as JSON lines by default; that is the same as the events
+// of the retrieval API, so that is easy to process with jq.
+func runSearch(c *cli, args []string) int {
+	fs := c.newFlagSet("logglily search")
+	rc := c.retrievalFlags(fs)
+	from := fs.String("from", "-24h", "start of the time range, e.g. -1h or 2018-01-05T17:11:25Z")
+	until := fs.String("until", "now", "end of the time range")
+	order := fs.String("order", "desc", "order of the events; desc or asc")
+	size := fs.Int("size", 50, "number of the events per page")
+	limit := fs.Int("limit", 1000, "maximum number of the events to print (0 means all)")
+	output := fs.String("output", "json", "output format; json or table")
+	positional, err := parseInterspersed(fs, args)
+	if err != nil {
+		return 2
+	}
+	if len(positional) == 0 {
+		c.errorf("no query is given; e.g. logglily search 'tag:web' -from -1h")
+		return 2
+	}
+	if *order != string(retrieval.OrderDesc) && *order != string(retrieval.OrderAsc) {
+		c.errorf("unknown order: %s", *order)
+		return 2
+	}
+	printer, err := newEventPrinter(c.stdout, *output)
+	if err != nil {
+		c.errorf("%s", err)
+		return 2
+	}
+	client, err := c.newRetrievalClient(rc)
+	if err != nil {
+		c.errorf("%s", err)
+		return 2
+	}
+
+	it := client.Iterate(retrieval.Query{
+		Query: strings.Join(positional, " "),
+		From:  *from,
+		Until: *until,
+		Order: retrieval.Order(*order),
+		Size:  *size,
+	})
+	printed := 0
+	for (*limit <= 0 || printed < *limit) && it.Next() {
+		if err := printer.print(it.Event()); err != nil {
+			c.errorf("%s", err)
+			return 1
+		}
+		printed++
+	}
+	printer.flush()
+	if err := it.Err(); err != nil {
+		c.errorf("%s", err)
+		return 1
+	}
+	if printed < it.Total() {
+		c.errorf("%d of %d events are printed", printed, it.Total())
+	}
+	return 0
+}
+
+// eventPrinter prints the events as JSON lines or the table.
+type eventPrinter struct {
+	w      io.Writer
+	table  *tabwriter.Writer
+	header bool
+}
+
+func newEventPrinter(w io.Writer, format string) (*eventPrinter, error) {
+	switch format {
+	case "json":
+		return &eventPrinter{w: w}, nil
+	case "table":
+		return &eventPrinter{w: w, table: tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)}, nil
+	default:
+		return nil, fmt.Errorf("unknown output format: %s", format)
+	}
+}
+
+func (p *eventPrinter) print(e retrieval.Event) error {
+	if p.table == nil {
+		b, err := json.Marshal(e)
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintf(p.w, "%s\n", b)
+		return err
+	}
+
+	if !p.header {
+		fmt.Fprintln(p.table, "TIME\tTAGS\tMESSAGE")
+		p.header = true
+	}
+	message := strings.Join(strings.Fields(e.LogMsg), " ")
+	_, err := fmt.Fprintf(p.table, "%s\t%s\t%s\n", e.Time().Format("2006-01-02T15:04:05.000Z07:00"), strings.Join(e.Tags, ","), message)
+	return err
+}
+
+// flush writes the buffered rows of the table.
+func (p *eventPrinter) flush() {
+	if p.table != nil {
+		p.table.Flush()
+	}
+}
+
+// queryTailer polls the events of the query, and prints the new ones.
+//
+// The events are indexed with a delay; so each poll searches from the newest event that has been seen
+// minus the lag, and the events that have been printed are skipped by the ID.
+type queryTailer struct {
+	client  *retrieval.Client
+	query   string
+	lag     time.Duration
+	printer *eventPrinter
+	now     func() time.Time
+
+	since time.Time
+	seen  map[string]time.Time
+}
+
+func newQueryTailer(client *retrieval.Client, query string, lag time.Duration, printer *eventPrinter) *queryTailer {
+	t := &queryTailer{
+		client:  client,
+		query:   query,
+		lag:     lag,
+		printer: printer,
+		now:     time.Now,
+		seen:    make(map[string]time.Time),
+	}
+	t.since = t.now()
+	return t
+}
+
+// poll prints the new events, and returns the number of them.
+func (t *queryTailer) poll() (int, error) {
+	from := t.since.Add(-t.lag)
+	it := t.client.Iterate(retrieval.Query{
+		Query: t.query,
+		From:  retrieval.FormatTime(from),
+		Until: "now",
+		Order: retrieval.OrderAsc,
+		Size:  1000,
+	})
+	printed := 0
+	for it.Next() {
+		e := it.Event()
+		if _, ok := t.seen[e.ID]; ok {
+			continue
+		}
+		if err := t.printer.print(e); err != nil {
+			return printed, err
+		}
+		printed++
+		t.seen[e.ID] = e.Time()
+		if e.Time().After(t.since) {
+			t.since = e.Time()
+		}
+	}
+	t.printer.flush()
+
+	// the events before the window never come again
+	for id, ts := range t.seen {
+		if ts.Before(from) {
+			delete(t.seen, id)
+		}
+	}
+	return printed, it.Err()
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/moznion/logglily/logger"
+)
+
+// runSend sends one event that consists of key=value pairs through the event API synchronously, e.g.
+//
+//	logglily send message="backup finished" duration=42 ok=true
+//
+// The values that are valid JSON (numbers, booleans, null, objects, arrays and quoted strings) are decoded; the others are strings.
+func runSend(c *cli, args []string) int {
+	fs := c.newFlagSet("logglily send")
+	conn := c.connectionFlags(fs)
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if err := conn.validate(); err != nil {
+		c.errorf("%s", err)
+		return 2
+	}
+
+	message, err := parseKeyValues(fs.Args())
+	if err != nil {
+		c.errorf("%s", err)
+		return 2
+	}
+
+	l := logger.NewSyncLogger(conn.tagList(), conn.token, !conn.insecure)
+	if c.apiClient != nil {
+		l.APIClient = c.apiClient
+	}
+	if err := l.Log(message); err != nil {
+		c.errorf("1 of 1 events failed to be sent: %s", err)
+		return 1
+	}
+	return 0
+}
+
+func parseKeyValues(args []string) (logger.Message, error) {
+	if len(args) == 0 {
+		return nil, fmt.Errorf("no key=value is given")
+	}
+
+	message := make(logger.Message, len(args))
+	for _, arg := range args {
+		i := strings.Index(arg, "=")
+		if i <= 0 {
+			return nil, fmt.Errorf("malformed key=value [given: %s]", arg)
+		}
+		message[arg[:i]] = parseValue(arg[i+1:])
+	}
+	return message, nil
+}
+
+// parseValue parses the value as JSON, or returns that as the string. The numbers are kept as json.Number not to lose the precision.
+func parseValue(s string) interface{} {
+	decoder := json.NewDecoder(strings.NewReader(s))
+	decoder.UseNumber()
+	var v interface{}
+	if err := decoder.Decode(&v); err != nil {
+		return s
+	}
+	if _, err := decoder.Token(); err != io.EOF {
+		return s
+	}
+	return v
+}
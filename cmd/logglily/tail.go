@@ -0,0 +1,170 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/moznion/logglily/api"
+	"github.com/moznion/logglily/tailer"
+)
+
+const (
+	defaultPollIntervalMS      = 1000
+	defaultQueryPollIntervalMS = 5000
+)
+
+// runTail follows the files and ships the lines until it is interrupted, e.g.
+//
+//	logglily tail -checkpoint /var/lib/logglily/checkpoint.json /var/log/app/*.log:app /var/log/nginx/access.log:nginx,access
+//
+// Each argument is the path or the glob pattern of the files, and that can have the comma-separated tags after the colon.
+// Those tags are added to -tag flag (or LOGGLY_TAG).
+//
+// With -q, this follows the events of the query on loggly instead of the files, e.g.
+//
+//	logglily tail -q 'tag:web AND json.level:error' -output table
+func runTail(c *cli, args []string) int {
+	fs := c.newFlagSet("logglily tail")
+	conn := c.connectionFlags(fs)
+	checkpoint := fs.String("checkpoint", "", "path of the checkpoint file to persist the read offsets")
+	pollInterval := fs.Int("poll-interval", defaultPollIntervalMS, "interval to check the files in milliseconds")
+	fromEnd := fs.Bool("from-end", false, "skip the existing contents of the files that are not in the checkpoint")
+	messageKey := fs.String("message-key", "message", "key to wrap the plain text lines under")
+	once := fs.Bool("once", false, "ship the lines that have been written (or print the events of -q once), and exit")
+	rc := c.retrievalFlags(fs)
+	query := fs.String("q", "", "follow the events of the query instead of the files")
+	lag := fs.Int("lag", 60000, "with -q, how far back to search for the events that are indexed late in milliseconds")
+	output := fs.String("output", "json", "with -q, output format; json or table")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if *query != "" {
+		if fs.NArg() > 0 {
+			c.errorf("the files cannot be given with -q")
+			return 2
+		}
+		interval := defaultQueryPollIntervalMS
+		fs.Visit(func(f *flag.Flag) {
+			if f.Name == "poll-interval" {
+				interval = *pollInterval
+			}
+		})
+		return runQueryTail(c, rc, *query, time.Duration(*lag)*time.Millisecond, time.Duration(interval)*time.Millisecond, *output, *once)
+	}
+	if err := conn.validate(); err != nil {
+		c.errorf("%s", err)
+		return 2
+	}
+	if fs.NArg() == 0 {
+		c.errorf("no file is given")
+		return 2
+	}
+
+	files := make([]tailer.FileConfig, 0, fs.NArg())
+	for _, arg := range fs.Args() {
+		files = append(files, parseFileArg(arg))
+	}
+
+	config := tailer.Config{
+		Files:          files,
+		Token:          conn.token,
+		Tags:           conn.tagList(),
+		Insecure:       conn.insecure,
+		CheckpointPath: *checkpoint,
+		PollInterval:   time.Duration(*pollInterval) * time.Millisecond,
+		StartAtEnd:     *fromEnd,
+		MessageKey:     *messageKey,
+		OnError: func(err error) {
+			c.errorf("%s", err)
+		},
+	}
+	if c.apiClient != nil {
+		config.APIClient = func(tags []string) api.Client {
+			return c.apiClient
+		}
+	}
+
+	t, err := tailer.New(config)
+	if err != nil {
+		c.errorf("%s", err)
+		return 2
+	}
+
+	if *once {
+		defer t.Close()
+		err = t.Poll()
+	} else {
+		ctx, cancel := context.WithCancel(context.Background())
+		sigCh := make(chan os.Signal, 1)
+		signal.Notify(sigCh, os.Interrupt, syscall.SIGTERM)
+		go func() {
+			<-sigCh
+			cancel()
+		}()
+		err = t.Run(ctx)
+		signal.Stop(sigCh)
+		cancel()
+	}
+	if err != nil {
+		c.errorf("%s", err)
+		return 1
+	}
+	return 0
+}
+
+// runQueryTail polls the events of the query, and prints the new ones until it is interrupted.
+func runQueryTail(c *cli, rc *retrievalConnection, query string, lag time.Duration, interval time.Duration, output string, once bool) int {
+	printer, err := newEventPrinter(c.stdout, output)
+	if err != nil {
+		c.errorf("%s", err)
+		return 2
+	}
+	client, err := c.newRetrievalClient(rc)
+	if err != nil {
+		c.errorf("%s", err)
+		return 2
+	}
+
+	t := newQueryTailer(client, query, lag, printer)
+	if once {
+		if _, err := t.poll(); err != nil {
+			c.errorf("%s", err)
+			return 1
+		}
+		return 0
+	}
+
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(sigCh)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		// the temporary errors (e.g. the rate limit of the API) don't stop the tailing
+		if _, err := t.poll(); err != nil {
+			c.errorf("%s", err)
+		}
+		select {
+		case <-ticker.C:
+		case <-sigCh:
+			return 0
+		}
+	}
+}
+
+// parseFileArg parses "pattern[:tag1,tag2]". The colon is taken as the separator
+// only if the part after that doesn't look like the path, e.g. "C:\logs\app.log" has no tags.
+func parseFileArg(arg string) tailer.FileConfig {
+	i := strings.LastIndex(arg, ":")
+	if i <= 0 || strings.ContainsAny(arg[i+1:], `/\`) {
+		return tailer.FileConfig{Pattern: arg}
+	}
+
+	conn := &connection{tags: arg[i+1:]}
+	return tailer.FileConfig{Pattern: arg[:i], Tags: conn.tagList()}
+}
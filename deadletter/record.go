@@ -0,0 +1,235 @@
+// Package deadletter defines the file format of the messages that failed to be sent (dead letters),
+// and provides the writer, the reader and the replayer of that.
+//
+// The file is NDJSON; each line is a Record, e.g.
+//
+//	{"version":1,"failed_at":"2018-01-05T17:11:25.494Z","tags":["web"],"error":"failed to call log API [status=503, msg=]","payload":{"message":"hello"}}
+//
+// The payload is the message as it was sent. If that is not JSON, that is stored as the JSON string with "encoding":"text".
+// Please write FailedMessages of SyncBulkResult and AsyncBulkResult with Writer, and resend them by `logglily replay`.
+package deadletter
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/moznion/logglily/logger"
+)
+
+// Version is the version of the format.
+const Version = 1
+
+// EncodingText is the encoding of the payload that is not JSON.
+const EncodingText = "text"
+
+// maxLineSize is the limit of a line; that is large enough for the message of the bulk API (up to 1MB) with the metadata.
+const maxLineSize = 8 * 1024 * 1024
+
+// Record is a line of the dead-letter file.
+type Record struct {
+	Version  int             `json:"version"`
+	FailedAt time.Time       `json:"failed_at"`
+	Tags     []string        `json:"tags,omitempty"`
+	Error    string          `json:"error,omitempty"`
+	Encoding string          `json:"encoding,omitempty"`
+	Payload  json.RawMessage `json:"payload"`
+}
+
+// NewRecord creates a new Record of the message.
+func NewRecord(message []byte, tags []string, cause error, failedAt time.Time) Record {
+	r := Record{
+		Version:  Version,
+		FailedAt: failedAt,
+		Tags:     tags,
+	}
+	if cause != nil {
+		r.Error = cause.Error()
+	}
+
+	message = bytes.TrimSpace(message)
+	if json.Valid(message) {
+		buf := &bytes.Buffer{}
+		if err := json.Compact(buf, message); err == nil {
+			r.Payload = buf.Bytes()
+			return r
+		}
+	}
+	r.Encoding = EncodingText
+	r.Payload, _ = json.Marshal(string(message))
+	return r
+}
+
+// Message returns the message as it was sent.
+func (r Record) Message() ([]byte, error) {
+	if r.Encoding == EncodingText {
+		var text string
+		if err := json.Unmarshal(r.Payload, &text); err != nil {
+			return nil, err
+		}
+		return []byte(text), nil
+	}
+	return r.Payload, nil
+}
+
+func (r Record) validate() error {
+	if r.Version != Version {
+		return fmt.Errorf("unsupported version [given: %d]", r.Version)
+	}
+	if len(r.Payload) == 0 {
+		return fmt.Errorf("payload is missing")
+	}
+	if r.Encoding != "" && r.Encoding != EncodingText {
+		return fmt.Errorf("unsupported encoding [given: %s]", r.Encoding)
+	}
+	return nil
+}
+
+// Writer writes the records. This is safe for the concurrent use.
+type Writer struct {
+	mu   sync.Mutex
+	w    io.Writer
+	tags []string
+	now  func() time.Time
+}
+
+// NewWriter creates a new Writer. The tags are the tags of the logger that failed to send the messages.
+// Please open the file with os.O_APPEND to keep the records that have been written.
+func NewWriter(w io.Writer, tags []string) *Writer {
+	return &Writer{
+		w:    w,
+		tags: tags,
+		now:  time.Now,
+	}
+}
+
+// Write writes the messages as the records; each message is a line.
+func (w *Writer) Write(messages [][]byte, cause error) error {
+	if len(messages) == 0 {
+		return nil
+	}
+
+	now := w.now()
+	buf := &bytes.Buffer{}
+	encoder := json.NewEncoder(buf)
+	encoder.SetEscapeHTML(false)
+	for _, message := range messages {
+		if err := encoder.Encode(NewRecord(message, w.tags, cause, now)); err != nil {
+			return err
+		}
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	_, err := w.w.Write(buf.Bytes())
+	return err
+}
+
+// WriteSyncBulkResult writes the failed messages of the result of SyncBulkLogger, e.g.
+//
+//	result, err := l.Log(message)
+//	dl.WriteSyncBulkResult(result, err)
+func (w *Writer) WriteSyncBulkResult(result *logger.SyncBulkResult, cause error) error {
+	if result == nil {
+		return nil
+	}
+	return w.Write(result.FailedMessages, cause)
+}
+
+// WriteAsyncBulkResult waits the result of AsyncBulkLogger, and writes the failed messages.
+func (w *Writer) WriteAsyncBulkResult(result *logger.AsyncBulkResult) error {
+	cause := <-result.AsyncErrChan
+	failed := <-result.FailedMessagesChan
+	return w.Write(failed, cause)
+}
+
+// MalformedRecordError is the error of the line that is not a valid record.
+type MalformedRecordError struct {
+	Line []byte
+	Err  error
+}
+
+func (e *MalformedRecordError) Error() string {
+	return fmt.Sprintf("malformed record: %s", e.Err)
+}
+
+// Reader reads the records.
+type Reader struct {
+	r           *bufio.Reader
+	offset      int64
+	maxLineSize int
+}
+
+// NewReader creates a new Reader.
+func NewReader(r io.Reader) *Reader {
+	return &Reader{r: bufio.NewReader(r), maxLineSize: maxLineSize}
+}
+
+// Next reads the next record. This returns *MalformedRecordError for the line that is not a valid record,
+// and the reading can be continued after that. This returns io.EOF at the end.
+//
+// The line that exceeds the limit (8MB) is also malformed; the Line of that error is only the head of the line.
+func (r *Reader) Next() (Record, error) {
+	for {
+		line, err := r.readLine()
+		if err != nil {
+			return Record{}, err
+		}
+		if len(bytes.TrimSpace(line)) == 0 {
+			continue
+		}
+
+		var record Record
+		if err := json.Unmarshal(line, &record); err != nil {
+			return Record{}, &MalformedRecordError{Line: line, Err: err}
+		}
+		if err := record.validate(); err != nil {
+			return Record{}, &MalformedRecordError{Line: line, Err: err}
+		}
+		return record, nil
+	}
+}
+
+func (r *Reader) readLine() ([]byte, error) {
+	var line []byte
+	var consumed int64
+	oversized := false
+	for {
+		chunk, err := r.r.ReadSlice('\n')
+		consumed += int64(len(chunk))
+		if !oversized && len(line)+len(chunk) > r.maxLineSize {
+			// only the head is kept, and the rest of the line is skipped
+			line = append(line, chunk[:r.maxLineSize-len(line)]...)
+			oversized = true
+		} else if !oversized {
+			line = append(line, chunk...)
+		}
+		if err == bufio.ErrBufferFull {
+			continue
+		}
+		if err == io.EOF && consumed > 0 {
+			// the last line without the newline
+			err = nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		r.offset += consumed
+		if oversized {
+			return nil, &MalformedRecordError{
+				Line: line,
+				Err:  fmt.Errorf("line is too long [size: %d bytes, limit: %d bytes]", consumed, r.maxLineSize),
+			}
+		}
+		return bytes.TrimRight(line, "\r\n"), nil
+	}
+}
+
+// Offset returns the position of the end of the line that has been read last.
+func (r *Reader) Offset() int64 {
+	return r.offset
+}
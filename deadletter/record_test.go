@@ -0,0 +1,123 @@
+package deadletter
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWriterAndReaderShouldRoundTripMessages(t *testing.T) {
+	buf := &bytes.Buffer{}
+	w := NewWriter(buf, []string{"web", "api"})
+	failedAt := time.Date(2018, 1, 5, 17, 11, 25, 0, time.UTC)
+	w.now = func() time.Time { return failedAt }
+
+	messages := [][]byte{
+		[]byte("{\n  \"message\": \"<hello>\"\n}"),
+		[]byte("plain text"),
+		[]byte(`"json string"`),
+	}
+	if err := w.Write(messages, errors.New("failed to call log API [status=503, msg=]")); err != nil {
+		t.Fatal(err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	expectedFirst := `{"version":1,"failed_at":"2018-01-05T17:11:25Z","tags":["web","api"],"error":"failed to call log API [status=503, msg=]","payload":{"message":"<hello>"}}`
+	if len(lines) != 3 || lines[0] != expectedFirst {
+		t.Fatalf("unexpected records: %q", lines)
+	}
+
+	r := NewReader(buf)
+	var got []string
+	for {
+		record, err := r.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !record.FailedAt.Equal(failedAt) || !reflect.DeepEqual(record.Tags, []string{"web", "api"}) {
+			t.Errorf("unexpected record: %+v", record)
+		}
+		message, err := record.Message()
+		if err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, string(message))
+	}
+	expected := []string{`{"message":"<hello>"}`, "plain text", `"json string"`}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("got %q, expected %q", got, expected)
+	}
+}
+
+func TestReaderShouldReportMalformedRecordsAndContinue(t *testing.T) {
+	input := `{"version":1,"payload":{"n":1}}
+
+not json
+{"version":2,"payload":{"n":2}}
+{"version":1,"encoding":"text","payload":"last"}`
+	r := NewReader(strings.NewReader(input))
+
+	record, err := r.Next()
+	if err != nil || string(record.Payload) != `{"n":1}` {
+		t.Fatalf("unexpected first record: %+v, %v", record, err)
+	}
+	if r.Offset() != int64(strings.Index(input, "\n")+1) {
+		t.Errorf("unexpected offset: %d", r.Offset())
+	}
+
+	for _, line := range []string{"not json", `{"version":2,"payload":{"n":2}}`} {
+		_, err := r.Next()
+		var malformed *MalformedRecordError
+		if !errors.As(err, &malformed) || string(malformed.Line) != line {
+			t.Errorf("expected the malformed record error of %q, but got %v", line, err)
+		}
+	}
+
+	record, err = r.Next()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if message, _ := record.Message(); string(message) != "last" {
+		t.Errorf("unexpected last message: %q", message)
+	}
+	if r.Offset() != int64(len(input)) {
+		t.Errorf("unexpected offset: %d", r.Offset())
+	}
+	if _, err := r.Next(); err != io.EOF {
+		t.Errorf("expected io.EOF, but got %v", err)
+	}
+}
+
+func TestReaderShouldRejectOversizedLineWhole(t *testing.T) {
+	long := `{"version":1,"payload":{"n":"` + strings.Repeat("x", 64) + `"}}`
+	input := `{"version":1,"payload":{"n":1}}` + "\n" + long + "\n" + `{"version":1,"payload":{"n":3}}` + "\n"
+	r := NewReader(strings.NewReader(input))
+	r.maxLineSize = 40
+
+	if record, err := r.Next(); err != nil || string(record.Payload) != `{"n":1}` {
+		t.Fatalf("unexpected first record: %+v, %v", record, err)
+	}
+
+	_, err := r.Next()
+	var malformed *MalformedRecordError
+	if !errors.As(err, &malformed) || !strings.Contains(malformed.Error(), "line is too long") {
+		t.Fatalf("expected the malformed record error of the oversized line, but got %v", err)
+	}
+	if string(malformed.Line) != long[:r.maxLineSize] {
+		t.Errorf("unexpected line: %q", malformed.Line)
+	}
+	if r.Offset() != int64(strings.Index(input, long)+len(long)+1) {
+		t.Errorf("unexpected offset: %d", r.Offset())
+	}
+
+	if record, err := r.Next(); err != nil || string(record.Payload) != `{"n":3}` {
+		t.Errorf("unexpected last record: %+v, %v", record, err)
+	}
+}
@@ -0,0 +1,386 @@
+package deadletter
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/moznion/logglily/api"
+	internalAPI "github.com/moznion/logglily/internal/api"
+	"github.com/moznion/logglily/internal/ratelimit"
+)
+
+const (
+	defaultBatchSize    = 1000
+	defaultMaxRetries   = 5
+	defaultRetryBackoff = time.Second
+	maxBulkBytes        = 5 * 1024 * 1024
+)
+
+// ReplayConfig is the setting of Replayer.
+type ReplayConfig struct {
+	Token    string
+	Insecure bool
+	// DefaultTags are the tags for the records that have no tags.
+	DefaultTags []string
+
+	// Rate is the maximum number of the messages to send per second. If this is less or equal to 0, that is unlimited.
+	Rate float64
+	// BatchSize is the maximum number of the messages in a bulk request. The default is 1000.
+	BatchSize int
+	// MaxRetries is the number of the retries for the temporary failures (5xx, 429 and the network errors).
+	// If this is less or equal to 0, the default (5) is used; please set NoRetry to disable the retries.
+	MaxRetries int
+	// NoRetry disables the retries; the temporary failure stops the replay immediately.
+	NoRetry bool
+	// RetryBackoff is the wait before the first retry; that is doubled for each retry. The default is 1 second.
+	RetryBackoff time.Duration
+
+	// APIClient creates the API client for the tags if it is not nil. This is mainly for the tests.
+	APIClient func(tags []string) api.Client
+}
+
+// ReplayStats is the result of the replay.
+type ReplayStats struct {
+	Sent     int
+	Rejected int
+}
+
+// Replayer resends the records of the dead-letter files via the bulk API.
+type Replayer struct {
+	config     ReplayConfig
+	batchSize  int
+	maxRetries int
+	backoff    time.Duration
+	limiter    *ratelimit.TokenBucket
+	clients    map[string]api.Client
+	sleep      func(d time.Duration)
+	now        func() time.Time
+}
+
+// NewReplayer creates a new Replayer.
+func NewReplayer(config ReplayConfig) (*Replayer, error) {
+	if config.Token == "" && config.APIClient == nil {
+		return nil, errors.New("token is required")
+	}
+
+	rp := &Replayer{
+		config:     config,
+		batchSize:  config.BatchSize,
+		maxRetries: config.MaxRetries,
+		backoff:    config.RetryBackoff,
+		clients:    make(map[string]api.Client),
+		sleep:      time.Sleep,
+		now:        time.Now,
+	}
+	if rp.batchSize <= 0 {
+		rp.batchSize = defaultBatchSize
+	}
+	if config.NoRetry {
+		rp.maxRetries = 0
+	} else if rp.maxRetries <= 0 {
+		rp.maxRetries = defaultMaxRetries
+	}
+	if rp.backoff <= 0 {
+		rp.backoff = defaultRetryBackoff
+	}
+	if config.Rate > 0 {
+		// a batch never exceeds the tokens of a second, so that the rate is kept from the first batch
+		if rate := int(config.Rate); rate < rp.batchSize {
+			rp.batchSize = rate
+			if rp.batchSize < 1 {
+				rp.batchSize = 1
+			}
+		}
+		rp.limiter = ratelimit.NewTokenBucket(config.Rate, float64(rp.batchSize))
+	}
+	return rp, nil
+}
+
+// replay is the state of the replay of a file.
+type replay struct {
+	*Replayer
+	progressPath string
+	rejectedPath string
+	rejected     *os.File
+	stats        ReplayStats
+}
+
+// Replay resends the records of the file. The offset up to which the records have been processed is saved into the progress file
+// after each bulk request, and the replay resumes from that offset; so please remove the progress file to replay the file again.
+//
+// The records that loggly rejects (400 and 413) and the malformed lines are appended into the rejected file
+// as the records; their `error` is the reason. The temporary failures (5xx, 429 and the network errors) are retried,
+// and the replay stops with the error if they are not recovered. The other client errors (e.g. 401, 403 and 404)
+// stop the replay immediately, because they are caused by the token or the endpoint rather than the records.
+func (rp *Replayer) Replay(path string, progressPath string, rejectedPath string) (ReplayStats, error) {
+	r := &replay{Replayer: rp, progressPath: progressPath, rejectedPath: rejectedPath}
+	defer func() {
+		if r.rejected != nil {
+			r.rejected.Close()
+		}
+	}()
+
+	offset, err := loadProgress(progressPath)
+	if err != nil {
+		return r.stats, fmt.Errorf("failed to load the progress: %s", err)
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return r.stats, err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return r.stats, err
+	}
+	if info.Size() < offset {
+		return r.stats, fmt.Errorf("progress (offset %d) is beyond the end of %s; the file may be replaced", offset, path)
+	}
+	if _, err := f.Seek(offset, io.SeekStart); err != nil {
+		return r.stats, err
+	}
+
+	reader := NewReader(f)
+	reader.offset = offset
+
+	var batch []Record
+	var batchKey string
+	var batchBytes int
+	var batchEnd int64
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		if err := r.send(batch); err != nil {
+			return err
+		}
+		batch, batchBytes = nil, 0
+		return r.saveProgress(batchEnd)
+	}
+
+	for {
+		record, err := reader.Next()
+		if err == io.EOF {
+			break
+		}
+		var malformed *MalformedRecordError
+		if errors.As(err, &malformed) {
+			if err := flush(); err != nil {
+				return r.stats, err
+			}
+			if err := r.reject(NewRecord(malformed.Line, nil, malformed, r.now())); err != nil {
+				return r.stats, err
+			}
+			if err := r.saveProgress(reader.Offset()); err != nil {
+				return r.stats, err
+			}
+			continue
+		}
+		if err != nil {
+			return r.stats, err
+		}
+
+		message, err := record.Message()
+		if err == nil && bytes.IndexByte(message, '\n') >= 0 {
+			err = errors.New("message contains the newline")
+		}
+		if err != nil {
+			if err := flush(); err != nil {
+				return r.stats, err
+			}
+			record.Error = err.Error()
+			if err := r.reject(record); err != nil {
+				return r.stats, err
+			}
+			if err := r.saveProgress(reader.Offset()); err != nil {
+				return r.stats, err
+			}
+			continue
+		}
+
+		key := strings.Join(r.tags(record), ",")
+		if len(batch) > 0 && (key != batchKey || len(batch) >= r.batchSize || batchBytes+len(message)+1 > maxBulkBytes) {
+			if err := flush(); err != nil {
+				return r.stats, err
+			}
+		}
+		batch = append(batch, record)
+		batchKey = key
+		batchBytes += len(message) + 1
+		batchEnd = reader.Offset()
+	}
+
+	return r.stats, flush()
+}
+
+func (r *replay) tags(record Record) []string {
+	if len(record.Tags) > 0 {
+		return record.Tags
+	}
+	return r.config.DefaultTags
+}
+
+func (r *replay) client(tags []string) api.Client {
+	key := strings.Join(tags, ",")
+	if c, ok := r.clients[key]; ok {
+		return c
+	}
+
+	var c api.Client
+	if r.config.APIClient != nil {
+		c = r.config.APIClient(tags)
+	} else {
+		c = internalAPI.NewSimpleClient(tags, r.config.Token, !r.config.Insecure)
+	}
+	r.clients[key] = c
+	return c
+}
+
+// send sends the records that have the same tags. If loggly rejects them, they are bisected to find the rejected records.
+func (r *replay) send(records []Record) error {
+	if r.limiter != nil {
+		r.limiter.Wait(float64(len(records)))
+	}
+
+	messages := make([][]byte, len(records))
+	for i, record := range records {
+		messages[i], _ = record.Message()
+	}
+
+	err := r.post(r.client(r.tags(records[0])), bytes.Join(messages, []byte{'\n'}))
+	if err == nil {
+		r.stats.Sent += len(records)
+		return nil
+	}
+	rejection, ok := err.(*rejectionError)
+	if !ok {
+		return err
+	}
+
+	if len(records) == 1 {
+		records[0].Error = rejection.Error()
+		return r.reject(records[0])
+	}
+	half := len(records) / 2
+	if err := r.send(records[:half]); err != nil {
+		return err
+	}
+	return r.send(records[half:])
+}
+
+// rejectionError is the error of the payload that loggly rejects; it is not retried, and the records are bisected.
+type rejectionError struct {
+	status int
+	msg    []byte
+}
+
+func (e *rejectionError) Error() string {
+	return fmt.Sprintf("failed to call log API [status=%d, msg=%s]", e.status, e.msg)
+}
+
+// post posts the payload with the retries. This returns *rejectionError if loggly rejects the payload (400 and 413),
+// or the other error if the temporary failure is not recovered or the failure is not temporary.
+func (r *replay) post(client api.Client, payload []byte) error {
+	for attempt := 0; ; attempt++ {
+		resp, err := client.LogAsBulk(payload)
+		if err == nil {
+			status := resp.StatusCode
+			msg, _ := ioutil.ReadAll(resp.Body)
+			resp.Body.Close()
+
+			switch {
+			case 200 <= status && status <= 299:
+				return nil
+			case status == http.StatusBadRequest || status == http.StatusRequestEntityTooLarge:
+				return &rejectionError{status: status, msg: msg}
+			case 400 <= status && status <= 499 && status != http.StatusTooManyRequests:
+				return fmt.Errorf("failed to call log API [status=%d, msg=%s]", status, msg)
+			}
+			err = fmt.Errorf("failed to call log API [status=%d, msg=%s]", status, msg)
+		}
+
+		if attempt >= r.maxRetries {
+			return err
+		}
+		r.sleep(r.backoff << uint(attempt))
+	}
+}
+
+// reject appends the record into the rejected file.
+func (r *replay) reject(record Record) error {
+	if r.rejected == nil {
+		f, err := os.OpenFile(r.rejectedPath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+		if err != nil {
+			return err
+		}
+		r.rejected = f
+	}
+
+	record.FailedAt = r.now()
+	buf := &bytes.Buffer{}
+	encoder := json.NewEncoder(buf)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(record); err != nil {
+		return err
+	}
+	if _, err := r.rejected.Write(buf.Bytes()); err != nil {
+		return err
+	}
+	r.stats.Rejected++
+	return nil
+}
+
+// saveProgress saves the offset atomically after the rejected records are persisted.
+func (r *replay) saveProgress(offset int64) error {
+	if r.rejected != nil {
+		if err := r.rejected.Sync(); err != nil {
+			return err
+		}
+	}
+
+	b, _ := json.Marshal(progress{Offset: offset})
+	tmp, err := ioutil.TempFile(filepath.Dir(r.progressPath), filepath.Base(r.progressPath)+".tmp")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(b); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), r.progressPath)
+}
+
+// progress is the content of the progress file, e.g. {"offset":1024}
+type progress struct {
+	Offset int64 `json:"offset"`
+}
+
+func loadProgress(path string) (int64, error) {
+	b, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	var p progress
+	if err := json.Unmarshal(b, &p); err != nil {
+		return 0, err
+	}
+	return p.Offset, nil
+}
@@ -0,0 +1,250 @@
+package deadletter
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/moznion/logglily/api"
+	internalAPI "github.com/moznion/logglily/internal/api"
+)
+
+// newTestReplayer creates a replayer with the client that accepts the bulks unless they contain the rejected message;
+// the responses are consumed before that, and the status 0 means the connection error.
+func newTestReplayer(t *testing.T, config ReplayConfig, responses []int, rejected string) (*Replayer, *internalAPI.RecordingClient) {
+	client := &internalAPI.RecordingClient{}
+	client.Respond = func(body []byte) (int, error) {
+		status := http.StatusOK
+		if len(responses) > 0 {
+			status = responses[0]
+			responses = responses[1:]
+		} else if rejected != "" && bytes.Contains(body, []byte(rejected)) {
+			status = http.StatusBadRequest
+		}
+		if status == 0 {
+			return 0, errors.New("connection refused")
+		}
+		return status, nil
+	}
+	config.APIClient = func(tags []string) api.Client {
+		return client.Labeled(strings.Join(tags, ","))
+	}
+	rp, err := NewReplayer(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rp.sleep = func(d time.Duration) {}
+	return rp, client
+}
+
+func writeDeadLetters(t *testing.T, dir string, lines ...string) (string, string, string) {
+	path := filepath.Join(dir, "failed.ndjson")
+	if err := ioutil.WriteFile(path, []byte(strings.Join(lines, "\n")+"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path, path + ".progress", path + ".rejected"
+}
+
+func readRecords(t *testing.T, path string) []Record {
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	var records []Record
+	r := NewReader(f)
+	for {
+		record, err := r.Next()
+		if err != nil {
+			return records
+		}
+		records = append(records, record)
+	}
+}
+
+func TestReplayShouldBatchRecordsPerTags(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "deadletter")
+	defer os.RemoveAll(dir)
+	path, progressPath, rejectedPath := writeDeadLetters(t, dir,
+		`{"version":1,"tags":["a"],"payload":{"n":1}}`,
+		`{"version":1,"tags":["a"],"payload":{"n":2}}`,
+		`{"version":1,"tags":["a"],"payload":{"n":3}}`,
+		`{"version":1,"tags":["b"],"payload":{"n":4}}`,
+		`{"version":1,"encoding":"text","payload":"no tags"}`,
+	)
+	rp, client := newTestReplayer(t, ReplayConfig{BatchSize: 2, DefaultTags: []string{"default"}}, nil, "")
+
+	stats, err := rp.Replay(path, progressPath, rejectedPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats != (ReplayStats{Sent: 5}) {
+		t.Errorf("unexpected stats: %+v", stats)
+	}
+	expected := []string{
+		"a {\"n\":1}\n{\"n\":2}",
+		`a {"n":3}`,
+		`b {"n":4}`,
+		"default no tags",
+	}
+	if !reflect.DeepEqual(client.Bulks(), expected) {
+		t.Errorf("got %q, expected %q", client.Bulks(), expected)
+	}
+	if _, err := os.Stat(rejectedPath); !os.IsNotExist(err) {
+		t.Errorf("rejected file should not be created: %v", err)
+	}
+
+	// the replay is done already
+	stats, err = rp.Replay(path, progressPath, rejectedPath)
+	if err != nil || stats != (ReplayStats{}) || len(client.Bulks()) != len(expected) {
+		t.Errorf("the records should not be sent again: %+v, %v, %q", stats, err, client.Bulks())
+	}
+}
+
+func TestReplayShouldMoveRejectedAndMalformedLines(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "deadletter")
+	defer os.RemoveAll(dir)
+	path, progressPath, rejectedPath := writeDeadLetters(t, dir,
+		`{"version":1,"payload":{"n":1}}`,
+		`{"version":1,"payload":{"n":"bad"}}`,
+		`{"version":1,"payload":{"n":3}}`,
+		`broken`,
+		`{"version":1,"payload":{"n":5}}`,
+	)
+	rp, client := newTestReplayer(t, ReplayConfig{}, nil, `"bad"`)
+
+	stats, err := rp.Replay(path, progressPath, rejectedPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats != (ReplayStats{Sent: 3, Rejected: 2}) {
+		t.Errorf("unexpected stats: %+v", stats)
+	}
+	expected := []string{`{"n":1}`, `{"n":3}`, `{"n":5}`}
+	if !reflect.DeepEqual(client.Bulks(), expected) {
+		t.Errorf("got %q, expected %q", client.Bulks(), expected)
+	}
+
+	records := readRecords(t, rejectedPath)
+	if len(records) != 2 {
+		t.Fatalf("unexpected rejected records: %+v", records)
+	}
+	if string(records[0].Payload) != `{"n":"bad"}` || !strings.Contains(records[0].Error, "status=400") {
+		t.Errorf("unexpected rejected record: %+v", records[0])
+	}
+	if message, _ := records[1].Message(); string(message) != "broken" || !strings.Contains(records[1].Error, "malformed record") {
+		t.Errorf("unexpected malformed record: %+v", records[1])
+	}
+}
+
+func TestReplayShouldRetryAndResumeFromProgress(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "deadletter")
+	defer os.RemoveAll(dir)
+	path, progressPath, rejectedPath := writeDeadLetters(t, dir,
+		`{"version":1,"payload":{"n":1}}`,
+		`{"version":1,"payload":{"n":2}}`,
+		`{"version":1,"payload":{"n":3}}`,
+	)
+	// the first batch is sent after a retry, and the second one fails permanently
+	rp, client := newTestReplayer(t, ReplayConfig{BatchSize: 1, MaxRetries: 2}, []int{503, 200, 0, 429, 503}, "")
+
+	stats, err := rp.Replay(path, progressPath, rejectedPath)
+	if err == nil || !strings.Contains(err.Error(), "status=503") {
+		t.Errorf("expected the error of the temporary failure, but got %v", err)
+	}
+	if stats != (ReplayStats{Sent: 1}) || len(client.Bulks()) != 1 {
+		t.Errorf("unexpected stats: %+v, %q", stats, client.Bulks())
+	}
+
+	stats, err = rp.Replay(path, progressPath, rejectedPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats != (ReplayStats{Sent: 2}) {
+		t.Errorf("unexpected stats: %+v", stats)
+	}
+	expected := []string{`{"n":1}`, `{"n":2}`, `{"n":3}`}
+	if !reflect.DeepEqual(client.Bulks(), expected) {
+		t.Errorf("got %q, expected %q", client.Bulks(), expected)
+	}
+
+	// the file is replaced by the shorter one
+	if err := ioutil.WriteFile(path, []byte("{}\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := rp.Replay(path, progressPath, rejectedPath); err == nil || !strings.Contains(err.Error(), "beyond the end") {
+		t.Errorf("expected the error of the progress, but got %v", err)
+	}
+}
+
+func TestReplayShouldAbortOnClientErrorsThatAreNotCausedByRecords(t *testing.T) {
+	for _, status := range []int{http.StatusUnauthorized, http.StatusForbidden, http.StatusNotFound} {
+		dir, _ := ioutil.TempDir("", "deadletter")
+		defer os.RemoveAll(dir)
+		path, progressPath, rejectedPath := writeDeadLetters(t, dir,
+			`{"version":1,"payload":{"n":1}}`,
+			`{"version":1,"payload":{"n":2}}`,
+		)
+		rp, client := newTestReplayer(t, ReplayConfig{}, []int{status}, "")
+
+		stats, err := rp.Replay(path, progressPath, rejectedPath)
+		if err == nil || !strings.Contains(err.Error(), "status="+strconv.Itoa(status)) {
+			t.Errorf("expected the error of the status %d, but got %v", status, err)
+		}
+		if stats != (ReplayStats{}) || len(client.Bulks()) != 0 {
+			t.Errorf("unexpected stats: %+v, %q", stats, client.Bulks())
+		}
+		if _, err := os.Stat(rejectedPath); !os.IsNotExist(err) {
+			t.Errorf("rejected file should not be created: %v", err)
+		}
+	}
+}
+
+func TestReplayShouldBisectPayloadTooLarge(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "deadletter")
+	defer os.RemoveAll(dir)
+	path, progressPath, rejectedPath := writeDeadLetters(t, dir,
+		`{"version":1,"payload":{"n":1}}`,
+		`{"version":1,"payload":{"n":2}}`,
+	)
+	rp, client := newTestReplayer(t, ReplayConfig{}, []int{http.StatusRequestEntityTooLarge}, "")
+
+	stats, err := rp.Replay(path, progressPath, rejectedPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats != (ReplayStats{Sent: 2}) {
+		t.Errorf("unexpected stats: %+v", stats)
+	}
+	if expected := []string{`{"n":1}`, `{"n":2}`}; !reflect.DeepEqual(client.Bulks(), expected) {
+		t.Errorf("got %q, expected %q", client.Bulks(), expected)
+	}
+}
+
+func TestReplayShouldNotRetryWithNoRetry(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "deadletter")
+	defer os.RemoveAll(dir)
+	path, progressPath, rejectedPath := writeDeadLetters(t, dir, `{"version":1,"payload":{"n":1}}`)
+
+	rp, client := newTestReplayer(t, ReplayConfig{NoRetry: true}, []int{503, 200}, "")
+	if _, err := rp.Replay(path, progressPath, rejectedPath); err == nil || !strings.Contains(err.Error(), "status=503") {
+		t.Errorf("expected the error of the temporary failure, but got %v", err)
+	}
+	if len(client.Bulks()) != 0 {
+		t.Errorf("should not be retried: %q", client.Bulks())
+	}
+
+	rp, _ = newTestReplayer(t, ReplayConfig{}, nil, "")
+	if rp.maxRetries != defaultMaxRetries {
+		t.Errorf("maxRetries == %d but wants %d", rp.maxRetries, defaultMaxRetries)
+	}
+}
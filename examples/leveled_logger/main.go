@@ -0,0 +1,44 @@
+//go:build !windows
+// +build !windows
+
+package main
+
+import (
+	"net/http"
+	"os"
+	"syscall"
+
+	"github.com/moznion/logglily/logger"
+)
+
+func main() {
+	token := os.Getenv("LOGGLY_TOKEN")
+	tag := os.Getenv("LOGGLY_TAG")
+
+	bulkLogger, err := logger.NewAsyncBulkLogger([]string{tag}, token, true, 1024*1024*3, 10000)
+	if err != nil {
+		panic(err)
+	}
+	defer func() {
+		<-bulkLogger.Shutdown().AsyncErrChan
+	}()
+
+	level := logger.NewAtomicLevel(logger.InfoLevel)
+	stop := level.SetLevelOnSignal(map[os.Signal]logger.Level{
+		syscall.SIGUSR1: logger.DebugLevel, // <= `kill -USR1 <pid>` enables debug messages
+		syscall.SIGUSR2: logger.InfoLevel,
+	})
+	defer stop()
+	// if the application serves http.DefaultServeMux (e.g. `http.ListenAndServe(":8080", nil)`),
+	// `curl -X PUT -d level=debug localhost:8080/log/level` also changes the level
+	http.Handle("/log/level", level)
+
+	l := logger.NewLeveledLogger(bulkLogger, level)
+	l.Route(logger.ErrorLevel, logger.NewSyncLogger([]string{tag}, token, true)) // <= errors are sent immediately
+
+	l.Debug(logger.Message{"message": "this is discarded by default"})
+	l.Info(logger.Message{"message": "hello", "from": "moznion"})
+	if err := l.Error(logger.Message{"message": "something wrong"}); err != nil {
+		panic(err)
+	}
+}
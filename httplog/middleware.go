@@ -0,0 +1,250 @@
+// Package httplog provides the `net/http` middlewares that log the requests through the loggers of logglily.
+package httplog
+
+import (
+	"fmt"
+	"math/rand"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/moznion/logglily/logger"
+)
+
+// The names of the fields of the access log.
+const (
+	FieldMethod    = "method"
+	FieldPath      = "path"
+	FieldRoute     = "route"
+	FieldStatus    = "status"
+	FieldBytes     = "bytes"
+	FieldDuration  = "duration_ms"
+	FieldRemoteIP  = "remote_ip"
+	FieldUserAgent = "user_agent"
+	FieldRequestID = "request_id"
+)
+
+var allFields = []string{
+	FieldMethod, FieldPath, FieldRoute, FieldStatus, FieldBytes,
+	FieldDuration, FieldRemoteIP, FieldUserAgent, FieldRequestID,
+}
+
+const defaultRequestIDHeader = "X-Request-Id"
+
+// Middleware is the access-log middleware that emits one message per request through the sink, e.g.
+//
+//	{"bytes":12,"duration_ms":1.23,"message":"GET /users/42 200","method":"GET","path":"/users/42","remote_ip":"192.0.2.1",
+//	 "request_id":"abc","route":"/users/{id}","status":200,"user_agent":"curl/7.58.0"}
+//
+// The message is sent after the handler returns. The fields of empty values (e.g. route, request_id) are omitted.
+//
+// NOTE: the setters are not goroutine-safe. Please configure the middleware before serving.
+type Middleware struct {
+	sink            logger.Sink
+	fields          map[string]bool
+	skip            func(r *http.Request) bool
+	skipPaths       []string
+	route           func(r *http.Request) string
+	trustedProxies  []*net.IPNet
+	requestIDHeader string
+	sampling        map[int]float64
+	random          func() float64
+}
+
+// NewMiddleware creates a new access-log middleware that sends the messages through the sink.
+// All fields are enabled by default.
+func NewMiddleware(sink logger.Sink) *Middleware {
+	m := &Middleware{
+		sink:            sink,
+		route:           defaultRoute,
+		requestIDHeader: defaultRequestIDHeader,
+		sampling:        make(map[int]float64),
+		random:          rand.Float64,
+	}
+	m.SetFields(allFields...)
+	return m
+}
+
+// SetFields selects the fields of the access log, e.g. httplog.FieldMethod, httplog.FieldStatus.
+// "message" is always emitted.
+func (m *Middleware) SetFields(fields ...string) {
+	m.fields = make(map[string]bool, len(fields))
+	for _, f := range fields {
+		m.fields[f] = true
+	}
+}
+
+// SetSkipPaths sets the paths that are not logged, e.g. "/healthz".
+// The path that ends with "*" matches by the prefix, e.g. "/static/*".
+func (m *Middleware) SetSkipPaths(paths ...string) {
+	m.skipPaths = paths
+}
+
+// SetSkip sets the predicate that reports whether the request is not logged.
+func (m *Middleware) SetSkip(skip func(r *http.Request) bool) {
+	m.skip = skip
+}
+
+// SetRoute sets the function that returns the route (the pattern) of the request, e.g. "/users/{id}".
+// By default, this is the matched pattern of `http.ServeMux` on Go 1.23 or later; otherwise route is not logged.
+func (m *Middleware) SetRoute(route func(r *http.Request) string) {
+	m.route = route
+}
+
+// SetTrustedProxies sets the CIDRs (or IP addresses) of the trusted proxies.
+// If the request comes from the trusted proxy, the remote IP is taken from X-Forwarded-For or X-Real-IP header.
+// By default, no proxy is trusted and the remote IP is the peer address.
+//
+// This returns an error if the CIDR is malformed.
+func (m *Middleware) SetTrustedProxies(cidrs ...string) error {
+	nets := make([]*net.IPNet, 0, len(cidrs))
+	for _, cidr := range cidrs {
+		if !strings.Contains(cidr, "/") {
+			if ip := net.ParseIP(cidr); ip != nil {
+				bits := 8 * net.IPv6len
+				if ip.To4() != nil {
+					ip = ip.To4()
+					bits = 8 * net.IPv4len
+				}
+				nets = append(nets, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+				continue
+			}
+		}
+		_, ipNet, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return fmt.Errorf("malformed trusted proxy [given: %s]: %s", cidr, err)
+		}
+		nets = append(nets, ipNet)
+	}
+	m.trustedProxies = nets
+	return nil
+}
+
+// SetRequestIDHeader changes the header that has the request ID. The default is "X-Request-Id".
+func (m *Middleware) SetRequestIDHeader(header string) {
+	m.requestIDHeader = header
+}
+
+// SetStatusSampling sets the rate of logging for the status class, e.g. SetStatusSampling(2, 0.1) logs 10% of 2xx responses.
+// The rate is between 0.0 and 1.0. The status classes that are not set are always logged.
+func (m *Middleware) SetStatusSampling(statusClass int, rate float64) {
+	m.sampling[statusClass] = rate
+}
+
+// Handler wraps the handler to log the requests.
+func (m *Middleware) Handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if m.shouldSkip(r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		start := time.Now()
+		rw := newResponseWriter(w)
+		defer func() {
+			// log even if the handler panics; the recovery middleware (if any) responds after this
+			if p := recover(); p != nil {
+				if !rw.wroteHeader {
+					rw.status = http.StatusInternalServerError
+				}
+				m.log(r, rw, time.Since(start))
+				panic(p)
+			}
+			m.log(r, rw, time.Since(start))
+		}()
+
+		next.ServeHTTP(rw, r)
+	})
+}
+
+func (m *Middleware) shouldSkip(r *http.Request) bool {
+	for _, path := range m.skipPaths {
+		if strings.HasSuffix(path, "*") {
+			if strings.HasPrefix(r.URL.Path, strings.TrimSuffix(path, "*")) {
+				return true
+			}
+			continue
+		}
+		if r.URL.Path == path {
+			return true
+		}
+	}
+	return m.skip != nil && m.skip(r)
+}
+
+func (m *Middleware) log(r *http.Request, rw *responseWriter, elapsed time.Duration) {
+	status := rw.statusCode()
+	if rate, ok := m.sampling[status/100]; ok && (rate <= 0 || (rate < 1 && m.random() >= rate)) {
+		return
+	}
+
+	message := logger.Message{
+		"message": fmt.Sprintf("%s %s %d", r.Method, r.URL.Path, status),
+	}
+	m.put(message, FieldMethod, r.Method)
+	m.put(message, FieldPath, r.URL.Path)
+	if m.route != nil {
+		m.put(message, FieldRoute, m.route(r))
+	}
+	m.put(message, FieldStatus, status)
+	m.put(message, FieldBytes, rw.bytes)
+	m.put(message, FieldDuration, float64(elapsed)/float64(time.Millisecond))
+	m.put(message, FieldRemoteIP, m.remoteIP(r))
+	m.put(message, FieldUserAgent, r.UserAgent())
+	m.put(message, FieldRequestID, r.Header.Get(m.requestIDHeader))
+
+	m.sink.Send(message)
+}
+
+func (m *Middleware) put(message logger.Message, key string, value interface{}) {
+	if !m.fields[key] {
+		return
+	}
+	if s, ok := value.(string); ok && s == "" {
+		return
+	}
+	message[key] = value
+}
+
+// remoteIP returns the IP address of the client.
+// X-Forwarded-For is scanned from the right, and the first address that is not a trusted proxy is the client.
+func (m *Middleware) remoteIP(r *http.Request) string {
+	peer := r.RemoteAddr
+	if host, _, err := net.SplitHostPort(peer); err == nil {
+		peer = host
+	}
+	if !m.isTrusted(peer) {
+		return peer
+	}
+
+	if xff := r.Header["X-Forwarded-For"]; len(xff) > 0 {
+		addrs := strings.Split(strings.Join(xff, ","), ",")
+		for i := len(addrs) - 1; i >= 0; i-- {
+			addr := strings.TrimSpace(addrs[i])
+			if addr == "" {
+				continue
+			}
+			if !m.isTrusted(addr) || i == 0 {
+				return addr
+			}
+		}
+	}
+	if realIP := strings.TrimSpace(r.Header.Get("X-Real-IP")); realIP != "" {
+		return realIP
+	}
+	return peer
+}
+
+func (m *Middleware) isTrusted(addr string) bool {
+	ip := net.ParseIP(addr)
+	if ip == nil {
+		return false
+	}
+	for _, ipNet := range m.trustedProxies {
+		if ipNet.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,189 @@
+package httplog
+
+import (
+	"bufio"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/moznion/logglily/logger"
+)
+
+type recordingSink struct {
+	messages []logger.Message
+}
+
+func (s *recordingSink) Send(message logger.Message) error {
+	s.messages = append(s.messages, message)
+	return nil
+}
+
+func TestMiddlewareShouldLogRequest(t *testing.T) {
+	sink := &recordingSink{}
+	m := NewMiddleware(sink)
+	m.SetRoute(func(r *http.Request) string { return "/users/{id}" })
+
+	h := m.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte("hello"))
+	}))
+
+	req := httptest.NewRequest("POST", "/users/42?q=1", nil)
+	req.RemoteAddr = "192.0.2.1:12345"
+	req.Header.Set("User-Agent", "test-agent")
+	req.Header.Set("X-Request-Id", "req-1")
+	h.ServeHTTP(httptest.NewRecorder(), req)
+
+	if len(sink.messages) != 1 {
+		t.Fatalf("len(messages) == %d", len(sink.messages))
+	}
+	got := sink.messages[0]
+	if _, ok := got[FieldDuration].(float64); !ok {
+		t.Errorf("duration is missing: %v", got)
+	}
+	delete(got, FieldDuration)
+
+	expected := logger.Message{
+		"message":      "POST /users/42 201",
+		FieldMethod:    "POST",
+		FieldPath:      "/users/42",
+		FieldRoute:     "/users/{id}",
+		FieldStatus:    201,
+		FieldBytes:     int64(5),
+		FieldRemoteIP:  "192.0.2.1",
+		FieldUserAgent: "test-agent",
+		FieldRequestID: "req-1",
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("got == %v but wants %v", got, expected)
+	}
+}
+
+func TestMiddlewareShouldSelectFieldsAndSkipPaths(t *testing.T) {
+	sink := &recordingSink{}
+	m := NewMiddleware(sink)
+	m.SetFields(FieldStatus)
+	m.SetSkipPaths("/healthz", "/static/*")
+	m.SetSkip(func(r *http.Request) bool { return r.Method == "OPTIONS" })
+
+	h := m.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	for _, req := range []*http.Request{
+		httptest.NewRequest("GET", "/healthz", nil),
+		httptest.NewRequest("GET", "/static/app.js", nil),
+		httptest.NewRequest("OPTIONS", "/", nil),
+		httptest.NewRequest("GET", "/healthz/deep", nil),
+	} {
+		h.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	expected := []logger.Message{{"message": "GET /healthz/deep 200", FieldStatus: 200}}
+	if !reflect.DeepEqual(sink.messages, expected) {
+		t.Errorf("messages == %v but wants %v", sink.messages, expected)
+	}
+}
+
+func TestMiddlewareShouldSampleByStatusClass(t *testing.T) {
+	sink := &recordingSink{}
+	m := NewMiddleware(sink)
+	m.SetFields()
+	m.SetStatusSampling(2, 0.5)
+	m.SetStatusSampling(4, 0)
+	randoms := []float64{0.1, 0.9}
+	m.random = func() float64 {
+		r := randoms[0]
+		randoms = randoms[1:]
+		return r
+	}
+
+	status := http.StatusOK
+	h := m.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(status)
+	}))
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/a", nil))
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/b", nil))
+	status = http.StatusNotFound
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/c", nil))
+	status = http.StatusInternalServerError
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/d", nil))
+
+	expected := []logger.Message{{"message": "GET /a 200"}, {"message": "GET /d 500"}}
+	if !reflect.DeepEqual(sink.messages, expected) {
+		t.Errorf("messages == %v but wants %v", sink.messages, expected)
+	}
+}
+
+func TestMiddlewareShouldHonorTrustedProxies(t *testing.T) {
+	m := NewMiddleware(&recordingSink{})
+	if err := m.SetTrustedProxies("10.0.0.0/8", "192.0.2.10"); err != nil {
+		t.Fatal("unexpected err", err)
+	}
+	if err := m.SetTrustedProxies("10.0.0.0/33"); err == nil {
+		t.Error("should be error")
+	}
+	m.SetTrustedProxies("10.0.0.0/8", "192.0.2.10")
+
+	cases := []struct {
+		remoteAddr string
+		xff        []string
+		realIP     string
+		expected   string
+	}{
+		{"198.51.100.1:1", []string{"203.0.113.1"}, "", "198.51.100.1"}, // untrusted peer
+		{"10.0.0.1:1", []string{"203.0.113.1, 10.0.0.2"}, "", "203.0.113.1"},
+		{"10.0.0.1:1", []string{"spoofed, 203.0.113.1", "192.0.2.10"}, "", "203.0.113.1"},
+		{"10.0.0.1:1", []string{"10.0.0.3, 10.0.0.2"}, "", "10.0.0.3"},
+		{"10.0.0.1:1", nil, "203.0.113.5", "203.0.113.5"},
+		{"10.0.0.1:1", nil, "", "10.0.0.1"},
+	}
+	for _, c := range cases {
+		r := httptest.NewRequest("GET", "/", nil)
+		r.RemoteAddr = c.remoteAddr
+		for _, v := range c.xff {
+			r.Header.Add("X-Forwarded-For", v)
+		}
+		if c.realIP != "" {
+			r.Header.Set("X-Real-IP", c.realIP)
+		}
+		if got := m.remoteIP(r); got != c.expected {
+			t.Errorf("remoteIP == %s but wants %s (case: %v)", got, c.expected, c)
+		}
+	}
+}
+
+type hijackableRecorder struct {
+	*httptest.ResponseRecorder
+}
+
+func (hijackableRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	return nil, nil, nil
+}
+
+func TestMiddlewareShouldPassThroughOptionalInterfaces(t *testing.T) {
+	sink := &recordingSink{}
+	m := NewMiddleware(sink)
+	m.SetFields(FieldStatus)
+
+	h := m.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, _, err := w.(http.Hijacker).Hijack(); err != nil {
+			t.Error("unexpected err", err)
+		}
+		w.(http.Flusher).Flush()
+	}))
+	h.ServeHTTP(hijackableRecorder{httptest.NewRecorder()}, httptest.NewRequest("GET", "/ws", nil))
+
+	if sink.messages[0][FieldStatus] != http.StatusOK {
+		t.Errorf("messages == %v", sink.messages)
+	}
+
+	h = m.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, _, err := w.(http.Hijacker).Hijack(); err != nil {
+			t.Error("unexpected err", err)
+		}
+	}))
+	h.ServeHTTP(hijackableRecorder{httptest.NewRecorder()}, httptest.NewRequest("GET", "/ws", nil))
+	if sink.messages[1][FieldStatus] != http.StatusSwitchingProtocols {
+		t.Errorf("messages == %v", sink.messages)
+	}
+}
@@ -0,0 +1,55 @@
+package httplog
+
+import (
+	"net/http"
+	"runtime/debug"
+
+	"github.com/moznion/logglily/logger"
+)
+
+// Recoverer is the middleware that recovers the panics of the handlers.
+// The panic is reported through the CrashReporter synchronously with the stack of the goroutine,
+// and the client receives 500 Internal Server Error if the response has not been started.
+//
+// `http.ErrAbortHandler` is not reported; that is re-panicked to abort the response as `net/http` expects.
+//
+// Please put this inside the access-log middleware to log the status 500, e.g.
+//
+//	handler := accessLog.Handler(httplog.NewRecoverer(reporter).Handler(mux))
+type Recoverer struct {
+	reporter *logger.CrashReporter
+}
+
+// NewRecoverer creates a new Recoverer that reports the panics through the reporter.
+func NewRecoverer(reporter *logger.CrashReporter) *Recoverer {
+	return &Recoverer{
+		reporter: reporter,
+	}
+}
+
+// Handler wraps the handler to recover the panics.
+func (rc *Recoverer) Handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rw := newResponseWriter(w)
+		defer func() {
+			p := recover()
+			if p == nil {
+				return
+			}
+			if p == http.ErrAbortHandler {
+				panic(p)
+			}
+
+			rc.reporter.Report(p, debug.Stack(), logger.Message{
+				FieldMethod: r.Method,
+				FieldPath:   r.URL.Path,
+			})
+
+			if !rw.wroteHeader && !rw.hijacked {
+				http.Error(rw, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			}
+		}()
+
+		next.ServeHTTP(rw, r)
+	})
+}
@@ -0,0 +1,69 @@
+package httplog
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/moznion/logglily/internal/api"
+	"github.com/moznion/logglily/logger"
+)
+
+func newTestReporter() (*logger.CrashReporter, *api.RecordingClient) {
+	client := &api.RecordingClient{}
+	l := logger.NewSyncLogger([]string{"test-tag"}, "test-token", true)
+	l.APIClient = client
+	return logger.NewCrashReporter(l), client
+}
+
+func TestRecovererShouldReportPanicAndRespond500(t *testing.T) {
+	reporter, client := newTestReporter()
+	sink := &recordingSink{}
+	accessLog := NewMiddleware(sink)
+	accessLog.SetFields(FieldStatus)
+
+	h := accessLog.Handler(NewRecoverer(reporter).Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic("boom")
+	})))
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("GET", "/panic", nil))
+
+	if rec.Code != http.StatusInternalServerError {
+		t.Errorf("code == %d but wants 500", rec.Code)
+	}
+	if len(client.Events()) != 1 {
+		t.Fatalf("len(bodies) == %d", len(client.Events()))
+	}
+	report := client.Events()[0]
+	for _, expected := range []string{`"message":"panic: boom"`, `"method":"GET"`, `"path":"/panic"`, "TestRecovererShouldReportPanicAndRespond500"} {
+		if !strings.Contains(report, expected) {
+			t.Errorf("report doesn't contain %s: %s", expected, report)
+		}
+	}
+	if sink.messages[0][FieldStatus] != http.StatusInternalServerError {
+		t.Errorf("access log == %v", sink.messages)
+	}
+}
+
+func TestRecovererShouldRepanicErrAbortHandler(t *testing.T) {
+	reporter, client := newTestReporter()
+	h := NewRecoverer(reporter).Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		panic(http.ErrAbortHandler)
+	}))
+
+	func() {
+		defer func() {
+			if p := recover(); p != http.ErrAbortHandler {
+				t.Errorf("recovered == %v", p)
+			}
+		}()
+		h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+	}()
+
+	if len(client.Events()) != 0 {
+		t.Errorf("ErrAbortHandler should not be reported: %v", client.Events())
+	}
+}
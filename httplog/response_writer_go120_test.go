@@ -0,0 +1,31 @@
+//go:build go1.20
+// +build go1.20
+
+package httplog
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestMiddlewareShouldSupportResponseController(t *testing.T) {
+	sink := &recordingSink{}
+	m := NewMiddleware(sink)
+	m.SetFields(FieldStatus)
+
+	h := m.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := http.NewResponseController(w).Flush(); err != nil {
+			t.Error("unexpected err", err)
+		}
+	}))
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("GET", "/events", nil))
+
+	if !rec.Flushed {
+		t.Error("response should be flushed through the controller")
+	}
+	if sink.messages[0][FieldStatus] != http.StatusOK {
+		t.Errorf("messages == %v", sink.messages)
+	}
+}
@@ -0,0 +1,10 @@
+//go:build !go1.23
+// +build !go1.23
+
+package httplog
+
+import "net/http"
+
+func defaultRoute(r *http.Request) string {
+	return ""
+}
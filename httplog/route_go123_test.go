@@ -0,0 +1,26 @@
+//go:build go1.23
+// +build go1.23
+
+package httplog
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestMiddlewareShouldLogServeMuxPattern(t *testing.T) {
+	sink := &recordingSink{}
+	m := NewMiddleware(sink)
+	m.SetFields(FieldRoute)
+
+	// sets the pattern as ServeMux does, because that is not set in the GOPATH mode (httpmuxgo121=1)
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.Pattern = "GET /users/{id}"
+	})
+	m.Handler(h).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/users/42", nil))
+
+	if sink.messages[0][FieldRoute] != "GET /users/{id}" {
+		t.Errorf("messages == %v", sink.messages)
+	}
+}
@@ -0,0 +1,188 @@
+package httplog
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	internalAPI "github.com/moznion/logglily/internal/api"
+	"github.com/moznion/logglily/logger"
+)
+
+// The names of the additional fields of the outbound request log.
+const (
+	FieldHost       = "host"
+	FieldRetries    = "retries"
+	FieldError      = "error"
+	FieldErrorClass = "error_class"
+)
+
+// The classes of the transport errors.
+const (
+	ErrorClassTimeout           = "timeout"
+	ErrorClassCanceled          = "canceled"
+	ErrorClassDNS               = "dns"
+	ErrorClassConnectionRefused = "connection_refused"
+	ErrorClassConnectionReset   = "connection_reset"
+	ErrorClassTLS               = "tls"
+	ErrorClassOther             = "other"
+)
+
+// Transport is the `http.RoundTripper` that logs each outbound request through the sink, e.g.
+//
+//	{"duration_ms":12.3,"host":"api.example.com","message":"GET api.example.com/users 200","method":"GET","path":"/users","retries":0,"status":200}
+//	{"duration_ms":3000.1,"error":"...","error_class":"timeout","host":"api.example.com","message":"GET api.example.com/users failed","method":"GET","path":"/users","retries":2}
+//
+// This only logs the requests; that doesn't retry nor modify them.
+// The retries that the caller does are counted if the requests have the context of TrackRetries().
+//
+// The requests that loggers of logglily send are never logged, so this is safe to be used as http.DefaultTransport
+// even if the loggers use http.DefaultClient. Those are the requests to the ingestion API of loggly (logs-01.loggly.com)
+// and the ones that are marked by api.MarkRequest().
+// If the custom api.Client sends the logs to somewhere else, please mark the requests, or the transport logs its own logging recursively.
+//
+// NOTE: the setters are not goroutine-safe. Please configure the transport before use.
+type Transport struct {
+	base http.RoundTripper
+	sink logger.Sink
+	skip func(req *http.Request) bool
+}
+
+// NewTransport creates a new Transport that wraps the base. If the base is nil, http.DefaultTransport is used.
+func NewTransport(base http.RoundTripper, sink logger.Sink) *Transport {
+	return &Transport{
+		base: base,
+		sink: sink,
+	}
+}
+
+// SetSkip sets the predicate that reports whether the request is not logged.
+func (t *Transport) SetSkip(skip func(req *http.Request) bool) {
+	t.skip = skip
+}
+
+// RoundTrip sends the request through the base and logs that.
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if internalAPI.IsInternalRequest(req) || isLogglyRequest(req) || (t.skip != nil && t.skip(req)) {
+		return t.roundTripper().RoundTrip(req)
+	}
+
+	retries := countAttempt(req)
+	start := time.Now()
+	resp, err := t.roundTripper().RoundTrip(req)
+	t.log(req, resp, retries, err, time.Since(start))
+	return resp, err
+}
+
+type retryCounterKey struct{}
+
+// retryCounter counts the attempts of each method and URL; the redirects are not counted as the retries.
+type retryCounter struct {
+	mu       sync.Mutex
+	attempts map[string]int
+}
+
+// TrackRetries returns the context that makes Transport count the attempts of the requests that have the context,
+// and log the number of the attempts except the first one as FieldRetries. Please use the context through the retries
+// of a logical request, e.g.
+//
+//	ctx := httplog.TrackRetries(context.Background())
+//	for attempt := 0; attempt < 3; attempt++ {
+//		resp, err := client.Do(req.WithContext(ctx))
+//		...
+//	}
+//
+// The requests without the context are logged with 0 retries.
+func TrackRetries(ctx context.Context) context.Context {
+	return context.WithValue(ctx, retryCounterKey{}, &retryCounter{attempts: make(map[string]int)})
+}
+
+// countAttempt counts the attempt of the request, and returns the number of the previous attempts.
+func countAttempt(req *http.Request) int {
+	counter, ok := req.Context().Value(retryCounterKey{}).(*retryCounter)
+	if !ok {
+		return 0
+	}
+	key := req.Method + " " + req.URL.String()
+
+	counter.mu.Lock()
+	defer counter.mu.Unlock()
+	retries := counter.attempts[key]
+	counter.attempts[key]++
+	return retries
+}
+
+func (t *Transport) roundTripper() http.RoundTripper {
+	if t.base == nil {
+		return http.DefaultTransport
+	}
+	return t.base
+}
+
+// isLogglyRequest reports whether the request is sent to the ingestion API of loggly, e.g. by the custom api.Client that doesn't mark that.
+// The other requests to loggly (e.g. the retrieval API) are logged.
+func isLogglyRequest(req *http.Request) bool {
+	if req.URL.Hostname() != "logs-01.loggly.com" {
+		return false
+	}
+	return strings.HasPrefix(req.URL.Path, "/inputs/") || strings.HasPrefix(req.URL.Path, "/bulk/")
+}
+
+func (t *Transport) log(req *http.Request, resp *http.Response, retries int, err error, elapsed time.Duration) {
+	target := req.URL.Host + req.URL.Path
+	message := logger.Message{
+		FieldHost:     req.URL.Host,
+		FieldMethod:   req.Method,
+		FieldPath:     req.URL.Path,
+		FieldRetries:  retries,
+		FieldDuration: float64(elapsed) / float64(time.Millisecond),
+	}
+	if err != nil {
+		message["message"] = fmt.Sprintf("%s %s failed", req.Method, target)
+		message[FieldError] = err.Error()
+		message[FieldErrorClass] = classifyError(err)
+	} else {
+		message["message"] = fmt.Sprintf("%s %s %d", req.Method, target, resp.StatusCode)
+		message[FieldStatus] = resp.StatusCode
+	}
+
+	t.sink.Send(message)
+}
+
+// classifyError classifies the transport error into the coarse classes to be aggregated easily.
+func classifyError(err error) string {
+	var dnsErr *net.DNSError
+	var netErr net.Error
+	var certErr x509.CertificateInvalidError
+	var unknownAuthorityErr x509.UnknownAuthorityError
+	var hostnameErr x509.HostnameError
+	var recordHeaderErr tls.RecordHeaderError
+
+	switch {
+	case errors.Is(err, context.Canceled):
+		return ErrorClassCanceled
+	case errors.Is(err, context.DeadlineExceeded):
+		return ErrorClassTimeout
+	case errors.As(err, &dnsErr):
+		return ErrorClassDNS
+	case errors.Is(err, syscall.ECONNREFUSED):
+		return ErrorClassConnectionRefused
+	case errors.Is(err, syscall.ECONNRESET):
+		return ErrorClassConnectionReset
+	case errors.As(err, &certErr), errors.As(err, &unknownAuthorityErr), errors.As(err, &hostnameErr), errors.As(err, &recordHeaderErr):
+		return ErrorClassTLS
+	case errors.As(err, &netErr) && netErr.Timeout():
+		return ErrorClassTimeout
+	case strings.Contains(err.Error(), "tls:"):
+		return ErrorClassTLS
+	}
+	return ErrorClassOther
+}
@@ -0,0 +1,232 @@
+package httplog
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/moznion/logglily/api"
+	"github.com/moznion/logglily/logger"
+)
+
+func TestTransportShouldLogOutboundRequest(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+	}))
+	defer server.Close()
+
+	sink := &recordingSink{}
+	client := &http.Client{Transport: NewTransport(nil, sink)}
+	resp, err := client.Get(server.URL + "/users")
+	if err != nil {
+		t.Fatal("unexpected err", err)
+	}
+	resp.Body.Close()
+
+	if len(sink.messages) != 1 {
+		t.Fatalf("len(messages) == %d", len(sink.messages))
+	}
+	m := sink.messages[0]
+	host := strings.TrimPrefix(server.URL, "http://")
+	if m["message"] != "GET "+host+"/users 418" || m[FieldHost] != host || m[FieldStatus] != 418 || m[FieldRetries] != 0 {
+		t.Errorf("message == %v", m)
+	}
+	if _, ok := m[FieldDuration].(float64); !ok {
+		t.Errorf("duration is missing: %v", m)
+	}
+}
+
+func TestTransportShouldClassifyErrors(t *testing.T) {
+	listener, _ := net.Listen("tcp", "127.0.0.1:0")
+	addr := listener.Addr().String()
+	listener.Close()
+
+	sink := &recordingSink{}
+	client := &http.Client{Transport: NewTransport(nil, sink)}
+	if _, err := client.Get("http://" + addr + "/"); err == nil {
+		t.Fatal("should be error")
+	}
+	if m := sink.messages[0]; m[FieldErrorClass] != ErrorClassConnectionRefused || m["message"] != "GET "+addr+"/ failed" {
+		t.Errorf("message == %v", m)
+	}
+
+	cases := map[error]string{
+		context.Canceled:                              ErrorClassCanceled,
+		context.DeadlineExceeded:                      ErrorClassTimeout,
+		&net.DNSError{Err: "no such host"}:            ErrorClassDNS,
+		errors.New("tls: handshake failure"):          ErrorClassTLS,
+		errors.New("something went wrong"):            ErrorClassOther,
+		&net.OpError{Op: "dial", Err: timeoutError{}}: ErrorClassTimeout,
+	}
+	for err, expected := range cases {
+		if got := classifyError(err); got != expected {
+			t.Errorf("classifyError(%v) == %s but wants %s", err, got, expected)
+		}
+	}
+}
+
+type timeoutError struct{}
+
+func (timeoutError) Error() string   { return "i/o timeout" }
+func (timeoutError) Timeout() bool   { return true }
+func (timeoutError) Temporary() bool { return true }
+
+func TestTransportShouldNotLogRequestsOfLoggers(t *testing.T) {
+	var received int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&received, 1)
+		w.Write([]byte("OK"))
+	}))
+	defer server.Close()
+
+	l := logger.NewSyncLogger([]string{"test-tag"}, "test-token", true)
+	transport := NewTransport(rewriteToServer(server), l)
+	l.APIClient.SetHTTPClient(&http.Client{Transport: transport})
+
+	// The logger sends through the transport that logs through the logger
+	client := &http.Client{Transport: transport}
+	resp, err := client.Get("http://example.com/")
+	if err != nil {
+		t.Fatal("unexpected err", err)
+	}
+	resp.Body.Close()
+
+	if got := atomic.LoadInt32(&received); got != 2 {
+		t.Errorf("received == %d but wants 2 (the request and its log)", got)
+	}
+}
+
+type rewritingRoundTripper struct {
+	host string
+}
+
+func (rt rewritingRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	req.URL.Scheme = "http"
+	req.URL.Host = rt.host
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+func rewriteToServer(server *httptest.Server) http.RoundTripper {
+	return rewritingRoundTripper{host: strings.TrimPrefix(server.URL, "http://")}
+}
+
+// customClient is the api.Client that doesn't use the client of logglily.
+type customClient struct {
+	client *http.Client
+	url    string
+	mark   bool
+}
+
+func (c *customClient) Log(body []byte) (*http.Response, error) {
+	req, _ := http.NewRequest("POST", c.url, bytes.NewReader(body))
+	if c.mark {
+		req = api.MarkRequest(req)
+	}
+	return c.client.Do(req)
+}
+
+func (c *customClient) LogAsBulk(body []byte) (*http.Response, error) {
+	return c.Log(body)
+}
+
+func (c *customClient) SetHTTPClient(client *http.Client) {
+	c.client = client
+}
+
+func TestTransportShouldNotLogRequestsOfCustomClients(t *testing.T) {
+	var received int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&received, 1)
+		w.Write([]byte("OK"))
+	}))
+	defer server.Close()
+
+	cases := []*customClient{
+		{url: "https://logs-01.loggly.com/inputs/test-token/tag/test-tag/"},
+		{url: "http://collector.example.com/", mark: true},
+	}
+	for _, c := range cases {
+		atomic.StoreInt32(&received, 0)
+		l := logger.NewSyncLogger([]string{"test-tag"}, "test-token", true)
+		transport := NewTransport(rewriteToServer(server), l)
+		c.client = &http.Client{Transport: transport}
+		l.APIClient = c
+
+		resp, err := (&http.Client{Transport: transport}).Get("http://example.com/")
+		if err != nil {
+			t.Fatal("unexpected err", err)
+		}
+		resp.Body.Close()
+
+		if got := atomic.LoadInt32(&received); got != 2 {
+			t.Errorf("received == %d but wants 2 (the request and its log) for %s", got, c.url)
+		}
+	}
+}
+
+func TestTransportShouldLogRequestsToRetrievalAPI(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("{}"))
+	}))
+	defer server.Close()
+
+	sink := &recordingSink{}
+	client := &http.Client{Transport: NewTransport(rewriteToServer(server), sink)}
+	for _, url := range []string{
+		"https://example.loggly.com/apiv2/search?q=*",
+		"https://logs-01.loggly.com/inputs/test-token/tag/test-tag/",
+	} {
+		resp, err := client.Get(url)
+		if err != nil {
+			t.Fatal("unexpected err", err)
+		}
+		resp.Body.Close()
+	}
+
+	if len(sink.messages) != 1 || sink.messages[0][FieldHost] != "example.loggly.com" {
+		t.Errorf("messages == %v", sink.messages)
+	}
+}
+
+func TestTransportShouldLogRetriesOfTrackedRequest(t *testing.T) {
+	var received int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&received, 1) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer server.Close()
+
+	sink := &recordingSink{}
+	client := &http.Client{Transport: NewTransport(nil, sink)}
+	ctx := TrackRetries(context.Background())
+	for attempt := 0; attempt < 3; attempt++ {
+		req, _ := http.NewRequest("GET", server.URL+"/users", nil)
+		resp, err := client.Do(req.WithContext(ctx))
+		if err != nil {
+			t.Fatal("unexpected err", err)
+		}
+		resp.Body.Close()
+	}
+	resp, err := client.Get(server.URL + "/users")
+	if err != nil {
+		t.Fatal("unexpected err", err)
+	}
+	resp.Body.Close()
+
+	if len(sink.messages) != 4 {
+		t.Fatalf("len(messages) == %d", len(sink.messages))
+	}
+	for i, expected := range []int{0, 1, 2, 0} {
+		if sink.messages[i][FieldRetries] != expected {
+			t.Errorf("retries of #%d == %v but wants %d", i, sink.messages[i][FieldRetries], expected)
+		}
+	}
+}
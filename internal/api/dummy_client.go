@@ -72,3 +72,24 @@ func (c *DummyHTTPFailClient) LogAsBulk(text []byte) (*http.Response, error) {
 func (c *DummyHTTPFailClient) SetHTTPClient(client *http.Client) {
 	// NOP
 }
+
+type DummyNopClient struct {
+}
+
+func (c *DummyNopClient) Log(text []byte) (*http.Response, error) {
+	return &http.Response{
+		StatusCode: 200,
+		Body:       ioutil.NopCloser(strings.NewReader("OK")),
+	}, nil
+}
+
+func (c *DummyNopClient) LogAsBulk(text []byte) (*http.Response, error) {
+	return &http.Response{
+		StatusCode: 200,
+		Body:       ioutil.NopCloser(strings.NewReader("OK")),
+	}, nil
+}
+
+func (c *DummyNopClient) SetHTTPClient(client *http.Client) {
+	// NOP
+}
@@ -1,6 +1,9 @@
 package api
 
-import "fmt"
+import (
+	"fmt"
+	"strings"
+)
 
 func buildEventAPIEndpoint(tag string, token string, isHTTPS bool) string {
 	return buildAPIEndpoint("logs-01.loggly.com/inputs/%s/tag/%s/", tag, token, isHTTPS)
@@ -18,3 +21,8 @@ func buildAPIEndpoint(baseResource string, tag string, token string, isHTTPS boo
 
 	return fmt.Sprintf(protocol+"://"+baseResource, token, tag)
 }
+
+// buildEndpointWithBaseURL builds the endpoint on the base URL, e.g. "http://localhost:8080" for the local proxy.
+func buildEndpointWithBaseURL(baseURL string, kind string, tag string, token string) string {
+	return fmt.Sprintf("%s/%s/%s/tag/%s/", strings.TrimRight(baseURL, "/"), kind, token, tag)
+}
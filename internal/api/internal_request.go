@@ -0,0 +1,20 @@
+package api
+
+import (
+	"context"
+	"net/http"
+)
+
+type internalRequestKey struct{}
+
+// MarkInternalRequest returns the request that is marked as the request of logglily itself.
+// The outbound logging (e.g. httplog.Transport) doesn't log the marked requests, or that logs its own calls recursively.
+func MarkInternalRequest(req *http.Request) *http.Request {
+	return req.WithContext(context.WithValue(req.Context(), internalRequestKey{}, true))
+}
+
+// IsInternalRequest reports whether the request is marked by MarkInternalRequest().
+func IsInternalRequest(req *http.Request) bool {
+	marked, _ := req.Context().Value(internalRequestKey{}).(bool)
+	return marked
+}
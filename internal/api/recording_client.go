@@ -0,0 +1,134 @@
+package api
+
+import (
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// RecordingClient is a client for the tests, which records the payloads that are accepted.
+//
+// The response is 200 by default; it is configurable by Status and Err, or by Respond for each payload.
+// The clients that are created by Labeled() share the records and the responses of this client,
+// e.g. to record the payloads of the clients that are created for each tags in the order of sending.
+type RecordingClient struct {
+	// Status is the status code of the responses. It is 200 if that is 0.
+	Status int
+	// Err is returned without the response if that is not nil.
+	Err error
+	// Respond decides the status code and the error of each payload instead of Status and Err if that is set.
+	// It is called without holding the lock of the client, so it can block.
+	Respond func(body []byte) (int, error)
+
+	mu      sync.Mutex
+	root    *RecordingClient
+	label   string
+	records []record
+}
+
+type record struct {
+	label string
+	body  string
+	bulk  bool
+}
+
+// Labeled returns a client that records the payloads into this client with the label.
+func (c *RecordingClient) Labeled(label string) *RecordingClient {
+	return &RecordingClient{root: c.rootClient(), label: label}
+}
+
+func (c *RecordingClient) Log(body []byte) (*http.Response, error) {
+	return c.respond(body, false)
+}
+
+func (c *RecordingClient) LogAsBulk(body []byte) (*http.Response, error) {
+	return c.respond(body, true)
+}
+
+func (c *RecordingClient) SetHTTPClient(client *http.Client) {
+}
+
+// Events returns the payloads that are accepted by Log(). The labeled ones are prefixed by the label and a space.
+func (c *RecordingClient) Events() []string {
+	return c.bodies(false)
+}
+
+// Bulks returns the payloads that are accepted by LogAsBulk(). The labeled ones are prefixed by the label and a space.
+func (c *RecordingClient) Bulks() []string {
+	return c.bodies(true)
+}
+
+// Lines returns each line of the payloads that are accepted. The labeled ones are prefixed by the label and a space.
+func (c *RecordingClient) Lines() []string {
+	var lines []string
+	for _, r := range c.rootClient().snapshot() {
+		for _, line := range strings.Split(r.body, "\n") {
+			lines = append(lines, withLabel(r.label, line))
+		}
+	}
+	return lines
+}
+
+// LinesByLabel returns each line of the payloads that are accepted, grouped by the label.
+func (c *RecordingClient) LinesByLabel() map[string][]string {
+	lines := make(map[string][]string)
+	for _, r := range c.rootClient().snapshot() {
+		lines[r.label] = append(lines[r.label], strings.Split(r.body, "\n")...)
+	}
+	return lines
+}
+
+func (c *RecordingClient) respond(body []byte, bulk bool) (*http.Response, error) {
+	root := c.rootClient()
+	status, err := root.Status, root.Err
+	if root.Respond != nil {
+		status, err = root.Respond(body)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if status == 0 {
+		status = http.StatusOK
+	}
+
+	if status == http.StatusOK {
+		root.mu.Lock()
+		root.records = append(root.records, record{label: c.label, body: string(body), bulk: bulk})
+		root.mu.Unlock()
+	}
+	return &http.Response{
+		StatusCode: status,
+		Body:       ioutil.NopCloser(strings.NewReader(`{"response":"ok"}`)),
+	}, nil
+}
+
+func (c *RecordingClient) bodies(bulk bool) []string {
+	var bodies []string
+	for _, r := range c.rootClient().snapshot() {
+		if r.bulk == bulk {
+			bodies = append(bodies, withLabel(r.label, r.body))
+		}
+	}
+	return bodies
+}
+
+func (c *RecordingClient) snapshot() []record {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]record(nil), c.records...)
+}
+
+func (c *RecordingClient) rootClient() *RecordingClient {
+	if c.root != nil {
+		return c.root
+	}
+	return c
+}
+
+func withLabel(label string, body string) string {
+	if label == "" {
+		return body
+	}
+	return label + " " + body
+}
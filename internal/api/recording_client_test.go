@@ -0,0 +1,37 @@
+package api
+
+import (
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+func TestRecordingClientShouldRecordAcceptedPayloadsWithLabels(t *testing.T) {
+	client := &RecordingClient{}
+	a := client.Labeled("a")
+	client.Respond = func(body []byte) (int, error) {
+		if string(body) == "rejected" {
+			return http.StatusBadRequest, nil
+		}
+		return 0, nil
+	}
+
+	client.Log([]byte("event"))
+	a.LogAsBulk([]byte("1\n2"))
+	if resp, _ := a.LogAsBulk([]byte("rejected")); resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("got == %d but wants %d", resp.StatusCode, http.StatusBadRequest)
+	}
+
+	if got, expected := client.Events(), []string{"event"}; !reflect.DeepEqual(got, expected) {
+		t.Errorf("got == %q but wants %q", got, expected)
+	}
+	if got, expected := a.Bulks(), []string{"a 1\n2"}; !reflect.DeepEqual(got, expected) {
+		t.Errorf("got == %q but wants %q", got, expected)
+	}
+	if got, expected := client.Lines(), []string{"event", "a 1", "a 2"}; !reflect.DeepEqual(got, expected) {
+		t.Errorf("got == %q but wants %q", got, expected)
+	}
+	if got, expected := client.LinesByLabel(), map[string][]string{"": {"event"}, "a": {"1", "2"}}; !reflect.DeepEqual(got, expected) {
+		t.Errorf("got == %q but wants %q", got, expected)
+	}
+}
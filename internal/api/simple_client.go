@@ -6,6 +6,7 @@ import (
 
 	"fmt"
 
+	"strconv"
 	"strings"
 
 	"github.com/moznion/logglily/internal"
@@ -27,6 +28,16 @@ func NewSimpleClient(tags []string, token string, isHTTPS bool) *SimpleClient {
 	}
 }
 
+// NewSimpleClientWithBaseURL creates the client that sends to the base URL instead of loggly, e.g. the local proxy.
+func NewSimpleClientWithBaseURL(tags []string, token string, baseURL string) *SimpleClient {
+	tagUnit := strings.Join(tags, ",")
+	return &SimpleClient{
+		logEventAPIEndpoint: buildEndpointWithBaseURL(baseURL, "inputs", tagUnit, token),
+		logBulkAPIEndpoint:  buildEndpointWithBaseURL(baseURL, "bulk", tagUnit, token),
+		client:              http.DefaultClient,
+	}
+}
+
 func (c *SimpleClient) Log(text []byte) (*http.Response, error) {
 	return c.post(c.logEventAPIEndpoint, text)
 }
@@ -47,7 +58,7 @@ func (c *SimpleClient) post(url string, text []byte) (*http.Response, error) {
 
 	req.Header.Set("Content-Type", content_type.PlainText)
 	req.Header.Set("User-Agent", fmt.Sprintf("logglily/%s; https://github.com/moznion/logglily", internal.Version))
-	req.Header.Set("Content-Length", string(len(text)))
+	req.Header.Set("Content-Length", strconv.Itoa(len(text)))
 
-	return c.client.Do(req)
+	return c.client.Do(MarkInternalRequest(req))
 }
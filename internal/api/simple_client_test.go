@@ -2,6 +2,7 @@ package api
 
 import (
 	"fmt"
+	"net/http"
 	"testing"
 )
 
@@ -36,3 +37,49 @@ func TestInstantiateHTTPS(t *testing.T) {
 		t.Errorf("got == `%v` but wants `%v`", client.logBulkAPIEndpoint, expected)
 	}
 }
+
+func TestInstantiateWithBaseURL(t *testing.T) {
+	client := NewSimpleClientWithBaseURL([]string{"a", "b"}, "testToken", "http://localhost:8080/")
+
+	expected := "http://localhost:8080/bulk/testToken/tag/a,b/"
+	if client.logBulkAPIEndpoint != expected {
+		t.Errorf("got == `%v` but wants `%v`", client.logBulkAPIEndpoint, expected)
+	}
+
+	expected = "http://localhost:8080/inputs/testToken/tag/a,b/"
+	if client.logEventAPIEndpoint != expected {
+		t.Errorf("got == `%v` but wants `%v`", client.logEventAPIEndpoint, expected)
+	}
+}
+
+type recordingRoundTripper struct {
+	requests []*http.Request
+}
+
+func (rt *recordingRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	rt.requests = append(rt.requests, req)
+	return &http.Response{StatusCode: 200, Body: http.NoBody}, nil
+}
+
+func TestSimpleClientShouldMarkRequestsAsInternal(t *testing.T) {
+	rt := &recordingRoundTripper{}
+	client := NewSimpleClient([]string{"test"}, "testToken", true)
+	client.SetHTTPClient(&http.Client{Transport: rt})
+
+	client.Log([]byte("{}"))
+	client.LogAsBulk([]byte("{}"))
+
+	if len(rt.requests) != 2 {
+		t.Fatalf("len(requests) == %d", len(rt.requests))
+	}
+	for _, req := range rt.requests {
+		if !IsInternalRequest(req) {
+			t.Errorf("request should be marked as internal: %s", req.URL)
+		}
+	}
+
+	req, _ := http.NewRequest("GET", "http://example.com/", nil)
+	if IsInternalRequest(req) {
+		t.Error("request should not be marked as internal")
+	}
+}
@@ -0,0 +1,115 @@
+package ratelimit
+
+import (
+	"sync"
+	"time"
+)
+
+// TokenBucket is a token bucket that is refilled at `rate` tokens per second up to `burst` tokens.
+//
+// A cost that is larger than the burst is capped to the burst; otherwise that never passes.
+type TokenBucket struct {
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+	clock  func() time.Time
+	mutex  *sync.Mutex
+}
+
+func NewTokenBucket(rate float64, burst float64) *TokenBucket {
+	if burst <= 0 {
+		burst = rate
+	}
+	if burst < 1 {
+		burst = 1
+	}
+
+	return &TokenBucket{
+		rate:   rate,
+		burst:  burst,
+		tokens: burst,
+		last:   time.Now(),
+		clock:  time.Now,
+		mutex:  &sync.Mutex{},
+	}
+}
+
+func (b *TokenBucket) SetClock(clock func() time.Time) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	b.clock = clock
+	b.last = clock()
+}
+
+func (b *TokenBucket) Rate() float64 {
+	return b.rate
+}
+
+// Allow takes the tokens if the bucket has enough tokens.
+func (b *TokenBucket) Allow(cost float64) bool {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	b.refill()
+	cost = b.capCost(cost)
+	if b.tokens < cost {
+		return false
+	}
+	b.tokens -= cost
+	return true
+}
+
+// Reserve takes the tokens even if the bucket doesn't have enough tokens,
+// and returns the duration to wait until the tokens are available.
+func (b *TokenBucket) Reserve(cost float64) time.Duration {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	b.refill()
+	b.tokens -= b.capCost(cost)
+	if b.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-b.tokens / b.rate * float64(time.Second))
+}
+
+// Wait blocks until the tokens are available, and takes them.
+func (b *TokenBucket) Wait(cost float64) {
+	if d := b.Reserve(cost); d > 0 {
+		time.Sleep(d)
+	}
+}
+
+// Return gives back the tokens that are taken but not used.
+func (b *TokenBucket) Return(cost float64) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	b.tokens += b.capCost(cost)
+	if b.tokens > b.burst {
+		b.tokens = b.burst
+	}
+}
+
+func (b *TokenBucket) refill() {
+	now := b.clock()
+	elapsed := now.Sub(b.last).Seconds()
+	b.last = now
+	if elapsed <= 0 {
+		return
+	}
+
+	b.tokens += elapsed * b.rate
+	if b.tokens > b.burst {
+		b.tokens = b.burst
+	}
+}
+
+func (b *TokenBucket) capCost(cost float64) float64 {
+	if cost > b.burst {
+		return b.burst
+	}
+	return cost
+}
@@ -0,0 +1,60 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTokenBucketAllow(t *testing.T) {
+	now := time.Date(2018, 1, 5, 17, 11, 25, 0, time.UTC)
+	b := NewTokenBucket(2, 2)
+	b.SetClock(func() time.Time {
+		return now
+	})
+
+	if !b.Allow(1) || !b.Allow(1) {
+		t.Error("burst should be allowed")
+	}
+	if b.Allow(1) {
+		t.Error("bucket should be empty")
+	}
+
+	now = now.Add(500 * time.Millisecond)
+	if !b.Allow(1) {
+		t.Error("bucket should be refilled")
+	}
+	if b.Allow(1) {
+		t.Error("bucket should be empty")
+	}
+
+	now = now.Add(10 * time.Second)
+	if !b.Allow(100) {
+		t.Error("cost should be capped to the burst")
+	}
+	if b.Allow(1) {
+		t.Error("bucket should be empty")
+	}
+
+	b.Return(1)
+	if !b.Allow(1) {
+		t.Error("returned token should be available")
+	}
+}
+
+func TestTokenBucketReserve(t *testing.T) {
+	now := time.Date(2018, 1, 5, 17, 11, 25, 0, time.UTC)
+	b := NewTokenBucket(10, 1)
+	b.SetClock(func() time.Time {
+		return now
+	})
+
+	if d := b.Reserve(1); d != 0 {
+		t.Errorf("d == %v but wants %v", d, 0)
+	}
+	if d := b.Reserve(1); d != 100*time.Millisecond {
+		t.Errorf("d == %v but wants %v", d, 100*time.Millisecond)
+	}
+	if d := b.Reserve(1); d != 200*time.Millisecond {
+		t.Errorf("d == %v but wants %v", d, 200*time.Millisecond)
+	}
+}
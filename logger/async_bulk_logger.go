@@ -5,8 +5,6 @@ import (
 
 	"bytes"
 
-	"encoding/json"
-
 	"time"
 
 	"errors"
@@ -40,6 +38,8 @@ type AsyncBulkLogger struct {
 	active                 bool
 	flushTickerStoppedChan chan struct{}
 	stopFlushTickerChan    chan struct{}
+	sendErrorHandler       func(err error, failedMessages [][]byte)
+	encoder
 }
 
 // NewAsyncBulkLogger creates an instance of AsyncBulkLogger.
@@ -88,7 +88,15 @@ func (l *AsyncBulkLogger) Log(message Message) (*AsyncBulkResult, error) {
 		}, err
 	}
 
-	body, err := json.Marshal(message)
+	body, err := l.encodeMessage(message)
+	if err == errMessageDropped {
+		asyncErrChan <- nil
+		failedMessagesChan <- nil
+		return &AsyncBulkResult{
+			AsyncErrChan:       asyncErrChan,
+			FailedMessagesChan: failedMessagesChan,
+		}, nil
+	}
 	if err != nil {
 		asyncErrChan <- err
 		failedMessagesChan <- nil
@@ -108,12 +116,197 @@ func (l *AsyncBulkLogger) Log(message Message) (*AsyncBulkResult, error) {
 	}, nil
 }
 
+// Send logs the message into loggly as a bulk asynchronously.
+//
+// This method is the same as Log() but it returns only the foreground error;
+// the result of the background processing and the failed messages are discarded
+// unless the handler is set by SetSendErrorHandler().
+func (l *AsyncBulkLogger) Send(message Message) error {
+	result, err := l.Log(message)
+	l.handleSendResult(result)
+	return err
+}
+
+// SetSendErrorHandler sets the handler that is called with the error and the failed messages of Send(),
+// including the errors of the background processing.
+// It is called from the background goroutines. Please set this before starting logging.
+func (l *AsyncBulkLogger) SetSendErrorHandler(handler func(err error, failedMessages [][]byte)) {
+	l.sendErrorHandler = handler
+}
+
+// LogRaw logs the message that is already encoded (e.g. a JSON line) into loggly as a bulk asynchronously.
+//
+// The body is sent as it is; the timestamper, the processors and the other encoding settings are not applied.
+// The body must not contain the newline because that separates the messages in the bulk payload,
+// and the caller must not modify the body after calling this.
+func (l *AsyncBulkLogger) LogRaw(body []byte) (*AsyncBulkResult, error) {
+	asyncErrChan := make(chan error, 1)
+	failedMessagesChan := make(chan [][]byte, 1)
+
+	var err error
+	if !l.active {
+		err = errors.New("in progress to shutdown. refused the message")
+	} else if bytes.IndexByte(body, '\n') >= 0 {
+		err = errors.New("raw message must not contain the newline")
+	}
+	if err != nil {
+		asyncErrChan <- err
+		failedMessagesChan <- nil
+		return &AsyncBulkResult{
+			AsyncErrChan:       asyncErrChan,
+			FailedMessagesChan: failedMessagesChan,
+		}, err
+	}
+
+	go func() {
+		l.post(body, asyncErrChan, failedMessagesChan)
+	}()
+
+	return &AsyncBulkResult{
+		AsyncErrChan:       asyncErrChan,
+		FailedMessagesChan: failedMessagesChan,
+	}, nil
+}
+
+// LogBatch logs the messages into loggly as a bulk, in the order of the slice.
+//
+// This method encodes all of the messages first; if any of them cannot be encoded, it returns the error and logs nothing.
+// The other behaviors are the same as LogRawBatch().
+func (l *AsyncBulkLogger) LogBatch(messages []Message) (*AsyncBulkResult, error) {
+	bodies := make([][]byte, 0, len(messages))
+	for _, message := range messages {
+		body, err := l.encodeMessage(message)
+		if err == errMessageDropped {
+			continue
+		}
+		if err != nil {
+			asyncErrChan := make(chan error, 1)
+			failedMessagesChan := make(chan [][]byte, 1)
+			asyncErrChan <- err
+			failedMessagesChan <- nil
+			return &AsyncBulkResult{
+				AsyncErrChan:       asyncErrChan,
+				FailedMessagesChan: failedMessagesChan,
+			}, err
+		}
+		bodies = append(bodies, body)
+	}
+	return l.LogRawBatch(bodies)
+}
+
+// LogRawBatch logs the messages that are already encoded into loggly as a bulk, in the order of the slice.
+//
+// Unlike LogRaw(), this method buffers the messages synchronously on the caller's goroutine,
+// so the order is kept and it doesn't spawn a goroutine per message.
+// It means this method blocks while calling the API when the buffer exceeds bulkSizeThreshold.
+// The returned result has already been completed: the error is the first one that occurred,
+// and the failed messages are all of the messages that are failed to log.
+//
+// The restrictions of LogRaw() are applied to each body. If any of them is invalid, this logs nothing.
+func (l *AsyncBulkLogger) LogRawBatch(bodies [][]byte) (*AsyncBulkResult, error) {
+	asyncErrChan := make(chan error, 1)
+	failedMessagesChan := make(chan [][]byte, 1)
+
+	var err error
+	if !l.active {
+		err = errors.New("in progress to shutdown. refused the message")
+	} else {
+		for _, body := range bodies {
+			if bytes.IndexByte(body, '\n') >= 0 {
+				err = errors.New("raw message must not contain the newline")
+				break
+			}
+		}
+	}
+	if err != nil {
+		asyncErrChan <- err
+		failedMessagesChan <- nil
+		return &AsyncBulkResult{
+			AsyncErrChan:       asyncErrChan,
+			FailedMessagesChan: failedMessagesChan,
+		}, err
+	}
+
+	var asyncErr error
+	var failedMessages [][]byte
+	for _, body := range bodies {
+		l.post(body, asyncErrChan, failedMessagesChan)
+		if err := <-asyncErrChan; err != nil && asyncErr == nil {
+			asyncErr = err
+		}
+		failedMessages = append(failedMessages, <-failedMessagesChan...)
+	}
+
+	asyncErrChan <- asyncErr
+	failedMessagesChan <- failedMessages
+	return &AsyncBulkResult{
+		AsyncErrChan:       asyncErrChan,
+		FailedMessagesChan: failedMessagesChan,
+	}, nil
+}
+
+// With returns a child logger that merges the fields into each message and sends that through this logger.
+func (l *AsyncBulkLogger) With(fields Message) *BoundLogger {
+	return NewBoundLogger(l, fields)
+}
+
+// LogFields logs the fields into loggly as a bulk asynchronously.
+//
+// This method encodes the fields into the pooled buffer directly, without building an intermediate Message.
+// The other behaviors are the same as Log().
+func (l *AsyncBulkLogger) LogFields(fields ...Field) (*AsyncBulkResult, error) {
+	asyncErrChan := make(chan error, 1)
+	failedMessagesChan := make(chan [][]byte, 1)
+
+	if !l.active {
+		err := errors.New("in progress to shutdown. refused the message")
+		asyncErrChan <- err
+		failedMessagesChan <- nil
+		return &AsyncBulkResult{
+			AsyncErrChan:       asyncErrChan,
+			FailedMessagesChan: failedMessagesChan,
+		}, err
+	}
+
+	buf := getBuffer()
+	var err error
+	buf.bs, err = l.appendFields(buf.bs, fields)
+	if err == errMessageDropped {
+		putBuffer(buf)
+		asyncErrChan <- nil
+		failedMessagesChan <- nil
+		return &AsyncBulkResult{
+			AsyncErrChan:       asyncErrChan,
+			FailedMessagesChan: failedMessagesChan,
+		}, nil
+	}
+	if err != nil {
+		putBuffer(buf)
+		asyncErrChan <- err
+		failedMessagesChan <- nil
+		return &AsyncBulkResult{
+			AsyncErrChan:       asyncErrChan,
+			FailedMessagesChan: failedMessagesChan,
+		}, err
+	}
+
+	body := detachBuffer(buf)
+	go func() {
+		l.post(body, asyncErrChan, failedMessagesChan)
+	}()
+
+	return &AsyncBulkResult{
+		AsyncErrChan:       asyncErrChan,
+		FailedMessagesChan: failedMessagesChan,
+	}, nil
+}
+
 // Flush flushes remained messages that are in the buffer.
 func (l *AsyncBulkLogger) Flush() *AsyncBulkResult {
 	asyncErrChan := make(chan error, 1)
 	failedMessagesChan := make(chan [][]byte, 1)
 
-	go l.flush(asyncErrChan, failedMessagesChan, l.bufferInitializer)
+	go l.flushBuffer(asyncErrChan, failedMessagesChan)
 
 	return &AsyncBulkResult{
 		AsyncErrChan:       asyncErrChan,
@@ -131,7 +324,7 @@ func (l *AsyncBulkLogger) Shutdown() *AsyncBulkResult {
 		l.stopFlushTickerChan <- notifier
 		<-l.flushTickerStoppedChan
 
-		l.flush(asyncErrorChan, failedMessageChan, l.bufferInitializer)
+		l.flushBuffer(asyncErrorChan, failedMessageChan)
 	}()
 
 	return &AsyncBulkResult{
@@ -140,6 +333,32 @@ func (l *AsyncBulkLogger) Shutdown() *AsyncBulkResult {
 	}
 }
 
+func (l *AsyncBulkLogger) sendEncoded(body []byte) error {
+	result, err := l.LogRaw(body)
+	l.handleSendResult(result)
+	return err
+}
+
+func (l *AsyncBulkLogger) handleSendResult(result *AsyncBulkResult) {
+	if l.sendErrorHandler == nil {
+		return
+	}
+	handler := l.sendErrorHandler
+	go func() {
+		if asyncErr := <-result.AsyncErrChan; asyncErr != nil {
+			handler(asyncErr, <-result.FailedMessagesChan)
+		}
+	}()
+}
+
+// detachLogs hands the buffered messages over to the caller.
+// The buffer must not share its backing array with the returned one, or following Log() overwrites that.
+func (l *AsyncBulkLogger) detachLogs() [][]byte {
+	logs := l.logs
+	l.logs = nil
+	return logs
+}
+
 func (l *AsyncBulkLogger) bufferInitializer() {
 	l.logs = l.logs[:0]
 	l.currentPayloadSize = 0
@@ -162,7 +381,7 @@ func (l *AsyncBulkLogger) startPeriodicallyFlushing(flushIntervalMillis int) {
 				// TODO: Should it be notifier channel that connect to outer?
 				errChan := make(chan error, 1)
 				failedMessageChan := make(chan [][]byte, 1)
-				l.flush(errChan, failedMessageChan, l.bufferInitializer)
+				l.flushBuffer(errChan, failedMessageChan)
 			case <-l.stopFlushTickerChan:
 				break loop
 			}
@@ -172,6 +391,13 @@ func (l *AsyncBulkLogger) startPeriodicallyFlushing(flushIntervalMillis int) {
 	}()
 }
 
+// flushBuffer flushes the buffer with holding the mutex that post() holds, so that it doesn't race with Log().
+func (l *AsyncBulkLogger) flushBuffer(errChan chan error, failedMessageChan chan [][]byte) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	l.flush(errChan, failedMessageChan, l.bufferInitializer)
+}
+
 func (l *AsyncBulkLogger) flush(errChan chan error, failedMessageChan chan [][]byte, bufferSweeper func()) {
 	l.flushMutex.Lock()
 	defer l.flushMutex.Unlock()
@@ -188,14 +414,14 @@ func (l *AsyncBulkLogger) flush(errChan chan error, failedMessageChan chan [][]b
 	resp, err := l.APIClient.LogAsBulk(payload)
 	if err != nil {
 		errChan <- err
-		failedMessageChan <- l.logs
+		failedMessageChan <- l.detachLogs()
 		return
 	}
 	defer resp.Body.Close()
 
 	if err := checkHTTPResponse(resp); err != nil {
 		errChan <- err
-		failedMessageChan <- l.logs
+		failedMessageChan <- l.detachLogs()
 		return
 	}
 
@@ -304,3 +304,151 @@ func TestAsyncBulkLogger_DisabledPeriodicallyFlushing(t *testing.T) {
 		t.Error("l.currentPayloadSize should not be 0 but come 0")
 	}
 }
+
+func TestAsyncBulkLoggerLogFieldsShouldBeSuccessfully(t *testing.T) {
+	l, _ := NewAsyncBulkLogger([]string{"test-tag"}, "test-token", true, 215, 0)
+	l.APIClient = &api.DummyNopClient{}
+
+	result, err := l.LogFields(String("Message", "msg1"), String("From", "john"))
+	if err != nil {
+		t.Error("unexpected err", err)
+	}
+	<-result.AsyncErrChan
+
+	l.mutex.Lock()
+	logs := l.logs
+	l.mutex.Unlock()
+	expected := `{"Message":"msg1","From":"john"}`
+	if len(logs) != 1 || string(logs[0]) != expected {
+		t.Errorf("logs == %q but wants [%q]", logs, expected)
+	}
+	// the buffered message doesn't keep the capacity of the pooled buffer
+	if cap(logs[0]) != len(expected) {
+		t.Errorf("cap(logs[0]) == %d but wants %d", cap(logs[0]), len(expected))
+	}
+
+	result = l.Flush()
+	if err := <-result.AsyncErrChan; err != nil {
+		t.Error("unexpected err", err)
+	}
+	l.mutex.Lock()
+	size := len(l.logs)
+	l.mutex.Unlock()
+	if size != 0 {
+		t.Errorf("len(l.logs) == %d but wants %d", size, 0)
+	}
+
+	result, err = l.LogFields(Object("invalid", make(chan int)))
+	if err == nil {
+		t.Error("err should not be nil, but got nil")
+	}
+	if failedMessages := <-result.FailedMessagesChan; failedMessages != nil {
+		t.Errorf("failedMessages == %v but wants nil", failedMessages)
+	}
+}
+
+func TestAsyncBulkLoggerLogRawShouldBufferBodyAsItIs(t *testing.T) {
+	l, err := NewAsyncBulkLogger([]string{"test-tag"}, "test-token", true, 215, 0)
+	if err != nil {
+		t.Error("unexpected err", err)
+	}
+	l.APIClient = &api.DummySuccClient{}
+
+	body := `{"b":1, "a":"not re-encoded"}`
+	result, err := l.LogRaw([]byte(body))
+	if err != nil {
+		t.Error("unexpected err", err)
+	}
+	if err := <-result.AsyncErrChan; err != nil {
+		t.Error("unexpected err", err)
+	}
+	<-result.FailedMessagesChan
+
+	if len(l.logs) != 1 || string(l.logs[0]) != body {
+		t.Errorf("logs == %q but wants [%q]", l.logs, body)
+	}
+
+	if _, err := l.LogRaw([]byte("multi\nline")); err == nil {
+		t.Error("the body that contains newline should be refused")
+	}
+}
+
+func TestAsyncBulkLoggerSendShouldNotifyFailedMessagesToHandler(t *testing.T) {
+	l, _ := NewAsyncBulkLogger([]string{"test-tag"}, "test-token", true, 215, 10000000)
+	l.APIClient = &api.DummyErrClient{}
+
+	handled := make(chan [][]byte, 3)
+	l.SetSendErrorHandler(func(err error, failedMessages [][]byte) {
+		if err == nil {
+			t.Error("handler should be called with the error")
+		}
+		handled <- failedMessages
+	})
+
+	for _, msg := range []string{"msg1", "msg2", "msg3"} {
+		if err := l.Send(Message{"Message": msg, "From": "john", "timestamp": "2018-01-05T17:11:25.494Z"}); err != nil {
+			t.Error("unexpected err", err)
+		}
+	}
+	select {
+	case failedMessages := <-handled:
+		if len(failedMessages) != 2 {
+			t.Errorf("len(failedMessages) == %d but wants %d", len(failedMessages), 2)
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatal("handler is not called")
+	}
+}
+
+func TestAsyncBulkLoggerLogRawBatchShouldBufferBodiesInOrder(t *testing.T) {
+	l, err := NewAsyncBulkLogger([]string{"test-tag"}, "test-token", true, 215, 0)
+	if err != nil {
+		t.Error("unexpected err", err)
+	}
+	l.APIClient = &api.DummySuccClient{}
+
+	bodies := [][]byte{[]byte(`{"n":1}`), []byte(`{"n":2}`), []byte(`{"n":3}`)}
+	result, err := l.LogRawBatch(bodies)
+	if err != nil {
+		t.Error("unexpected err", err)
+	}
+	if err := <-result.AsyncErrChan; err != nil {
+		t.Error("unexpected err", err)
+	}
+	<-result.FailedMessagesChan
+
+	if len(l.logs) != 3 || string(l.logs[0]) != `{"n":1}` || string(l.logs[1]) != `{"n":2}` || string(l.logs[2]) != `{"n":3}` {
+		t.Errorf("logs == %q but wants %q", l.logs, bodies)
+	}
+
+	if _, err := l.LogRawBatch([][]byte{[]byte(`{"n":4}`), []byte("multi\nline")}); err == nil {
+		t.Error("the batch that contains newline should be refused")
+	}
+	if len(l.logs) != 3 {
+		t.Errorf("size of logs == %v but wants %v", len(l.logs), 3)
+	}
+}
+
+func TestAsyncBulkLoggerLogBatchShouldCollectFailedMessages(t *testing.T) {
+	l, _ := NewAsyncBulkLogger([]string{"test-tag"}, "test-token", true, 215, 0)
+	l.APIClient = &api.DummyErrClient{}
+
+	var messages []Message
+	for _, msg := range []string{"msg1", "msg2", "msg3"} {
+		messages = append(messages, Message{"Message": msg, "From": "john", "timestamp": "2018-01-05T17:11:25.494Z"})
+	}
+	result, err := l.LogBatch(messages)
+	if err != nil {
+		t.Error("unexpected err", err)
+	}
+	if err := <-result.AsyncErrChan; err == nil {
+		t.Error("err should not be nil, but got nil")
+	}
+	if failedMessages := <-result.FailedMessagesChan; len(failedMessages) != 2 {
+		t.Errorf("len(failedMessages) == %d but wants %d", len(failedMessages), 2)
+	}
+
+	if _, err := l.LogBatch([]Message{{"invalid": make(chan int)}}); err == nil {
+		t.Error("err should not be nil, but got nil")
+	}
+}
@@ -1,8 +1,6 @@
 package logger
 
 import (
-	"encoding/json"
-
 	"github.com/moznion/logglily/api"
 	internalAPI "github.com/moznion/logglily/internal/api"
 )
@@ -15,6 +13,7 @@ import (
 // *Thus if it is necessary to control the capacity of goroutines, please consider using AsyncPoolLogger.*
 type AsyncLogger struct {
 	APIClient api.Client
+	encoder
 }
 
 // NewAsyncLogger creates an instance of AsyncLogger.
@@ -38,7 +37,55 @@ func NewAsyncLogger(tags []string, token string, isHTTPS bool) *AsyncLogger {
 func (l *AsyncLogger) Log(message Message) (*AsyncResult, error) {
 	asyncErrChan := make(chan error, 1)
 
-	body, err := json.Marshal(message)
+	body, err := l.encodeMessage(message)
+	if err == errMessageDropped {
+		asyncErrChan <- nil
+		return &AsyncResult{
+			AsyncErrChan: asyncErrChan,
+		}, nil
+	}
+	if err != nil {
+		asyncErrChan <- err
+		return &AsyncResult{
+			AsyncErrChan: asyncErrChan,
+		}, err
+	}
+
+	l.log(body, asyncErrChan)
+
+	return &AsyncResult{
+		AsyncErrChan: asyncErrChan,
+	}, nil
+}
+
+// Send logs message into loggly through event API asynchronously.
+//
+// This method is the same as Log() but it returns only the foreground error;
+// the result of the background processing is not notified.
+func (l *AsyncLogger) Send(message Message) error {
+	_, err := l.Log(message)
+	return err
+}
+
+// With returns a child logger that merges the fields into each message and sends that through this logger.
+func (l *AsyncLogger) With(fields Message) *BoundLogger {
+	return NewBoundLogger(l, fields)
+}
+
+// LogFields logs the fields into loggly through event API asynchronously.
+//
+// This method encodes the fields without building an intermediate Message.
+// The other behaviors are the same as Log().
+func (l *AsyncLogger) LogFields(fields ...Field) (*AsyncResult, error) {
+	asyncErrChan := make(chan error, 1)
+
+	body, err := l.encodeFields(fields)
+	if err == errMessageDropped {
+		asyncErrChan <- nil
+		return &AsyncResult{
+			AsyncErrChan: asyncErrChan,
+		}, nil
+	}
 	if err != nil {
 		asyncErrChan <- err
 		return &AsyncResult{
@@ -46,6 +93,19 @@ func (l *AsyncLogger) Log(message Message) (*AsyncResult, error) {
 		}, err
 	}
 
+	l.log(body, asyncErrChan)
+
+	return &AsyncResult{
+		AsyncErrChan: asyncErrChan,
+	}, nil
+}
+
+func (l *AsyncLogger) sendEncoded(body []byte) error {
+	l.log(body, make(chan error, 1))
+	return nil
+}
+
+func (l *AsyncLogger) log(body []byte, asyncErrChan chan error) {
 	go func() {
 		resp, err := l.APIClient.Log(body)
 		if err != nil {
@@ -61,8 +121,4 @@ func (l *AsyncLogger) Log(message Message) (*AsyncResult, error) {
 
 		asyncErrChan <- nil
 	}()
-
-	return &AsyncResult{
-		AsyncErrChan: asyncErrChan,
-	}, nil
 }
@@ -118,3 +118,24 @@ func TestAsyncLoggerLogShouldBeFailWithHTTPStatusFailing(t *testing.T) {
 		}
 	}
 }
+
+func TestAsyncLoggerLogFieldsShouldBeSuccessfully(t *testing.T) {
+	l := NewAsyncLogger([]string{"test-tag"}, "test-token", true)
+	l.APIClient = &api.DummyNopClient{}
+
+	result, err := l.LogFields(String("Message", "test-msg"), String("From", "john doe"))
+	if err != nil {
+		t.Error("unexpected error", err)
+	}
+	if err := <-result.AsyncErrChan; err != nil {
+		t.Error("unexpected error", err)
+	}
+
+	result, err = l.LogFields(Object("invalid", make(chan int)))
+	if err == nil {
+		t.Error("err should not be nil, but got nil")
+	}
+	if err := <-result.AsyncErrChan; err == nil {
+		t.Error("err should not be nil, but got nil")
+	}
+}
@@ -1,7 +1,6 @@
 package logger
 
 import (
-	"encoding/json"
 	"sync"
 
 	"time"
@@ -24,6 +23,7 @@ type AsyncPoolLogger struct {
 	wg          *sync.WaitGroup
 	active      bool
 	stoppedChan chan struct{}
+	encoder
 }
 
 type asyncLog struct {
@@ -73,7 +73,13 @@ func (l *AsyncPoolLogger) Log(message Message) (*AsyncResult, error) {
 		}, err
 	}
 
-	body, err := json.Marshal(message)
+	body, err := l.encodeMessage(message)
+	if err == errMessageDropped {
+		asyncErrChan <- nil
+		return &AsyncResult{
+			AsyncErrChan: asyncErrChan,
+		}, nil
+	}
 	if err != nil {
 		asyncErrChan <- err
 		return &AsyncResult{
@@ -81,6 +87,64 @@ func (l *AsyncPoolLogger) Log(message Message) (*AsyncResult, error) {
 		}, err
 	}
 
+	return l.enqueue(body, asyncErrChan)
+}
+
+// Send logs message into loggly through event API asynchronously.
+//
+// This method is the same as Log() but it returns only the foreground error;
+// the result of the background processing is not notified.
+func (l *AsyncPoolLogger) Send(message Message) error {
+	_, err := l.Log(message)
+	return err
+}
+
+// With returns a child logger that merges the fields into each message and sends that through this logger.
+func (l *AsyncPoolLogger) With(fields Message) *BoundLogger {
+	return NewBoundLogger(l, fields)
+}
+
+// LogFields logs the fields into loggly through event API asynchronously.
+//
+// This method encodes the fields without building an intermediate Message.
+// The other behaviors are the same as Log().
+func (l *AsyncPoolLogger) LogFields(fields ...Field) (*AsyncResult, error) {
+	asyncErrChan := make(chan error, 1)
+
+	if !l.active {
+		err := errors.New("in progress to shutdown. refused the message")
+		asyncErrChan <- err
+		return &AsyncResult{
+			AsyncErrChan: asyncErrChan,
+		}, err
+	}
+
+	body, err := l.encodeFields(fields)
+	if err == errMessageDropped {
+		asyncErrChan <- nil
+		return &AsyncResult{
+			AsyncErrChan: asyncErrChan,
+		}, nil
+	}
+	if err != nil {
+		asyncErrChan <- err
+		return &AsyncResult{
+			AsyncErrChan: asyncErrChan,
+		}, err
+	}
+
+	return l.enqueue(body, asyncErrChan)
+}
+
+func (l *AsyncPoolLogger) sendEncoded(body []byte) error {
+	if !l.active {
+		return errors.New("in progress to shutdown. refused the message")
+	}
+	_, err := l.enqueue(body, make(chan error, 1))
+	return err
+}
+
+func (l *AsyncPoolLogger) enqueue(body []byte, asyncErrChan chan error) (*AsyncResult, error) {
 	l.logsQueue <- &asyncLog{
 		body:    body,
 		errChan: asyncErrChan,
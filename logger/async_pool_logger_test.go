@@ -151,3 +151,23 @@ func TestAsyncPoolLogger_Shutdown(t *testing.T) {
 		t.Error("error and payload of channel error are different")
 	}
 }
+
+func TestAsyncPoolLoggerLogFieldsShouldBeSuccessfully(t *testing.T) {
+	l := NewAsyncPoolLogger([]string{"test-tag"}, "test-token", true, 3, 100000)
+	l.APIClient = &api.DummyNopClient{}
+
+	result, err := l.LogFields(String("Message", "test-msg"), String("From", "john doe"))
+	if err != nil {
+		t.Error("unexpected error", err)
+	}
+	if err := <-result.AsyncErrChan; err != nil {
+		t.Error("unexpected error", err)
+	}
+
+	<-l.Shutdown()
+
+	_, err = l.LogFields(String("Message", "test-msg"))
+	if err == nil {
+		t.Error("err should not be nil, but got nil")
+	}
+}
@@ -0,0 +1,78 @@
+package logger
+
+import "context"
+
+// BoundLogger is a child logger that has the bound contextual fields, e.g. `service`, `request_id` and `user_id`.
+//
+// This logger merges the bound fields into each message and sends that through the parent logger;
+// so the derived loggers share the transport and buffers of the parent.
+//
+// The precedence of the fields is as following (former wins);
+// 1. The fields of the message that is given to Log()
+// 2. The fields that are bound by the child, i.e. the latest With()
+// 3. The fields that are bound by the ancestors
+type BoundLogger struct {
+	sink   Sink
+	fields Message
+}
+
+// NewBoundLogger creates an instance of BoundLogger that sends the messages through the sink.
+//
+// Every logger of this package also has With() method as a shorthand of this.
+func NewBoundLogger(sink Sink, fields Message) *BoundLogger {
+	return &BoundLogger{
+		sink:   sink,
+		fields: mergeFields(nil, fields),
+	}
+}
+
+// With returns a derived logger that has the fields in addition to the fields of this logger.
+// This logger is not modified.
+func (l *BoundLogger) With(fields Message) *BoundLogger {
+	return &BoundLogger{
+		sink:   l.sink,
+		fields: mergeFields(l.fields, fields),
+	}
+}
+
+// Fields returns a copy of the bound fields.
+func (l *BoundLogger) Fields() Message {
+	return mergeFields(nil, l.fields)
+}
+
+// Log logs the message that the bound fields are merged into.
+// The given message is not modified.
+func (l *BoundLogger) Log(message Message) error {
+	return l.sink.Send(mergeFields(l.fields, message))
+}
+
+// Send is the same as Log(). This makes BoundLogger satisfy Sink.
+func (l *BoundLogger) Send(message Message) error {
+	return l.Log(message)
+}
+
+// mergeFields returns a new message that has the fields of both; the fields of `override` win.
+func mergeFields(base Message, override Message) Message {
+	merged := make(Message, len(base)+len(override))
+	for k, v := range base {
+		merged[k] = v
+	}
+	for k, v := range override {
+		merged[k] = v
+	}
+	return merged
+}
+
+type boundLoggerContextKey struct{}
+
+// NewContext returns a copy of the context that holds the logger.
+func NewContext(ctx context.Context, l *BoundLogger) context.Context {
+	return context.WithValue(ctx, boundLoggerContextKey{}, l)
+}
+
+// FromContext retrieves the logger that is stored by NewContext().
+// If the context doesn't have that, this returns false as the second value.
+func FromContext(ctx context.Context) (*BoundLogger, bool) {
+	l, ok := ctx.Value(boundLoggerContextKey{}).(*BoundLogger)
+	return l, ok
+}
@@ -0,0 +1,89 @@
+package logger
+
+import (
+	"context"
+	"testing"
+)
+
+func TestBoundLoggerShouldMergeFields(t *testing.T) {
+	sink := &recordingSink{}
+
+	parent := NewBoundLogger(sink, Message{"service": "api", "env": "prod"})
+	child := parent.With(Message{"request_id": "req-1", "env": "staging"})
+
+	child.Log(Message{"message": "hello", "request_id": "overridden"})
+	parent.Log(Message{"message": "world"})
+
+	got := sink.messages[0]
+	expected := Message{"service": "api", "env": "staging", "request_id": "overridden", "message": "hello"}
+	if len(got) != len(expected) {
+		t.Errorf("got == %v but wants %v", got, expected)
+	}
+	for k, v := range expected {
+		if got[k] != v {
+			t.Errorf("got[%s] == %v but wants %v", k, got[k], v)
+		}
+	}
+
+	if _, ok := sink.messages[1]["request_id"]; ok {
+		t.Error("parent should not have the fields of the child")
+	}
+	if sink.messages[1]["env"] != "prod" {
+		t.Errorf("env == %v but wants %v", sink.messages[1]["env"], "prod")
+	}
+}
+
+func TestBoundLoggerShouldShareBufferOfParent(t *testing.T) {
+	l, _ := NewSyncBulkLogger([]string{"test-tag"}, "test-token", true, 1024, 0)
+
+	l.With(Message{"service": "api"}).Log(Message{"message": "msg1"})
+	l.With(Message{"service": "batch"}).Log(Message{"message": "msg2"})
+
+	if len(l.logs) != 2 {
+		t.Fatalf("len(l.logs) == %d but wants %d", len(l.logs), 2)
+	}
+	expected := `{"message":"msg2","service":"batch"}`
+	if string(l.logs[1]) != expected {
+		t.Errorf("l.logs[1] == `%s` but wants `%s`", l.logs[1], expected)
+	}
+}
+
+func TestLeveledLoggerWith(t *testing.T) {
+	defaultSink := &recordingSink{}
+	errorSink := &recordingSink{}
+
+	l := NewLeveledLogger(defaultSink, nil)
+	l.Route(ErrorLevel, errorSink)
+
+	child := l.With(Message{"service": "api"}).With(Message{"user_id": 42})
+	child.Info(Message{"message": "info"})
+	child.Error(Message{"message": "error"})
+	l.Info(Message{"message": "parent"})
+
+	for _, got := range []Message{defaultSink.messages[0], errorSink.messages[0]} {
+		if got["service"] != "api" || got["user_id"] != 42 {
+			t.Errorf("got == %v but it should have the bound fields", got)
+		}
+	}
+	if _, ok := defaultSink.messages[1]["service"]; ok {
+		t.Error("parent should not have the fields of the child")
+	}
+
+	l.AtomicLevel().SetLevel(ErrorLevel)
+	child.Info(Message{"message": "discarded"})
+	if len(defaultSink.messages) != 2 {
+		t.Errorf("len(messages) == %d but wants %d", len(defaultSink.messages), 2)
+	}
+}
+
+func TestBoundLoggerContext(t *testing.T) {
+	if _, ok := FromContext(context.Background()); ok {
+		t.Error("empty context should not have the logger")
+	}
+
+	l := NewBoundLogger(&recordingSink{}, Message{"request_id": "req-1"})
+	got, ok := FromContext(NewContext(context.Background(), l))
+	if !ok || got != l {
+		t.Errorf("got == %v but wants %v", got, l)
+	}
+}
@@ -0,0 +1,69 @@
+package logger
+
+import (
+	"reflect"
+	"runtime"
+	"strconv"
+	"strings"
+)
+
+// callerSkipPrefixes are the prefixes of the functions that are not the caller.
+// Those are the functions of this module, e.g. "github.com/moznion/logglily/logger.(*SyncLogger).Log"
+// and "github.com/moznion/logglily/sloghandler.(*Handler).Handle", and the ones of log/slog that calls sloghandler.
+var callerSkipPrefixes = []string{
+	strings.TrimSuffix(reflect.TypeOf(encoder{}).PkgPath(), "logger"),
+	"log/slog.",
+}
+
+const maxCallerSearchDepth = 32
+
+// captureCaller returns the first frame outside of this module; that is the frame that calls the logger.
+// The loggers that wrap another one (e.g. BoundLogger, LeveledLogger and sloghandler) are skipped as well.
+func captureCaller() (Frame, bool) {
+	pcs := make([]uintptr, maxCallerSearchDepth)
+	n := runtime.Callers(2, pcs)
+	frames := runtime.CallersFrames(pcs[:n])
+	for {
+		frame, more := frames.Next()
+		if !isLoggerFrame(frame) {
+			return Frame{Function: frame.Function, File: frame.File, Line: frame.Line}, frame.Function != ""
+		}
+		if !more {
+			return Frame{}, false
+		}
+	}
+}
+
+func isLoggerFrame(frame runtime.Frame) bool {
+	// the tests of this module are the callers
+	if strings.HasSuffix(frame.File, "_test.go") {
+		return false
+	}
+	for _, prefix := range callerSkipPrefixes {
+		if strings.HasPrefix(frame.Function, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+func (f Frame) toMap() map[string]interface{} {
+	return map[string]interface{}{
+		"function": f.Function,
+		"file":     f.File,
+		"line":     f.Line,
+	}
+}
+
+// appendCallerEntry appends the caller as the key/value pair of JSON object.
+// The keys are in the same order as `json.Marshal` encodes the map.
+func appendCallerEntry(dst []byte, key string, caller Frame) []byte {
+	dst = appendJSONString(dst, key)
+	dst = append(dst, `:{"file":`...)
+	dst = appendJSONString(dst, caller.File)
+	dst = append(dst, `,"function":`...)
+	dst = appendJSONString(dst, caller.Function)
+	dst = append(dst, `,"line":`...)
+	dst = strconv.AppendInt(dst, int64(caller.Line), 10)
+	return append(dst, '}')
+}
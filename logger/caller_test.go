@@ -0,0 +1,71 @@
+package logger
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/moznion/logglily/internal/api"
+)
+
+func assertCaller(t *testing.T, stdout string, function string) {
+	var decoded struct {
+		Caller Frame `json:"caller"`
+	}
+	if err := json.Unmarshal([]byte(stdout), &decoded); err != nil {
+		t.Fatal("unexpected err", err)
+	}
+	if !strings.HasSuffix(decoded.Caller.Function, function) {
+		t.Errorf("caller function == %s but wants %s", decoded.Caller.Function, function)
+	}
+	if !strings.HasSuffix(decoded.Caller.File, "caller_test.go") || decoded.Caller.Line <= 0 {
+		t.Errorf("unexpected caller: %+v", decoded.Caller)
+	}
+}
+
+func TestSyncLoggerShouldInjectCaller(t *testing.T) {
+	l := NewSyncLogger([]string{"test-tag"}, "test-token", true)
+	l.APIClient = &api.DummySuccClient{}
+	l.SetCaller("caller")
+
+	stdout, err := captureLogStdoutCapture(func() error {
+		return l.Log(Message{"message": "hello"})
+	})
+	if err != nil {
+		t.Error("unexpected err", err)
+	}
+	assertCaller(t, stdout, "TestSyncLoggerShouldInjectCaller.func1")
+
+	stdout, err = captureLogStdoutCapture(func() error {
+		return l.LogFields(String("message", "hello"))
+	})
+	if err != nil {
+		t.Error("unexpected err", err)
+	}
+	assertCaller(t, stdout, "TestSyncLoggerShouldInjectCaller.func2")
+
+	stdout, err = captureLogStdoutCapture(func() error {
+		return l.Log(Message{"message": "hello", "caller": "given"})
+	})
+	if err != nil {
+		t.Error("unexpected err", err)
+	}
+	if stdout != `{"caller":"given","message":"hello"}` {
+		t.Errorf("caller should not be overwritten: %s", stdout)
+	}
+}
+
+func TestCallerShouldSkipWrappingLoggers(t *testing.T) {
+	l := NewSyncLogger([]string{"test-tag"}, "test-token", true)
+	l.APIClient = &api.DummySuccClient{}
+	l.SetCaller("caller")
+	leveled := NewLeveledLogger(l, nil).With(Message{"service": "api"})
+
+	stdout, err := captureLogStdoutCapture(func() error {
+		return leveled.Info(Message{"message": "hello"})
+	})
+	if err != nil {
+		t.Error("unexpected err", err)
+	}
+	assertCaller(t, stdout, "TestCallerShouldSkipWrappingLoggers.func1")
+}
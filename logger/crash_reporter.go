@@ -0,0 +1,171 @@
+package logger
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"runtime"
+	"sync"
+	"time"
+)
+
+const (
+	defaultCrashFlushTimeout = 10 * time.Second
+
+	initialStackBufferSize = 64 * 1024
+	// maxStackBufferSize keeps the crash report under the size limit of the event API (1MB)
+	maxStackBufferSize = 512 * 1024
+)
+
+// CrashReporter reports the panic into loggly and flushes the registered bulk loggers before the process dies.
+//
+// Without this, the messages that are buffered by SyncBulkLogger and AsyncBulkLogger are lost with the process,
+// and the panic itself never reaches loggly. Please defer Recover() at the top of main() and each goroutine, e.g.
+//
+//	reporter := logger.NewCrashReporter(logger.NewSyncLogger(tags, token, true))
+//	reporter.RegisterAsyncBulkLogger(bulkLogger)
+//	defer reporter.Recover()
+//
+// The crash report is sent through the given SyncLogger (event API) synchronously, like;
+//
+//	{"level":"fatal","message":"panic: boom","panic":"boom","panic_type":"string","stack":"goroutine 1 [running]:\n..."}
+//
+// NOTE: the register methods are not goroutine-safe. Please configure the reporter before running.
+type CrashReporter struct {
+	logger       *SyncLogger
+	flushers     []func() error
+	flushTimeout time.Duration
+	exitCode     int
+	exit         func(code int)
+}
+
+// NewCrashReporter creates a new CrashReporter that sends the crash reports through the logger.
+func NewCrashReporter(logger *SyncLogger) *CrashReporter {
+	return &CrashReporter{
+		logger:       logger,
+		flushTimeout: defaultCrashFlushTimeout,
+		exit:         os.Exit,
+	}
+}
+
+// RegisterSyncBulkLogger registers the SyncBulkLogger to be flushed on crash.
+func (c *CrashReporter) RegisterSyncBulkLogger(l *SyncBulkLogger) {
+	c.RegisterFlusher(func() error {
+		_, err := l.Flush()
+		return err
+	})
+}
+
+// RegisterAsyncBulkLogger registers the AsyncBulkLogger to be flushed on crash. This waits for the result of the flushing.
+func (c *CrashReporter) RegisterAsyncBulkLogger(l *AsyncBulkLogger) {
+	c.RegisterFlusher(func() error {
+		return <-l.Flush().AsyncErrChan
+	})
+}
+
+// RegisterFlusher registers the function that flushes something on crash.
+func (c *CrashReporter) RegisterFlusher(flush func() error) {
+	c.flushers = append(c.flushers, flush)
+}
+
+// SetFlushTimeout changes the time to wait for the flushing on crash. The default is 10 seconds.
+func (c *CrashReporter) SetFlushTimeout(timeout time.Duration) {
+	c.flushTimeout = timeout
+}
+
+// SetExitCode makes Recover() exit the process with the code instead of re-panicking.
+// If 0 is given, Recover() re-panics; this is the default.
+func (c *CrashReporter) SetExitCode(code int) {
+	c.exitCode = code
+}
+
+// Recover recovers the panic, reports that with the stacks of all goroutines and flushes the registered loggers.
+// After that, this re-panics with the same value, or exits the process if SetExitCode() is given.
+//
+// This must be called by defer directly, e.g. `defer reporter.Recover()`; otherwise the panic is not recovered.
+func (c *CrashReporter) Recover() {
+	p := recover()
+	if p == nil {
+		return
+	}
+
+	c.Report(p, allGoroutineStacks(), nil)
+	c.Flush()
+
+	if c.exitCode != 0 {
+		c.exit(c.exitCode)
+		return
+	}
+	panic(p)
+}
+
+// Report sends the crash report of the panic value synchronously.
+// The fields are added into the report; those don't overwrite the fields of the report.
+func (c *CrashReporter) Report(p interface{}, stack []byte, fields Message) error {
+	message := Message{
+		"level":      FatalLevel.String(),
+		"message":    fmt.Sprintf("panic: %v", p),
+		"panic":      fmt.Sprint(p),
+		"panic_type": fmt.Sprintf("%T", p),
+		"stack":      string(stack),
+	}
+	if err, ok := p.(error); ok {
+		message["error"] = err
+	}
+	for k, v := range fields {
+		if _, ok := message[k]; !ok {
+			message[k] = v
+		}
+	}
+
+	return c.logger.Log(message)
+}
+
+// Flush flushes the registered loggers concurrently, and waits for them until the flush timeout.
+// This returns the first error of the flushing.
+func (c *CrashReporter) Flush() error {
+	errs := make(chan error, len(c.flushers))
+	var wg sync.WaitGroup
+	for _, flush := range c.flushers {
+		wg.Add(1)
+		go func(flush func() error) {
+			defer wg.Done()
+			errs <- flush()
+		}(flush)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(c.flushTimeout):
+		return errors.New("timed out to flush the loggers")
+	}
+
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// allGoroutineStacks returns the stack traces of all goroutines. The too long traces are truncated.
+func allGoroutineStacks() []byte {
+	buf := make([]byte, initialStackBufferSize)
+	for {
+		n := runtime.Stack(buf, true)
+		if n < len(buf) {
+			return buf[:n]
+		}
+		if len(buf) >= maxStackBufferSize {
+			return append(buf[:n], "\n...(truncated)"...)
+		}
+		buf = make([]byte, 2*len(buf))
+	}
+}
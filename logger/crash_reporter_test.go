@@ -0,0 +1,118 @@
+package logger
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/moznion/logglily/internal/api"
+)
+
+func newTestCrashReporter() *CrashReporter {
+	l := NewSyncLogger([]string{"test-tag"}, "test-token", true)
+	l.APIClient = &api.DummySuccClient{}
+	return NewCrashReporter(l)
+}
+
+func TestCrashReporterShouldReportAndFlushBeforeRepanic(t *testing.T) {
+	reporter := newTestCrashReporter()
+
+	syncBulk, _ := NewSyncBulkLogger([]string{"test-tag"}, "test-token", true, 5*1024*1024, 0)
+	syncBulk.APIClient = &api.DummySuccClient{}
+	reporter.RegisterSyncBulkLogger(syncBulk)
+	asyncBulk, _ := NewAsyncBulkLogger([]string{"test-tag"}, "test-token", true, 5*1024*1024, 0)
+	asyncBulk.APIClient = &api.DummySuccClient{}
+	reporter.RegisterAsyncBulkLogger(asyncBulk)
+
+	var recovered interface{}
+	stdout, _ := captureLogStdoutCapture(func() error {
+		syncBulk.Log(Message{"message": "buffered sync"})
+		result, _ := asyncBulk.Log(Message{"message": "buffered async"})
+		<-result.AsyncErrChan
+
+		func() {
+			defer func() {
+				recovered = recover()
+			}()
+			defer reporter.Recover()
+			panic("boom")
+		}()
+		return nil
+	})
+
+	if recovered != "boom" {
+		t.Errorf("should re-panic with the same value: %v", recovered)
+	}
+
+	var report map[string]interface{}
+	if err := json.NewDecoder(strings.NewReader(stdout)).Decode(&report); err != nil {
+		t.Fatalf("unexpected err: %s (stdout: %s)", err, stdout)
+	}
+	if report["message"] != "panic: boom" || report["panic_type"] != "string" || report["level"] != "fatal" {
+		t.Errorf("report == %v", report)
+	}
+	if stack := report["stack"].(string); !strings.Contains(stack, "TestCrashReporterShouldReportAndFlushBeforeRepanic") {
+		t.Errorf("stack doesn't contain the panicking function: %s", stack)
+	}
+
+	if !strings.Contains(stdout, `{"message":"buffered sync"}`) || !strings.Contains(stdout, `{"message":"buffered async"}`) {
+		t.Errorf("buffered messages should be flushed: %s", stdout)
+	}
+}
+
+func TestCrashReporterShouldExitWithCode(t *testing.T) {
+	reporter := newTestCrashReporter()
+	reporter.SetExitCode(3)
+	exitCode := 0
+	reporter.exit = func(code int) {
+		exitCode = code
+	}
+
+	stdout, _ := captureLogStdoutCapture(func() error {
+		defer reporter.Recover()
+		panic(errors.New("boom"))
+	})
+
+	if exitCode != 3 {
+		t.Errorf("exitCode == %d but wants 3", exitCode)
+	}
+	if !strings.Contains(stdout, `"error":{"message":"boom","type":"*errors.errorString"}`) {
+		t.Errorf("stdout == %s", stdout)
+	}
+}
+
+func TestCrashReporterShouldNotDoAnythingWithoutPanic(t *testing.T) {
+	reporter := newTestCrashReporter()
+	reporter.RegisterFlusher(func() error {
+		t.Error("should not flush")
+		return nil
+	})
+
+	stdout, _ := captureLogStdoutCapture(func() error {
+		defer reporter.Recover()
+		return nil
+	})
+	if stdout != "" {
+		t.Errorf("stdout == %s", stdout)
+	}
+}
+
+func TestCrashReporterFlushShouldTimeout(t *testing.T) {
+	reporter := newTestCrashReporter()
+	reporter.SetFlushTimeout(10 * time.Millisecond)
+	block := make(chan struct{})
+	defer close(block)
+	reporter.RegisterFlusher(func() error {
+		<-block
+		return nil
+	})
+	reporter.RegisterFlusher(func() error {
+		return errors.New("failed")
+	})
+
+	if err := reporter.Flush(); err == nil {
+		t.Error("should be timed out")
+	}
+}
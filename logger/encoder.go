@@ -0,0 +1,184 @@
+package logger
+
+import "encoding/json"
+
+// encoder builds the payload from the message. This is embedded in every logger,
+// so the settings of the encoding are configurable through the logger.
+//
+// The message passes through the stages in the following order;
+// 1. Timestamper injects the timestamp, and the caller is injected if it is enabled
+// 2. Processors process the message in order; that may drop the message
+// 3. The errors in the message are converted into the structured objects
+// 4. Redactor removes the sensitive values
+// 5. TolerantEncoding substitutes the unmarshalable values
+// 6. The message is encoded into JSON
+//
+// NOTE: the setters are not goroutine-safe. Please configure the logger before logging.
+type encoder struct {
+	timestamper *Timestamper
+	processors  []Processor
+	redactor    *Redactor
+	tolerant    *TolerantEncoding
+	callerKey   string
+}
+
+// SetTimestamper sets the Timestamper that injects the timestamp into the message when Log() is called.
+// If nil is given, the timestamp is not injected; this is the default.
+func (e *encoder) SetTimestamper(timestamper *Timestamper) {
+	e.timestamper = timestamper
+}
+
+// SetProcessors sets the chain of the processors that process the message before encoding.
+// The processors are applied in the given order. If nothing is given, the chain is cleared.
+//
+// When the processors are set, LogFields() builds an intermediate Message from the fields to apply them.
+func (e *encoder) SetProcessors(processors ...Processor) {
+	e.processors = processors
+}
+
+// SetRedactor sets the Redactor that removes the sensitive values from the message before encoding.
+// If nil is given, the message is encoded verbatim; this is the default.
+//
+// When Redactor is set, LogFields() builds an intermediate Message from the fields to apply the rules.
+func (e *encoder) SetRedactor(redactor *Redactor) {
+	e.redactor = redactor
+}
+
+// SetTolerantEncoding sets the TolerantEncoding that substitutes the unmarshalable values instead of dropping the whole message.
+// If nil is given, the message that cannot be encoded results in an error; this is the default.
+//
+// The message is sanitized only when that cannot be encoded as it is.
+// In that case, LogFields() builds an intermediate Message from the fields to sanitize them.
+func (e *encoder) SetTolerantEncoding(tolerant *TolerantEncoding) {
+	e.tolerant = tolerant
+}
+
+// SetCaller enables to inject the caller of Log() into the message with the given key, e.g.
+//
+//	{"caller":{"file":"/path/to/main.go","function":"main.main","line":42},"message":"hello"}
+//
+// If the message has the field of the key already, that is not overwritten.
+// If empty string is given, the caller is not injected; this is the default.
+//
+// NOTE: capturing the caller walks the stack, so this has the cost for each Log() call.
+func (e *encoder) SetCaller(key string) {
+	e.callerKey = key
+}
+
+func (e *encoder) encodeMessage(message Message) ([]byte, error) {
+	if e.timestamper != nil {
+		message = e.timestamper.stamp(message)
+	}
+	if e.callerKey != "" {
+		message = e.injectCaller(message)
+	}
+	if len(e.processors) > 0 {
+		var ok bool
+		message, ok = processMessage(e.processors, message)
+		if !ok {
+			return nil, errMessageDropped
+		}
+	}
+	message = structureErrors(message)
+	if e.redactor != nil {
+		message = e.redactor.Redact(message)
+	}
+
+	body, err := json.Marshal(message)
+	if err != nil && e.tolerant != nil {
+		// Sanitize only the message that cannot be encoded, because that walks the whole message by reflection
+		return json.Marshal(e.tolerant.Sanitize(message))
+	}
+	return body, err
+}
+
+// encodeFields encodes the fields into newly allocated bytes that is owned by the caller.
+func (e *encoder) encodeFields(fields []Field) ([]byte, error) {
+	buf := getBuffer()
+	defer putBuffer(buf)
+
+	var err error
+	buf.bs, err = e.appendFields(buf.bs, fields)
+	if err != nil {
+		return nil, err
+	}
+
+	body := make([]byte, len(buf.bs))
+	copy(body, buf.bs)
+	return body, nil
+}
+
+func (e *encoder) appendFields(dst []byte, fields []Field) ([]byte, error) {
+	if e.requiresMessage() {
+		body, err := e.encodeMessage(fieldsToMessage(fields))
+		if err != nil {
+			return dst, err
+		}
+		return append(dst, body...), nil
+	}
+
+	start := len(dst)
+	dst, err := e.appendFieldsDirectly(dst, fields)
+	if err != nil && e.tolerant != nil {
+		// Sanitize the fields that cannot be encoded through the intermediate Message
+		body, err := e.encodeMessage(fieldsToMessage(fields))
+		if err != nil {
+			return dst[:start], err
+		}
+		return append(dst[:start], body...), nil
+	}
+	return dst, err
+}
+
+func (e *encoder) appendFieldsDirectly(dst []byte, fields []Field) ([]byte, error) {
+	stamp := e.timestamper != nil && !hasFieldKey(fields, e.timestamper.key())
+	withCaller := e.callerKey != "" && !hasFieldKey(fields, e.callerKey)
+	if !stamp && !withCaller {
+		return appendFields(dst, fields)
+	}
+
+	dst = append(dst, '{')
+	first := true
+	if stamp {
+		dst = e.timestamper.appendEntry(dst)
+		first = false
+	}
+	if withCaller {
+		if caller, ok := captureCaller(); ok {
+			if !first {
+				dst = append(dst, ',')
+			}
+			dst = appendCallerEntry(dst, e.callerKey, caller)
+			first = false
+		}
+	}
+	dst, err := appendFieldEntries(dst, fields, first)
+	if err != nil {
+		return dst, err
+	}
+	return append(dst, '}'), nil
+}
+
+// injectCaller returns the message that has the caller.
+// The given message is not modified; this returns a shallow copy if it is necessary to add the caller.
+func (e *encoder) injectCaller(message Message) Message {
+	if _, ok := message[e.callerKey]; ok {
+		return message
+	}
+	caller, ok := captureCaller()
+	if !ok {
+		return message
+	}
+
+	injected := make(Message, len(message)+1)
+	for k, v := range message {
+		injected[k] = v
+	}
+	injected[e.callerKey] = caller.toMap()
+	return injected
+}
+
+// requiresMessage returns whether the fields must be converted to Message to pass through the stages.
+func (e *encoder) requiresMessage() bool {
+	return e.redactor != nil || len(e.processors) > 0
+}
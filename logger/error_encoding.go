@@ -0,0 +1,263 @@
+package logger
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"runtime"
+)
+
+const (
+	maxErrorChainLength = 32
+	maxStackDepth       = 64
+)
+
+// Frame is a frame of the stack trace.
+type Frame struct {
+	Function string `json:"function"`
+	File     string `json:"file"`
+	Line     int    `json:"line"`
+}
+
+// StackTracer is the interface of the error that carries the stack trace.
+// The errors that are created by WithStack() implement this.
+type StackTracer interface {
+	StackTrace() []Frame
+}
+
+// WithStack returns the error that wraps the given error with the stack trace of the caller.
+// The stack trace is encoded into the "stack" of the structured error.
+//
+// If the given error is nil, this returns nil. If the given error carries the stack trace already, this returns that as it is.
+func WithStack(err error) error {
+	if err == nil {
+		return nil
+	}
+	if _, ok := err.(StackTracer); ok {
+		return err
+	}
+
+	pcs := make([]uintptr, maxStackDepth)
+	n := runtime.Callers(2, pcs)
+	return &stackError{err: err, pcs: pcs[:n]}
+}
+
+type stackError struct {
+	err error
+	pcs []uintptr
+}
+
+func (e *stackError) Error() string {
+	return e.err.Error()
+}
+
+func (e *stackError) Unwrap() error {
+	return e.err
+}
+
+func (e *stackError) StackTrace() []Frame {
+	frames := make([]Frame, 0, len(e.pcs))
+	callersFrames := runtime.CallersFrames(e.pcs)
+	for {
+		frame, more := callersFrames.Next()
+		frames = append(frames, Frame{Function: frame.Function, File: frame.File, Line: frame.Line})
+		if !more {
+			break
+		}
+	}
+	return frames
+}
+
+// encodeError builds the structured representation of the error, e.g.
+//
+//	{"message":"open config: no such file","type":"*fs.PathError","chain":[{"message":"no such file","type":"syscall.Errno"}],"stack":[...]}
+//
+// "chain" is the list of the wrapped errors that are unwrapped through `Unwrap() error`, `Unwrap() []error` and `Cause() error`.
+// "stack" is given by the first error that implements StackTracer in the chain.
+func encodeError(err error) map[string]interface{} {
+	structured := map[string]interface{}{
+		"message": err.Error(),
+		"type":    errorTypeName(err),
+	}
+
+	var stack []Frame
+	if st, ok := err.(StackTracer); ok {
+		stack = st.StackTrace()
+	}
+
+	var chain []interface{}
+	queue := unwrapError(err)
+	for len(queue) > 0 && len(chain) < maxErrorChainLength {
+		wrapped := queue[0]
+		queue = append(unwrapError(wrapped), queue[1:]...)
+
+		if st, ok := wrapped.(StackTracer); ok {
+			if stack == nil {
+				stack = st.StackTrace()
+			}
+		}
+		if _, ok := wrapped.(*stackError); ok {
+			// it is transparent
+			continue
+		}
+		chain = append(chain, map[string]interface{}{
+			"message": wrapped.Error(),
+			"type":    errorTypeName(wrapped),
+		})
+	}
+
+	if len(chain) > 0 {
+		structured["chain"] = chain
+	}
+	if len(stack) > 0 {
+		frames := make([]interface{}, len(stack))
+		for i, frame := range stack {
+			frames[i] = frame.toMap()
+		}
+		structured["stack"] = frames
+	}
+	return structured
+}
+
+// errorTypeName returns the type name of the error. The error that is wrapped by WithStack() is reported as the wrapped one.
+func errorTypeName(err error) string {
+	for {
+		se, ok := err.(*stackError)
+		if !ok {
+			return fmt.Sprintf("%T", err)
+		}
+		err = se.err
+	}
+}
+
+func unwrapError(err error) []error {
+	switch e := err.(type) {
+	case interface{ Unwrap() error }:
+		if wrapped := e.Unwrap(); wrapped != nil {
+			return []error{wrapped}
+		}
+	case interface{ Unwrap() []error }:
+		var wrapped []error
+		for _, w := range e.Unwrap() {
+			if w != nil {
+				wrapped = append(wrapped, w)
+			}
+		}
+		return wrapped
+	case interface{ Cause() error }:
+		if cause := e.Cause(); cause != nil && cause != err {
+			return []error{cause}
+		}
+	}
+	return nil
+}
+
+// encodableError returns the value that represents the error in the message.
+// The error that implements json.Marshaler is kept as it is.
+func encodableError(err error) interface{} {
+	if _, ok := err.(json.Marshaler); ok {
+		return err
+	}
+	return encodeError(err)
+}
+
+// structureErrors returns the message whose error values are replaced with the structured representation.
+// The given message is not modified; this copies only the maps and slices that contain errors.
+func structureErrors(message Message) Message {
+	s := &errorStructurer{}
+	if replaced, ok := s.structureMap(map[string]interface{}(message)); ok {
+		return Message(replaced)
+	}
+	return message
+}
+
+type errorStructurer struct {
+	// visiting holds the nested maps and slices on the current path to break the cycles
+	visiting map[uintptr]bool
+}
+
+func (s *errorStructurer) enter(v interface{}) bool {
+	ptr := reflect.ValueOf(v).Pointer()
+	if s.visiting == nil {
+		s.visiting = make(map[uintptr]bool)
+	}
+	if s.visiting[ptr] || len(s.visiting) >= defaultMaxEncodingDepth {
+		return false
+	}
+	s.visiting[ptr] = true
+	return true
+}
+
+func (s *errorStructurer) leave(v interface{}) {
+	delete(s.visiting, reflect.ValueOf(v).Pointer())
+}
+
+// structureValue returns the replaced value and true if the value contains errors.
+func (s *errorStructurer) structureValue(v interface{}) (interface{}, bool) {
+	switch value := v.(type) {
+	case error:
+		if _, ok := value.(json.Marshaler); ok {
+			return nil, false
+		}
+		return encodeError(value), true
+	case Message:
+		if replaced, ok := s.structureNested(map[string]interface{}(value)); ok {
+			return Message(replaced.(map[string]interface{})), true
+		}
+	case map[string]interface{}:
+		return s.structureNested(value)
+	case []interface{}:
+		if len(value) > 0 {
+			return s.structureNested(value)
+		}
+	case []error:
+		replaced := make([]interface{}, len(value))
+		for i, err := range value {
+			if err != nil {
+				replaced[i] = encodableError(err)
+			}
+		}
+		return replaced, true
+	}
+	return nil, false
+}
+
+func (s *errorStructurer) structureNested(v interface{}) (interface{}, bool) {
+	if !s.enter(v) {
+		return nil, false
+	}
+	defer s.leave(v)
+
+	if m, ok := v.(map[string]interface{}); ok {
+		return s.structureMap(m)
+	}
+
+	list := v.([]interface{})
+	var replaced []interface{}
+	for i, elem := range list {
+		if r, ok := s.structureValue(elem); ok {
+			if replaced == nil {
+				replaced = make([]interface{}, len(list))
+				copy(replaced, list)
+			}
+			replaced[i] = r
+		}
+	}
+	return replaced, replaced != nil
+}
+
+func (s *errorStructurer) structureMap(m map[string]interface{}) (map[string]interface{}, bool) {
+	var replaced map[string]interface{}
+	for k, v := range m {
+		if r, ok := s.structureValue(v); ok {
+			if replaced == nil {
+				replaced = make(map[string]interface{}, len(m))
+				for k, v := range m {
+					replaced[k] = v
+				}
+			}
+			replaced[k] = r
+		}
+	}
+	return replaced, replaced != nil
+}
@@ -0,0 +1,135 @@
+package logger
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/moznion/logglily/internal/api"
+)
+
+type wrappedError struct {
+	msg string
+	err error
+}
+
+func (e *wrappedError) Error() string { return e.msg + ": " + e.err.Error() }
+func (e *wrappedError) Unwrap() error { return e.err }
+
+type causerError struct {
+	err error
+}
+
+func (e causerError) Error() string { return "caused: " + e.err.Error() }
+func (e causerError) Cause() error  { return e.err }
+
+type jsonError struct{}
+
+func (jsonError) Error() string                { return "json" }
+func (jsonError) MarshalJSON() ([]byte, error) { return []byte(`{"code":42}`), nil }
+
+func TestEncodeErrorShouldHaveChain(t *testing.T) {
+	root := errors.New("root")
+	err := &wrappedError{msg: "outer", err: causerError{err: root}}
+
+	got := encodeError(err)
+	expected := map[string]interface{}{
+		"message": "outer: caused: root",
+		"type":    "*logger.wrappedError",
+		"chain": []interface{}{
+			map[string]interface{}{"message": "caused: root", "type": "logger.causerError"},
+			map[string]interface{}{"message": "root", "type": "*errors.errorString"},
+		},
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("got == %v but wants %v", got, expected)
+	}
+}
+
+func TestEncodeErrorShouldHaveStackOfWithStack(t *testing.T) {
+	err := &wrappedError{msg: "outer", err: WithStack(errors.New("root"))}
+
+	got := encodeError(err)
+	chain := got["chain"].([]interface{})
+	if len(chain) != 1 || chain[0].(map[string]interface{})["type"] != "*errors.errorString" {
+		t.Errorf("chain should not contain the stack wrapper: %v", chain)
+	}
+
+	stack := got["stack"].([]interface{})
+	top := stack[0].(map[string]interface{})
+	if !strings.HasSuffix(top["function"].(string), "TestEncodeErrorShouldHaveStackOfWithStack") {
+		t.Errorf("unexpected top frame: %v", top)
+	}
+	if !strings.HasSuffix(top["file"].(string), "error_encoding_test.go") {
+		t.Errorf("unexpected top frame: %v", top)
+	}
+
+	if WithStack(nil) != nil {
+		t.Error("WithStack(nil) should be nil")
+	}
+	withStack := WithStack(errors.New("x"))
+	if WithStack(withStack) != withStack {
+		t.Error("WithStack should not wrap the error that has the stack twice")
+	}
+	if encodeError(withStack)["type"] != "*errors.errorString" {
+		t.Error("type should be of the wrapped error")
+	}
+}
+
+func TestStructureErrorsShouldReplaceNestedErrors(t *testing.T) {
+	given := Message{
+		"message": "failed",
+		"error":   errors.New("boom"),
+		"custom":  jsonError{},
+		"nested":  map[string]interface{}{"errors": []interface{}{"ok", fmt.Errorf("wrapped")}},
+	}
+	got := structureErrors(given)
+
+	body, err := json.Marshal(got)
+	if err != nil {
+		t.Fatal("unexpected err", err)
+	}
+	expected := `{"custom":{"code":42},"error":{"message":"boom","type":"*errors.errorString"},"message":"failed","nested":{"errors":["ok",{"message":"wrapped","type":"*errors.errorString"}]}}`
+	if string(body) != expected {
+		t.Errorf("got == `%s` but wants `%s`", body, expected)
+	}
+
+	if _, ok := given["error"].(error); !ok {
+		t.Error("given message should not be modified")
+	}
+
+	noErrors := Message{"message": "hello"}
+	if reflect.ValueOf(structureErrors(noErrors)).Pointer() != reflect.ValueOf(noErrors).Pointer() {
+		t.Error("the message without errors should not be copied")
+	}
+}
+
+func TestStructureErrorsShouldBreakCycles(t *testing.T) {
+	m := map[string]interface{}{}
+	m["self"] = m
+	m["other"] = m
+
+	got := structureErrors(Message{"map": m, "error": errors.New("boom")})
+	if _, ok := got["error"].(map[string]interface{}); !ok {
+		t.Errorf("got == %v", got)
+	}
+}
+
+func TestSyncLoggerShouldEncodeErrorAsStructuredObject(t *testing.T) {
+	l := NewSyncLogger([]string{"test-tag"}, "test-token", true)
+	l.APIClient = &api.DummySuccClient{}
+
+	stdout, err := captureLogStdoutCapture(func() error {
+		return l.Log(Message{"message": "failed", "error": errors.New("boom")})
+	})
+	if err != nil {
+		t.Error("unexpected err", err)
+	}
+	expected := `{"error":{"message":"boom","type":"*errors.errorString"},"message":"failed"}`
+	if stdout != expected {
+		t.Errorf("stdout == `%v` but wants `%v`", stdout, expected)
+	}
+}
@@ -0,0 +1,199 @@
+package logger
+
+import (
+	"encoding/json"
+	"math"
+	"time"
+)
+
+type fieldType uint8
+
+const (
+	skipFieldType fieldType = iota
+	stringFieldType
+	intFieldType
+	uintFieldType
+	floatFieldType
+	boolFieldType
+	durationFieldType
+	timeFieldType
+	errorFieldType
+	objectFieldType
+	rawJSONFieldType
+)
+
+// Field is a typed key/value pair that is encoded directly into the payload.
+//
+// Field is an alternative of Message for the hot path.
+// Message is a map, so building it allocates for each entry and encoding it through `json.Marshal` uses reflection.
+// On the other hand, Field holds the value as it is and LogFields() encodes that into the pooled buffer directly.
+//
+// Please create Field through the constructor functions, e.g. String(), Int(), Duration(), Err() and so on.
+//
+// NOTE: Field doesn't deduplicate the keys. If the same key is given twice, the payload contains that twice.
+type Field struct {
+	key       string
+	fieldType fieldType
+	integer   int64
+	str       string
+	iface     interface{}
+}
+
+// String creates a field that contains string value.
+func String(key string, value string) Field {
+	return Field{key: key, fieldType: stringFieldType, str: value}
+}
+
+// Int creates a field that contains int value.
+func Int(key string, value int) Field {
+	return Int64(key, int64(value))
+}
+
+// Int64 creates a field that contains int64 value.
+func Int64(key string, value int64) Field {
+	return Field{key: key, fieldType: intFieldType, integer: value}
+}
+
+// Uint64 creates a field that contains uint64 value.
+func Uint64(key string, value uint64) Field {
+	return Field{key: key, fieldType: uintFieldType, integer: int64(value)}
+}
+
+// Float64 creates a field that contains float64 value.
+//
+// NaN and infinities are not representable in JSON, so those are encoded as strings; "NaN", "+Inf" and "-Inf".
+func Float64(key string, value float64) Field {
+	return Field{key: key, fieldType: floatFieldType, integer: int64(math.Float64bits(value))}
+}
+
+// Bool creates a field that contains bool value.
+func Bool(key string, value bool) Field {
+	var i int64
+	if value {
+		i = 1
+	}
+	return Field{key: key, fieldType: boolFieldType, integer: i}
+}
+
+// Duration creates a field that contains time.Duration value.
+//
+// The value is encoded as nanoseconds number; this is the same as `json.Marshal` does for time.Duration.
+func Duration(key string, value time.Duration) Field {
+	return Field{key: key, fieldType: durationFieldType, integer: int64(value)}
+}
+
+// Time creates a field that contains time.Time value.
+//
+// The value is encoded as RFC3339 string with nanoseconds; this is the same as `json.Marshal` does for time.Time.
+func Time(key string, value time.Time) Field {
+	if value.Before(minUnixNanoTime) || value.After(maxUnixNanoTime) {
+		// Out of the range of UnixNano(); keep the value as it is.
+		return Field{key: key, fieldType: timeFieldType, iface: value}
+	}
+	return Field{key: key, fieldType: timeFieldType, integer: value.UnixNano(), iface: value.Location()}
+}
+
+// Err creates a field that contains error value with "error" key.
+//
+// The error is encoded as the structured object that has "message", "type", "chain" and "stack"; please refer WithStack() also.
+// If the error implements json.Marshaler, that is used instead.
+//
+// If the given error is nil, this field is skipped on encoding.
+func Err(err error) Field {
+	return NamedErr("error", err)
+}
+
+// NamedErr creates a field that contains error value with the given key.
+//
+// If the given error is nil, this field is skipped on encoding.
+func NamedErr(key string, err error) Field {
+	if err == nil {
+		return Field{key: key, fieldType: skipFieldType}
+	}
+	return Field{key: key, fieldType: errorFieldType, iface: err}
+}
+
+// Object creates a field that contains arbitrary value.
+//
+// The value is encoded through `json.Marshal`, so this field doesn't benefit the allocation-less encoding.
+// If the value cannot be encoded, LogFields() returns that error like as Log() does,
+// unless TolerantEncoding is set.
+func Object(key string, value interface{}) Field {
+	return Field{key: key, fieldType: objectFieldType, iface: value}
+}
+
+// RawJSON creates a field that contains pre-encoded JSON value.
+//
+// The value is written into the payload as it is; it must be valid JSON.
+func RawJSON(key string, value []byte) Field {
+	return Field{key: key, fieldType: rawJSONFieldType, iface: value}
+}
+
+// Key returns the key of the field.
+func (f Field) Key() string {
+	return f.key
+}
+
+// fieldsToMessage builds the Message that is equivalent to the fields.
+// This is used when the fields must pass through the Message-based stages, e.g. Redactor.
+func fieldsToMessage(fields []Field) Message {
+	message := make(Message, len(fields))
+	for i := range fields {
+		if fields[i].fieldType == skipFieldType {
+			continue
+		}
+		message[fields[i].key] = fields[i].value()
+	}
+	return message
+}
+
+func (f Field) value() interface{} {
+	switch f.fieldType {
+	case stringFieldType:
+		return f.str
+	case intFieldType:
+		return f.integer
+	case uintFieldType:
+		return uint64(f.integer)
+	case floatFieldType:
+		v := math.Float64frombits(uint64(f.integer))
+		switch {
+		case math.IsNaN(v):
+			return "NaN"
+		case math.IsInf(v, 1):
+			return "+Inf"
+		case math.IsInf(v, -1):
+			return "-Inf"
+		}
+		return v
+	case boolFieldType:
+		return f.integer == 1
+	case durationFieldType:
+		return time.Duration(f.integer)
+	case timeFieldType:
+		return f.timeValue()
+	case errorFieldType:
+		return encodableError(f.iface.(error))
+	case rawJSONFieldType:
+		return json.RawMessage(f.iface.([]byte))
+	case objectFieldType:
+		return f.iface
+	}
+	return nil
+}
+
+var (
+	minUnixNanoTime = time.Unix(0, math.MinInt64)
+	maxUnixNanoTime = time.Unix(0, math.MaxInt64)
+)
+
+func (f Field) timeValue() time.Time {
+	if t, ok := f.iface.(time.Time); ok {
+		return t
+	}
+	t := time.Unix(0, f.integer)
+	if loc, ok := f.iface.(*time.Location); ok && loc != nil {
+		t = t.In(loc)
+	}
+	return t
+}
@@ -0,0 +1,209 @@
+package logger
+
+import (
+	"encoding/json"
+	"math"
+	"strconv"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	initialBufferCapacity = 1024
+	maximumPooledCapacity = 64 * 1024
+)
+
+type buffer struct {
+	bs []byte
+}
+
+var bufferPool = sync.Pool{
+	New: func() interface{} {
+		return &buffer{bs: make([]byte, 0, initialBufferCapacity)}
+	},
+}
+
+func getBuffer() *buffer {
+	buf := bufferPool.Get().(*buffer)
+	buf.bs = buf.bs[:0]
+	return buf
+}
+
+func putBuffer(buf *buffer) {
+	if cap(buf.bs) > maximumPooledCapacity {
+		// Don't keep a huge buffer around
+		return
+	}
+	bufferPool.Put(buf)
+}
+
+// detachBuffer copies the encoded bytes out of the pooled buffer, and recycles the buffer.
+// The bulk loggers keep the messages until the flush. If they kept the pooled buffers instead, each buffer would hold
+// its whole capacity although the threshold counts only the length, and the buffers of the failed messages
+// could not be recycled because those messages are handed over to the caller.
+func detachBuffer(buf *buffer) []byte {
+	body := make([]byte, len(buf.bs))
+	copy(body, buf.bs)
+	putBuffer(buf)
+	return body
+}
+
+func appendFields(dst []byte, fields []Field) ([]byte, error) {
+	dst = append(dst, '{')
+	dst, err := appendFieldEntries(dst, fields, true)
+	if err != nil {
+		return dst, err
+	}
+	return append(dst, '}'), nil
+}
+
+// appendFieldEntries appends the key/value pairs of the fields without braces.
+// `first` must be false if something has been written in the object already.
+func appendFieldEntries(dst []byte, fields []Field, first bool) ([]byte, error) {
+	for i := range fields {
+		f := &fields[i]
+		if f.fieldType == skipFieldType {
+			continue
+		}
+
+		if !first {
+			dst = append(dst, ',')
+		}
+		first = false
+
+		dst = appendJSONString(dst, f.key)
+		dst = append(dst, ':')
+
+		var err error
+		dst, err = appendFieldValue(dst, f)
+		if err != nil {
+			return dst, err
+		}
+	}
+	return dst, nil
+}
+
+func hasFieldKey(fields []Field, key string) bool {
+	for i := range fields {
+		if fields[i].fieldType != skipFieldType && fields[i].key == key {
+			return true
+		}
+	}
+	return false
+}
+
+func appendFieldValue(dst []byte, f *Field) ([]byte, error) {
+	switch f.fieldType {
+	case stringFieldType:
+		return appendJSONString(dst, f.str), nil
+	case intFieldType, durationFieldType:
+		return strconv.AppendInt(dst, f.integer, 10), nil
+	case uintFieldType:
+		return strconv.AppendUint(dst, uint64(f.integer), 10), nil
+	case floatFieldType:
+		return appendJSONFloat(dst, math.Float64frombits(uint64(f.integer))), nil
+	case boolFieldType:
+		return strconv.AppendBool(dst, f.integer == 1), nil
+	case timeFieldType:
+		dst = append(dst, '"')
+		dst = f.timeValue().AppendFormat(dst, time.RFC3339Nano)
+		return append(dst, '"'), nil
+	case errorFieldType:
+		b, err := json.Marshal(encodableError(f.iface.(error)))
+		if err != nil {
+			return dst, err
+		}
+		return append(dst, b...), nil
+	case rawJSONFieldType:
+		return append(dst, f.iface.([]byte)...), nil
+	case objectFieldType:
+		b, err := json.Marshal(f.iface)
+		if err != nil {
+			return dst, err
+		}
+		return append(dst, b...), nil
+	}
+	return append(dst, "null"...), nil
+}
+
+// appendJSONFloat appends the float value as like as `encoding/json` does.
+func appendJSONFloat(dst []byte, f float64) []byte {
+	switch {
+	case math.IsNaN(f):
+		return append(dst, `"NaN"`...)
+	case math.IsInf(f, 1):
+		return append(dst, `"+Inf"`...)
+	case math.IsInf(f, -1):
+		return append(dst, `"-Inf"`...)
+	}
+
+	format := byte('f')
+	if abs := math.Abs(f); abs != 0 && (abs < 1e-6 || abs >= 1e21) {
+		format = 'e'
+	}
+
+	start := len(dst)
+	dst = strconv.AppendFloat(dst, f, format, -1, 64)
+	if format == 'e' {
+		// clean up e-09 to e-9
+		n := len(dst) - start
+		if n >= 4 && dst[len(dst)-4] == 'e' && dst[len(dst)-3] == '-' && dst[len(dst)-2] == '0' {
+			dst[len(dst)-2] = dst[len(dst)-1]
+			dst = dst[:len(dst)-1]
+		}
+	}
+	return dst
+}
+
+const hexDigits = "0123456789abcdef"
+
+// appendJSONString appends the quoted string as like as `encoding/json` does; this escapes HTML characters too.
+func appendJSONString(dst []byte, s string) []byte {
+	dst = append(dst, '"')
+	start := 0
+	for i := 0; i < len(s); {
+		if b := s[i]; b < utf8.RuneSelf {
+			if b >= 0x20 && b != '"' && b != '\\' && b != '<' && b != '>' && b != '&' {
+				i++
+				continue
+			}
+
+			dst = append(dst, s[start:i]...)
+			switch b {
+			case '"', '\\':
+				dst = append(dst, '\\', b)
+			case '\n':
+				dst = append(dst, '\\', 'n')
+			case '\r':
+				dst = append(dst, '\\', 'r')
+			case '\t':
+				dst = append(dst, '\\', 't')
+			default:
+				dst = append(dst, '\\', 'u', '0', '0', hexDigits[b>>4], hexDigits[b&0xF])
+			}
+			i++
+			start = i
+			continue
+		}
+
+		r, size := utf8.DecodeRuneInString(s[i:])
+		if r == utf8.RuneError && size == 1 {
+			dst = append(dst, s[start:i]...)
+			dst = append(dst, `\ufffd`...)
+			i += size
+			start = i
+			continue
+		}
+		if r == '\u2028' || r == '\u2029' {
+			dst = append(dst, s[start:i]...)
+			dst = append(dst, '\\', 'u', '2', '0', '2', hexDigits[r&0xF])
+			i += size
+			start = i
+			continue
+		}
+		i += size
+	}
+	dst = append(dst, s[start:]...)
+	return append(dst, '"')
+}
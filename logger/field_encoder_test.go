@@ -0,0 +1,121 @@
+package logger
+
+import (
+	"encoding/json"
+	"errors"
+	"math"
+	"testing"
+	"time"
+)
+
+func TestAppendFieldsShouldBeCompatibleWithJSONMarshal(t *testing.T) {
+	now := time.Date(2018, 1, 5, 17, 11, 25, 494000000, time.UTC)
+
+	got, err := appendFields(nil, []Field{
+		String("str", "a\"b\\c\n<&> \x01日本"),
+		Int("int", -42),
+		Uint64("uint", math.MaxUint64),
+		Float64("float", 1.5),
+		Float64("small", 0.0000001),
+		Float64("big", 1e21),
+		Bool("t", true),
+		Bool("f", false),
+		Duration("duration", 1500*time.Millisecond),
+		Time("time", now),
+		Object("object", map[string]int{"a": 1}),
+		RawJSON("raw", []byte(`[1,2]`)),
+	})
+	if err != nil {
+		t.Fatal("unexpected err", err)
+	}
+
+	expected, _ := json.Marshal(struct {
+		Str      string         `json:"str"`
+		Int      int            `json:"int"`
+		Uint     uint64         `json:"uint"`
+		Float    float64        `json:"float"`
+		Small    float64        `json:"small"`
+		Big      float64        `json:"big"`
+		T        bool           `json:"t"`
+		F        bool           `json:"f"`
+		Duration time.Duration  `json:"duration"`
+		Time     time.Time      `json:"time"`
+		Object   map[string]int `json:"object"`
+		Raw      []int          `json:"raw"`
+	}{
+		"a\"b\\c\n<&> \x01日本", -42, math.MaxUint64, 1.5, 0.0000001, 1e21, true, false,
+		1500 * time.Millisecond, now, map[string]int{"a": 1}, []int{1, 2},
+	})
+	if string(got) != string(expected) {
+		t.Errorf("got == `%s` but wants `%s`", got, expected)
+	}
+}
+
+func TestAppendFieldsShouldEncodeSpecialValues(t *testing.T) {
+	got, err := appendFields(nil, []Field{
+		Err(nil),
+		Err(errors.New("boom")),
+		Float64("nan", math.NaN()),
+		Float64("inf", math.Inf(1)),
+		Float64("-inf", math.Inf(-1)),
+		String("invalid", "\xff"),
+	})
+	if err != nil {
+		t.Fatal("unexpected err", err)
+	}
+
+	expected := `{"error":{"message":"boom","type":"*errors.errorString"},"nan":"NaN","inf":"+Inf","-inf":"-Inf","invalid":"\ufffd"}`
+	if string(got) != expected {
+		t.Errorf("got == `%s` but wants `%s`", got, expected)
+	}
+}
+
+func TestAppendFieldsShouldReturnErrorOnUnmarshalableObject(t *testing.T) {
+	_, err := appendFields(nil, []Field{Object("ch", make(chan int))})
+	if err == nil {
+		t.Error("err should not be nil, but got nil")
+	}
+}
+
+func TestEncodeFieldsShouldReturnOwnedBytes(t *testing.T) {
+	e := &encoder{}
+	body1, _ := e.encodeFields([]Field{String("k", "v1")})
+	body2, _ := e.encodeFields([]Field{String("k", "v2")})
+
+	if string(body1) != `{"k":"v1"}` {
+		t.Errorf("body1 == `%s` but wants `%s`", body1, `{"k":"v1"}`)
+	}
+	if string(body2) != `{"k":"v2"}` {
+		t.Errorf("body2 == `%s` but wants `%s`", body2, `{"k":"v2"}`)
+	}
+}
+
+func BenchmarkEncodeMessage(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		json.Marshal(Message{
+			"message":  "request finished",
+			"method":   "GET",
+			"status":   200,
+			"duration": 1500 * time.Microsecond,
+			"ok":       true,
+		})
+	}
+}
+
+func BenchmarkAppendFields(b *testing.B) {
+	b.ReportAllocs()
+	buf := getBuffer()
+	defer putBuffer(buf)
+	for i := 0; i < b.N; i++ {
+		buf.bs, _ = appendFields(buf.bs[:0],
+			[]Field{
+				String("message", "request finished"),
+				String("method", "GET"),
+				Int("status", 200),
+				Duration("duration", 1500*time.Microsecond),
+				Bool("ok", true),
+			},
+		)
+	}
+}
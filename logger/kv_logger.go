@@ -0,0 +1,89 @@
+package logger
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// missingValue is the value for the last key of odd-length key/values; this is the same as go-kit's.
+const missingValue = "(MISSING)"
+
+var emptyInterfaceType = reflect.TypeOf((*interface{})(nil)).Elem()
+
+// KVLogger is an adapter of go-kit style logger; this has `Log(keyvals ...interface{}) error` method,
+// so this satisfies go-kit's `log.Logger` interface without importing go-kit.
+//
+// The alternating keys and values are converted into Message as follows;
+//   - the key that is not string is converted by `fmt.Sprint()`, e.g. fmt.Stringer
+//   - if the number of the key/values is odd, the value of the last key is "(MISSING)"
+//   - the value that is a function of `func() interface{}` type (e.g. go-kit's `log.Valuer`) is evaluated lazily at the time of Log()
+//   - if the same key is given twice, the latter one wins
+type KVLogger struct {
+	sink    Sink
+	keyvals []interface{}
+}
+
+// NewKVLogger creates a new KVLogger that sends the messages through the sink.
+func NewKVLogger(sink Sink) *KVLogger {
+	return &KVLogger{
+		sink: sink,
+	}
+}
+
+// Log converts the key/values into Message and sends that through the sink.
+func (l *KVLogger) Log(keyvals ...interface{}) error {
+	message := make(Message, (len(l.keyvals)+len(keyvals)+1)/2)
+	putKeyvals(message, l.keyvals)
+	putKeyvals(message, keyvals)
+	return l.sink.Send(message)
+}
+
+// With returns a new KVLogger that has the contextual key/values in addition.
+// The lazy values are evaluated at the time of each Log(), not With().
+func (l *KVLogger) With(keyvals ...interface{}) *KVLogger {
+	merged := make([]interface{}, 0, len(l.keyvals)+len(keyvals)+1)
+	merged = append(merged, l.keyvals...)
+	merged = append(merged, keyvals...)
+	if len(keyvals)%2 != 0 {
+		merged = append(merged, missingValue)
+	}
+	return &KVLogger{
+		sink:    l.sink,
+		keyvals: merged,
+	}
+}
+
+func putKeyvals(message Message, keyvals []interface{}) {
+	for i := 0; i < len(keyvals); i += 2 {
+		key := kvKey(keyvals[i])
+		if i+1 >= len(keyvals) {
+			message[key] = missingValue
+			break
+		}
+		message[key] = kvValue(keyvals[i+1])
+	}
+}
+
+func kvKey(key interface{}) string {
+	if s, ok := key.(string); ok {
+		return s
+	}
+	return fmt.Sprint(key)
+}
+
+// kvValue evaluates the lazy value; that is the function that takes nothing and returns interface{}.
+func kvValue(value interface{}) interface{} {
+	if f, ok := value.(func() interface{}); ok {
+		return f()
+	}
+
+	v := reflect.ValueOf(value)
+	if v.Kind() != reflect.Func || v.IsNil() {
+		return value
+	}
+	t := v.Type()
+	if t.NumIn() != 0 || t.NumOut() != 1 || t.Out(0) != emptyInterfaceType {
+		return value
+	}
+	return v.Call(nil)[0].Interface()
+}
@@ -0,0 +1,84 @@
+package logger
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+type stringerKey struct{}
+
+func (stringerKey) String() string { return "stringer" }
+
+// valuer is the same type as go-kit's log.Valuer
+type valuer func() interface{}
+
+func TestKVLoggerShouldConvertKeyvals(t *testing.T) {
+	sink := &recordingSink{}
+	l := NewKVLogger(sink)
+
+	err := errors.New("boom")
+	l.Log("message", "hello", 42, "number key", stringerKey{}, true, "err", err, "dangling")
+
+	expected := []Message{{
+		"message":  "hello",
+		"42":       "number key",
+		"stringer": true,
+		"err":      err,
+		"dangling": "(MISSING)",
+	}}
+	if !reflect.DeepEqual(sink.messages, expected) {
+		t.Errorf("messages == %v but wants %v", sink.messages, expected)
+	}
+
+	sink.messages = nil
+	l.Log()
+	if !reflect.DeepEqual(sink.messages, []Message{{}}) {
+		t.Errorf("messages == %v", sink.messages)
+	}
+}
+
+func TestKVLoggerShouldEvaluateValuersLazily(t *testing.T) {
+	sink := &recordingSink{}
+	count := 0
+	counter := valuer(func() interface{} {
+		count++
+		return count
+	})
+	plain := func() interface{} { return "plain" }
+	notValuer := func() string { return "x" }
+
+	l := NewKVLogger(sink).With("count", counter, "plain", plain, "service")
+	if count != 0 {
+		t.Fatal("valuer should not be evaluated on With()")
+	}
+
+	l.Log("message", "first")
+	l.Log("message", "second", "service", "api")
+	l.With("extra", 1).Log("not_valuer", notValuer)
+
+	if sink.messages[0]["count"] != 1 || sink.messages[1]["count"] != 2 || sink.messages[0]["plain"] != "plain" {
+		t.Errorf("messages == %v", sink.messages)
+	}
+	if sink.messages[0]["service"] != "(MISSING)" || sink.messages[1]["service"] != "api" {
+		t.Errorf("messages == %v", sink.messages)
+	}
+	if sink.messages[2]["extra"] != 1 || sink.messages[2]["count"] != 3 {
+		t.Errorf("messages == %v", sink.messages)
+	}
+	if _, ok := sink.messages[2]["not_valuer"].(func() string); !ok {
+		t.Errorf("the function that doesn't return interface{} should not be evaluated: %v", sink.messages[2])
+	}
+}
+
+func TestKVLoggerShouldWorkWithTimestampValuer(t *testing.T) {
+	sink := &recordingSink{}
+	now := time.Date(2018, 1, 2, 3, 4, 5, 0, time.UTC)
+	l := NewKVLogger(sink).With("ts", func() interface{} { return now })
+
+	l.Log("message", "hello")
+	if sink.messages[0]["ts"] != now {
+		t.Errorf("messages == %v", sink.messages)
+	}
+}
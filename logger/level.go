@@ -0,0 +1,192 @@
+package logger
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"sync/atomic"
+)
+
+// Level is a severity of the message.
+type Level int32
+
+const (
+	// DebugLevel is the level for verbose messages that are usually disabled on the production.
+	DebugLevel Level = iota
+	// InfoLevel is the level for informational messages. This is the default level.
+	InfoLevel
+	// WarnLevel is the level for messages that are not errors but should be cared.
+	WarnLevel
+	// ErrorLevel is the level for errors.
+	ErrorLevel
+	// FatalLevel is the level for errors that the process cannot continue.
+	FatalLevel
+)
+
+var levelNames = [...]string{"debug", "info", "warn", "error", "fatal"}
+
+// String returns the lower-case name of the level, e.g. "info".
+func (l Level) String() string {
+	if !l.valid() {
+		return fmt.Sprintf("Level(%d)", int32(l))
+	}
+	return levelNames[l]
+}
+
+// MarshalText marshals the level as the name.
+func (l Level) MarshalText() ([]byte, error) {
+	if !l.valid() {
+		return nil, fmt.Errorf("invalid level [given: %d]", int32(l))
+	}
+	return []byte(l.String()), nil
+}
+
+// UnmarshalText unmarshals the name of the level. See also ParseLevel().
+func (l *Level) UnmarshalText(text []byte) error {
+	level, err := ParseLevel(string(text))
+	if err != nil {
+		return err
+	}
+	*l = level
+	return nil
+}
+
+func (l Level) valid() bool {
+	return DebugLevel <= l && l <= FatalLevel
+}
+
+// ParseLevel parses the name of the level case-insensitively. "warning" is also accepted as WarnLevel.
+func ParseLevel(name string) (Level, error) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	if name == "warning" {
+		return WarnLevel, nil
+	}
+	for i, levelName := range levelNames {
+		if name == levelName {
+			return Level(i), nil
+		}
+	}
+	return 0, fmt.Errorf("unknown level [given: %s]", name)
+}
+
+// AtomicLevel is a minimum level that can be changed at runtime safely.
+//
+// This also works as http.Handler to inspect and change the level;
+// GET returns the current level like `{"level":"info"}`,
+// and PUT/POST changes the level by the JSON body like `{"level":"debug"}` or the form value of `level`.
+type AtomicLevel struct {
+	level int32
+}
+
+// NewAtomicLevel creates an instance of AtomicLevel with the initial level.
+func NewAtomicLevel(level Level) *AtomicLevel {
+	return &AtomicLevel{
+		level: int32(level),
+	}
+}
+
+// Level returns the current minimum level.
+func (a *AtomicLevel) Level() Level {
+	return Level(atomic.LoadInt32(&a.level))
+}
+
+// SetLevel changes the minimum level.
+func (a *AtomicLevel) SetLevel(level Level) {
+	atomic.StoreInt32(&a.level, int32(level))
+}
+
+// Enabled returns whether the given level is greater than or equal to the minimum level.
+func (a *AtomicLevel) Enabled(level Level) bool {
+	return level >= a.Level()
+}
+
+// SetLevelOnSignal changes the minimum level when the process receives the signal.
+//
+// e.g.
+//
+//	stop := level.SetLevelOnSignal(map[os.Signal]logger.Level{
+//	    syscall.SIGUSR1: logger.DebugLevel,
+//	    syscall.SIGUSR2: logger.InfoLevel,
+//	})
+//	defer stop()
+//
+// The returned function stops the watching.
+func (a *AtomicLevel) SetLevelOnSignal(levels map[os.Signal]Level) func() {
+	sigChan := make(chan os.Signal, 1)
+	stopChan := make(chan struct{})
+
+	signals := make([]os.Signal, 0, len(levels))
+	for sig := range levels {
+		signals = append(signals, sig)
+	}
+	signal.Notify(sigChan, signals...)
+
+	go func() {
+		for {
+			select {
+			case sig := <-sigChan:
+				if level, ok := levels[sig]; ok {
+					a.SetLevel(level)
+				}
+			case <-stopChan:
+				return
+			}
+		}
+	}()
+
+	return func() {
+		signal.Stop(sigChan)
+		close(stopChan)
+	}
+}
+
+type levelPayload struct {
+	Level *Level `json:"level"`
+}
+
+// ServeHTTP inspects or changes the minimum level.
+func (a *AtomicLevel) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		// NOP
+	case http.MethodPut, http.MethodPost:
+		level, err := a.levelFromRequest(r)
+		if err != nil {
+			writeLevelError(w, http.StatusBadRequest, err)
+			return
+		}
+		a.SetLevel(level)
+	default:
+		w.Header().Set("Allow", "GET, PUT, POST")
+		writeLevelError(w, http.StatusMethodNotAllowed, fmt.Errorf("method not allowed [given: %s]", r.Method))
+		return
+	}
+
+	current := a.Level()
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(levelPayload{Level: &current})
+}
+
+func (a *AtomicLevel) levelFromRequest(r *http.Request) (Level, error) {
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "application/json") {
+		var payload levelPayload
+		if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+			return 0, err
+		}
+		if payload.Level == nil {
+			return 0, fmt.Errorf("level is missing")
+		}
+		return *payload.Level, nil
+	}
+
+	return ParseLevel(r.FormValue("level"))
+}
+
+func writeLevelError(w http.ResponseWriter, status int, err error) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+}
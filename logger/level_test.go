@@ -0,0 +1,101 @@
+package logger
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseLevel(t *testing.T) {
+	cases := map[string]Level{
+		"debug":   DebugLevel,
+		"INFO":    InfoLevel,
+		"warn":    WarnLevel,
+		"warning": WarnLevel,
+		" error ": ErrorLevel,
+		"fatal":   FatalLevel,
+	}
+	for given, expected := range cases {
+		got, err := ParseLevel(given)
+		if err != nil {
+			t.Error("unexpected err", err)
+		}
+		if got != expected {
+			t.Errorf("got == %v but wants %v", got, expected)
+		}
+	}
+
+	if _, err := ParseLevel("verbose"); err == nil {
+		t.Error("err should not be nil, but got nil")
+	}
+}
+
+func TestAtomicLevelServeHTTP(t *testing.T) {
+	level := NewAtomicLevel(InfoLevel)
+
+	rec := httptest.NewRecorder()
+	level.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/level", nil))
+	if body := strings.TrimSpace(rec.Body.String()); body != `{"level":"info"}` {
+		t.Errorf("body == `%s` but wants `%s`", body, `{"level":"info"}`)
+	}
+
+	req := httptest.NewRequest(http.MethodPut, "/level", strings.NewReader(`{"level":"debug"}`))
+	req.Header.Set("Content-Type", "application/json")
+	rec = httptest.NewRecorder()
+	level.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Errorf("status == %d but wants %d", rec.Code, http.StatusOK)
+	}
+	if level.Level() != DebugLevel {
+		t.Errorf("level == %v but wants %v", level.Level(), DebugLevel)
+	}
+
+	req = httptest.NewRequest(http.MethodPost, "/level", strings.NewReader("level=error"))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	rec = httptest.NewRecorder()
+	level.ServeHTTP(rec, req)
+	if level.Level() != ErrorLevel {
+		t.Errorf("level == %v but wants %v", level.Level(), ErrorLevel)
+	}
+
+	req = httptest.NewRequest(http.MethodPut, "/level", strings.NewReader(`{"level":"verbose"}`))
+	req.Header.Set("Content-Type", "application/json")
+	rec = httptest.NewRecorder()
+	level.ServeHTTP(rec, req)
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("status == %d but wants %d", rec.Code, http.StatusBadRequest)
+	}
+	if level.Level() != ErrorLevel {
+		t.Errorf("level == %v but wants %v", level.Level(), ErrorLevel)
+	}
+
+	rec = httptest.NewRecorder()
+	level.ServeHTTP(rec, httptest.NewRequest(http.MethodDelete, "/level", nil))
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("status == %d but wants %d", rec.Code, http.StatusMethodNotAllowed)
+	}
+}
+
+func TestAtomicLevelSetLevelOnSignal(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("sending signal is not supported")
+	}
+
+	level := NewAtomicLevel(InfoLevel)
+	stop := level.SetLevelOnSignal(map[os.Signal]Level{os.Interrupt: DebugLevel})
+	defer stop()
+
+	p, _ := os.FindProcess(os.Getpid())
+	p.Signal(os.Interrupt)
+
+	for i := 0; i < 100 && level.Level() != DebugLevel; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if level.Level() != DebugLevel {
+		t.Errorf("level == %v but wants %v", level.Level(), DebugLevel)
+	}
+}
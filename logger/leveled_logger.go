@@ -0,0 +1,130 @@
+package logger
+
+import "fmt"
+
+const defaultLevelKey = "level"
+
+// LeveledLogger is a wrapper of any logger that has the severity management.
+//
+// This logger adds the level field (`"level": "info"` by default) into the message,
+// and discards the message that is less than the minimum level.
+// The minimum level is AtomicLevel, so it can be changed at runtime (e.g. via HTTP handler or signal).
+//
+// And this logger can route the messages to the different logger for each level;
+// e.g. errors are sent through SyncLogger, and debug messages are buffered by AsyncBulkLogger.
+type LeveledLogger struct {
+	sinks    [FatalLevel + 1]Sink
+	level    *AtomicLevel
+	levelKey string
+}
+
+// NewLeveledLogger creates an instance of LeveledLogger.
+//
+// `sink` is the underlying logger for all levels; please use Route() to change that for each level.
+// `level` is the minimum level. If that is nil, the minimum level is InfoLevel.
+func NewLeveledLogger(sink Sink, level *AtomicLevel) *LeveledLogger {
+	if level == nil {
+		level = NewAtomicLevel(InfoLevel)
+	}
+
+	l := &LeveledLogger{
+		level:    level,
+		levelKey: defaultLevelKey,
+	}
+	for i := range l.sinks {
+		l.sinks[i] = sink
+	}
+	return l
+}
+
+// With returns a derived logger that merges the fields into each message, like as BoundLogger does.
+// The derived logger shares the minimum level and the underlying loggers with this logger.
+func (l *LeveledLogger) With(fields Message) *LeveledLogger {
+	child := *l
+	for i, sink := range child.sinks {
+		if bound, ok := sink.(*BoundLogger); ok {
+			child.sinks[i] = bound.With(fields)
+			continue
+		}
+		child.sinks[i] = NewBoundLogger(sink, fields)
+	}
+	return &child
+}
+
+// SetLevelKey changes the name of the level field. The default is "level".
+//
+// NOTE: this method is not goroutine-safe. Please configure the logger before logging.
+func (l *LeveledLogger) SetLevelKey(key string) {
+	l.levelKey = key
+}
+
+// Route routes the messages of the level to the sink.
+//
+// NOTE: this method is not goroutine-safe. Please configure the logger before logging.
+func (l *LeveledLogger) Route(level Level, sink Sink) {
+	if !level.valid() {
+		return
+	}
+	l.sinks[level] = sink
+}
+
+// AtomicLevel returns the minimum level. Changing this affects this logger immediately.
+func (l *LeveledLogger) AtomicLevel() *AtomicLevel {
+	return l.level
+}
+
+// Enabled returns whether the message of the level is logged or not.
+func (l *LeveledLogger) Enabled(level Level) bool {
+	return l.level.Enabled(level)
+}
+
+// Log logs the message with the level.
+//
+// If the level is less than the minimum level, this method discards the message and returns nil.
+// The level field is always set by this logger; if the message has the field of the same name, that is overwritten.
+// The given message is not modified.
+func (l *LeveledLogger) Log(level Level, message Message) error {
+	if !level.valid() {
+		return fmt.Errorf("invalid level [given: %d]", int32(level))
+	}
+
+	if !l.Enabled(level) {
+		return nil
+	}
+
+	leveled := make(Message, len(message)+1)
+	for k, v := range message {
+		leveled[k] = v
+	}
+	leveled[l.levelKey] = level.String()
+
+	return l.sinks[level].Send(leveled)
+}
+
+// Debug logs the message with DebugLevel.
+func (l *LeveledLogger) Debug(message Message) error {
+	return l.Log(DebugLevel, message)
+}
+
+// Info logs the message with InfoLevel.
+func (l *LeveledLogger) Info(message Message) error {
+	return l.Log(InfoLevel, message)
+}
+
+// Warn logs the message with WarnLevel.
+func (l *LeveledLogger) Warn(message Message) error {
+	return l.Log(WarnLevel, message)
+}
+
+// Error logs the message with ErrorLevel.
+func (l *LeveledLogger) Error(message Message) error {
+	return l.Log(ErrorLevel, message)
+}
+
+// Fatal logs the message with FatalLevel.
+//
+// This method doesn't terminate the process, because asynchronous loggers must be flushed before exiting.
+// Please exit by yourself after shutting down the loggers.
+func (l *LeveledLogger) Fatal(message Message) error {
+	return l.Log(FatalLevel, message)
+}
@@ -0,0 +1,70 @@
+package logger
+
+import "testing"
+
+type recordingSink struct {
+	messages []Message
+}
+
+func (s *recordingSink) Send(message Message) error {
+	s.messages = append(s.messages, message)
+	return nil
+}
+
+func TestLeveledLoggerShouldFilterByMinimumLevel(t *testing.T) {
+	sink := &recordingSink{}
+	l := NewLeveledLogger(sink, nil)
+
+	l.Debug(Message{"message": "debug"})
+	l.Info(Message{"message": "info"})
+	l.Error(Message{"message": "error", "level": "overwritten"})
+
+	if len(sink.messages) != 2 {
+		t.Fatalf("len(messages) == %d but wants %d", len(sink.messages), 2)
+	}
+	if sink.messages[0]["level"] != "info" {
+		t.Errorf("level == %v but wants %v", sink.messages[0]["level"], "info")
+	}
+	if sink.messages[1]["level"] != "error" {
+		t.Errorf("level == %v but wants %v", sink.messages[1]["level"], "error")
+	}
+
+	l.AtomicLevel().SetLevel(DebugLevel)
+	l.Debug(Message{"message": "debug"})
+	if len(sink.messages) != 3 {
+		t.Errorf("len(messages) == %d but wants %d", len(sink.messages), 3)
+	}
+}
+
+func TestLeveledLoggerShouldRouteByLevel(t *testing.T) {
+	defaultSink := &recordingSink{}
+	errorSink := &recordingSink{}
+
+	l := NewLeveledLogger(defaultSink, NewAtomicLevel(DebugLevel))
+	l.SetLevelKey("severity")
+	l.Route(ErrorLevel, errorSink)
+	l.Route(FatalLevel, errorSink)
+
+	given := Message{"message": "msg"}
+	l.Debug(given)
+	l.Warn(given)
+	l.Error(given)
+	l.Fatal(given)
+
+	if len(defaultSink.messages) != 2 {
+		t.Errorf("len(defaultSink.messages) == %d but wants %d", len(defaultSink.messages), 2)
+	}
+	if len(errorSink.messages) != 2 {
+		t.Errorf("len(errorSink.messages) == %d but wants %d", len(errorSink.messages), 2)
+	}
+	if errorSink.messages[1]["severity"] != "fatal" {
+		t.Errorf("severity == %v but wants %v", errorSink.messages[1]["severity"], "fatal")
+	}
+	if _, ok := given["severity"]; ok {
+		t.Error("given message should not be modified")
+	}
+
+	if err := l.Log(Level(42), given); err == nil {
+		t.Error("err should not be nil, but got nil")
+	}
+}
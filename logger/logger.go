@@ -6,6 +6,24 @@ var newlineCharByte = []byte{10}
 // Message is a structure that represents the message payload.
 type Message map[string]interface{}
 
+// Sink is an interface that sends the message somewhere.
+//
+// All loggers of this package implement this through Send() method,
+// so the wrappers of this package (e.g. LeveledLogger) work over any logger.
+type Sink interface {
+	// Send sends the message and returns the foreground error.
+	// Asynchronous loggers don't wait for the result of the background processing.
+	Send(message Message) error
+}
+
+// SinkFunc is an adapter to allow the use of ordinary functions as Sink.
+type SinkFunc func(message Message) error
+
+// Send calls f(message).
+func (f SinkFunc) Send(message Message) error {
+	return f(message)
+}
+
 // AsyncBulkResult is a result structure of asynchronously bulk API calling.
 type AsyncBulkResult struct {
 	// AsyncErrChan is a channel that notifies the error of asynchronously processing.
@@ -0,0 +1,43 @@
+package logger
+
+import "time"
+
+// periodicReporter calls the report function periodically on the background goroutine.
+type periodicReporter struct {
+	stopChan    chan struct{}
+	stoppedChan chan struct{}
+}
+
+func startPeriodicReporter(interval time.Duration, report func()) *periodicReporter {
+	r := &periodicReporter{
+		stopChan:    make(chan struct{}, 1),
+		stoppedChan: make(chan struct{}, 1),
+	}
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+	loop:
+		for {
+			select {
+			case <-ticker.C:
+				report()
+			case <-r.stopChan:
+				break loop
+			}
+		}
+
+		// Report the remained one
+		report()
+		r.stoppedChan <- notifier
+	}()
+
+	return r
+}
+
+// stop stops the reporter and waits for the last report.
+func (r *periodicReporter) stop() {
+	r.stopChan <- notifier
+	<-r.stoppedChan
+}
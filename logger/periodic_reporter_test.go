@@ -0,0 +1,27 @@
+package logger
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestPeriodicReporter(t *testing.T) {
+	var count int32
+	r := startPeriodicReporter(10*time.Millisecond, func() {
+		atomic.AddInt32(&count, 1)
+	})
+
+	time.Sleep(55 * time.Millisecond)
+	r.stop()
+
+	got := atomic.LoadInt32(&count)
+	if got < 2 {
+		t.Errorf("count == %d but it should be reported periodically", got)
+	}
+
+	time.Sleep(30 * time.Millisecond)
+	if atomic.LoadInt32(&count) != got {
+		t.Error("reporter should not report after stopping")
+	}
+}
@@ -0,0 +1,79 @@
+package logger
+
+import "errors"
+
+// errMessageDropped is notified by the encoder when the processor drops the message.
+// Loggers treat that as success; nothing is sent.
+var errMessageDropped = errors.New("message is dropped by the processor")
+
+// Processor processes the message between Log() and encoding; e.g. enrichment, filtering and transformation.
+//
+// Processor can mutate the given message, or return another one.
+// If the second return value is false, the message is dropped and the logger sends nothing.
+//
+// The given message is a shallow copy of the message that is passed to Log(),
+// so mutating the top-level fields doesn't affect the caller. Nested maps are not copied.
+//
+// Please use this through SetProcessors() of the logger.
+type Processor func(message Message) (Message, bool)
+
+// StaticFields returns the processor that adds the fields into each message.
+// The fields that the message already has are not overwritten.
+func StaticFields(fields Message) Processor {
+	return func(message Message) (Message, bool) {
+		for k, v := range fields {
+			if _, ok := message[k]; !ok {
+				message[k] = v
+			}
+		}
+		return message, true
+	}
+}
+
+// RenameField returns the processor that renames the field.
+// If the message doesn't have the field, the message is not changed. If the message has the field of the new name, that is overwritten.
+func RenameField(from string, to string) Processor {
+	return func(message Message) (Message, bool) {
+		if v, ok := message[from]; ok {
+			delete(message, from)
+			message[to] = v
+		}
+		return message, true
+	}
+}
+
+// RemoveFields returns the processor that removes the fields from each message.
+func RemoveFields(keys ...string) Processor {
+	return func(message Message) (Message, bool) {
+		for _, key := range keys {
+			delete(message, key)
+		}
+		return message, true
+	}
+}
+
+// FilterMessages returns the processor that keeps only the messages that the predicate returns true for.
+func FilterMessages(keep func(message Message) bool) Processor {
+	return func(message Message) (Message, bool) {
+		return message, keep(message)
+	}
+}
+
+func processMessage(processors []Processor, message Message) (Message, bool) {
+	processed := make(Message, len(message))
+	for k, v := range message {
+		processed[k] = v
+	}
+
+	for _, process := range processors {
+		var ok bool
+		processed, ok = process(processed)
+		if !ok {
+			return nil, false
+		}
+		if processed == nil {
+			processed = Message{}
+		}
+	}
+	return processed, true
+}
@@ -0,0 +1,116 @@
+package logger
+
+import (
+	"testing"
+
+	"github.com/moznion/logglily/internal/api"
+)
+
+func TestProcessMessageShouldApplyInOrder(t *testing.T) {
+	processors := []Processor{
+		StaticFields(Message{"service": "api", "msg": "static"}),
+		RenameField("msg", "message"),
+		RemoveFields("internal"),
+	}
+
+	given := Message{"msg": "hello", "internal": true}
+	got, ok := processMessage(processors, given)
+	if !ok {
+		t.Fatal("message should not be dropped")
+	}
+
+	if got["message"] != "hello" || got["service"] != "api" || len(got) != 2 {
+		t.Errorf("got == %v", got)
+	}
+	if given["msg"] != "hello" || given["internal"] != true || len(given) != 2 {
+		t.Errorf("given message should not be modified: %v", given)
+	}
+}
+
+func TestProcessMessageShouldDropAndReplace(t *testing.T) {
+	processors := []Processor{
+		FilterMessages(func(message Message) bool {
+			return message["level"] != "debug"
+		}),
+		func(message Message) (Message, bool) {
+			return Message{"replaced": true}, true
+		},
+	}
+
+	if _, ok := processMessage(processors, Message{"level": "debug"}); ok {
+		t.Error("message should be dropped")
+	}
+
+	got, ok := processMessage(processors, Message{"level": "info"})
+	if !ok || got["replaced"] != true || len(got) != 1 {
+		t.Errorf("got == %v", got)
+	}
+}
+
+func TestSyncBulkLoggerWithProcessors(t *testing.T) {
+	l, _ := NewSyncBulkLogger([]string{"test-tag"}, "test-token", true, 1024, 0)
+	l.APIClient = &api.DummyNopClient{}
+	l.SetProcessors(
+		FilterMessages(func(message Message) bool {
+			return message["drop"] != true
+		}),
+		StaticFields(Message{"service": "api"}),
+	)
+
+	result, err := l.Log(Message{"message": "dropped", "drop": true})
+	if err != nil || result.FailedMessages != nil {
+		t.Errorf("dropped message should be succeeded: %v, %v", result, err)
+	}
+	l.LogFields(String("message", "dropped"), Bool("drop", true))
+	l.Log(Message{"message": "msg1"})
+	l.LogFields(String("message", "msg2"))
+
+	if len(l.logs) != 2 {
+		t.Fatalf("len(l.logs) == %d but wants %d", len(l.logs), 2)
+	}
+	expected := []string{`{"message":"msg1","service":"api"}`, `{"message":"msg2","service":"api"}`}
+	for i, e := range expected {
+		if string(l.logs[i]) != e {
+			t.Errorf("l.logs[%d] == `%s` but wants `%s`", i, l.logs[i], e)
+		}
+	}
+}
+
+func TestAsyncBulkLoggerShouldNotifyDroppedMessageAsSuccess(t *testing.T) {
+	l, _ := NewAsyncBulkLogger([]string{"test-tag"}, "test-token", true, 1024, 0)
+	l.APIClient = &api.DummyErrClient{}
+	l.SetProcessors(FilterMessages(func(message Message) bool {
+		return false
+	}))
+
+	result, err := l.Log(Message{"message": "dropped"})
+	if err != nil {
+		t.Error("unexpected err", err)
+	}
+	if err := <-result.AsyncErrChan; err != nil {
+		t.Error("unexpected err", err)
+	}
+	if failedMessages := <-result.FailedMessagesChan; failedMessages != nil {
+		t.Errorf("failedMessages == %v but wants nil", failedMessages)
+	}
+	if len(l.logs) != 0 {
+		t.Errorf("len(l.logs) == %d but wants %d", len(l.logs), 0)
+	}
+}
+
+func TestAsyncPoolLoggerShouldNotifyDroppedMessageAsSuccess(t *testing.T) {
+	l := NewAsyncPoolLogger([]string{"test-tag"}, "test-token", true, 1, 10)
+	l.APIClient = &api.DummyErrClient{}
+	l.SetProcessors(FilterMessages(func(message Message) bool {
+		return false
+	}))
+
+	result, err := l.LogFields(String("message", "dropped"))
+	if err != nil {
+		t.Error("unexpected err", err)
+	}
+	if err := <-result.AsyncErrChan; err != nil {
+		t.Error("unexpected err", err)
+	}
+	<-l.Shutdown()
+}
@@ -0,0 +1,221 @@
+package logger
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"github.com/moznion/logglily/internal/ratelimit"
+)
+
+// encodingSink is implemented by the loggers of this package.
+// RateLimiter encodes the message by the logger to measure the bytes, and sends that body as it is.
+type encodingSink interface {
+	encodeMessage(message Message) ([]byte, error)
+	sendEncoded(body []byte) error
+}
+
+// RateLimitMode is the behavior of RateLimiter when the rate limit is exceeded.
+type RateLimitMode int
+
+const (
+	// RateLimitBlock blocks Log() until the message can be sent.
+	RateLimitBlock RateLimitMode = iota
+	// RateLimitDrop drops the message.
+	RateLimitDrop
+	// RateLimitSpill sends the message to the spill logger instead. Please see also SetSpillSink().
+	RateLimitSpill
+)
+
+// RateLimiter is a wrapper of any logger that limits the rate of messages by the token bucket algorithm.
+//
+// The rate limit is configurable in events per second and bytes per second;
+// bytes are measured by the size of the body that the underlying logger encodes,
+// i.e. after the timestamper, the processors and the redactor of that logger.
+// If the underlying logger is not a logger of this package (e.g. BoundLogger or SinkFunc),
+// bytes are measured by the size of the JSON encoded message instead.
+// When the rate limit is exceeded, RateLimiter blocks, drops or spills the message according to the mode.
+//
+// The dropped and spilled messages are counted as suppressed messages;
+// StartSuppressionReport() emits that count periodically as a message.
+type RateLimiter struct {
+	// counters are accessed atomically; keep them 64-bit aligned at the head of the struct
+	suppressed uint64
+	spilled    uint64
+	sink       Sink
+	spill      Sink
+	events     *ratelimit.TokenBucket
+	bytes      *ratelimit.TokenBucket
+	mode       RateLimitMode
+	reporter   *periodicReporter
+}
+
+// NewRateLimiter creates an instance of RateLimiter.
+//
+// `eventsPerSecond` and `bytesPerSecond` are the rate limits. If the value is less or equal to 0, that is not limited.
+// The burst is one second of the rate; please see also SetBurst().
+func NewRateLimiter(sink Sink, eventsPerSecond float64, bytesPerSecond float64, mode RateLimitMode) *RateLimiter {
+	r := &RateLimiter{
+		sink: sink,
+		mode: mode,
+	}
+	if eventsPerSecond > 0 {
+		r.events = ratelimit.NewTokenBucket(eventsPerSecond, eventsPerSecond)
+	}
+	if bytesPerSecond > 0 {
+		r.bytes = ratelimit.NewTokenBucket(bytesPerSecond, bytesPerSecond)
+	}
+	return r
+}
+
+// SetBurst changes the maximum burst of events and bytes. If the value is less or equal to 0, that is not changed.
+//
+// NOTE: this method is not goroutine-safe. Please configure the rate limiter before logging.
+func (r *RateLimiter) SetBurst(events float64, bytes float64) {
+	if r.events != nil && events > 0 {
+		r.events = ratelimit.NewTokenBucket(r.events.Rate(), events)
+	}
+	if r.bytes != nil && bytes > 0 {
+		r.bytes = ratelimit.NewTokenBucket(r.bytes.Rate(), bytes)
+	}
+}
+
+// SetSpillSink sets the logger that receives the messages over the rate limit on RateLimitSpill mode.
+// If this is not set, the messages are dropped.
+//
+// NOTE: this method is not goroutine-safe. Please configure the rate limiter before logging.
+func (r *RateLimiter) SetSpillSink(sink Sink) {
+	r.spill = sink
+}
+
+// Log sends the message through the underlying logger if the message is within the rate limit.
+//
+// On RateLimitBlock mode, this method blocks until the message is within the rate limit.
+// On the other modes, this method returns nil if the message is over the rate limit.
+func (r *RateLimiter) Log(message Message) error {
+	size := 0.0
+	send := func() error {
+		return r.sink.Send(message)
+	}
+	if r.bytes != nil {
+		if sink, ok := r.sink.(encodingSink); ok {
+			// Encode the message once by the logger, and send that encoded body
+			body, err := sink.encodeMessage(message)
+			if err == errMessageDropped {
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+			size = float64(len(body))
+			send = func() error {
+				return sink.sendEncoded(body)
+			}
+		} else {
+			size = r.measure(message)
+		}
+	}
+
+	if r.mode == RateLimitBlock {
+		r.wait(size)
+		return send()
+	}
+
+	if r.allow(size) {
+		return send()
+	}
+
+	atomic.AddUint64(&r.suppressed, 1)
+	if r.mode == RateLimitSpill && r.spill != nil {
+		atomic.AddUint64(&r.spilled, 1)
+		return r.spill.Send(message)
+	}
+	return nil
+}
+
+// Send is the same as Log(). This makes RateLimiter satisfy Sink.
+func (r *RateLimiter) Send(message Message) error {
+	return r.Log(message)
+}
+
+// Suppressed returns the number of the dropped and spilled messages that are not reported yet.
+func (r *RateLimiter) Suppressed() uint64 {
+	return atomic.LoadUint64(&r.suppressed)
+}
+
+// StartSuppressionReport starts emitting the count of the suppressed messages periodically, e.g.
+//
+//	{"message":"120 events suppressed by rate limit","suppressed":120,"spilled":0}
+//
+// The report is sent through the underlying logger regardless of the rate limit.
+// That is emitted only when something is suppressed, and the count is reset after that.
+// If the report has been started already, this method does nothing.
+func (r *RateLimiter) StartSuppressionReport(interval time.Duration) {
+	if r.reporter != nil {
+		return
+	}
+	r.reporter = startPeriodicReporter(interval, func() {
+		r.emitSuppressionReport()
+	})
+}
+
+// StopSuppressionReport stops emitting the report. The remained count is emitted before stopping.
+func (r *RateLimiter) StopSuppressionReport() {
+	if r.reporter == nil {
+		return
+	}
+	r.reporter.stop()
+	r.reporter = nil
+}
+
+func (r *RateLimiter) measure(message Message) float64 {
+	body, err := json.Marshal(message)
+	if err != nil {
+		// Let the underlying logger report the error
+		return 0
+	}
+	return float64(len(body))
+}
+
+func (r *RateLimiter) wait(size float64) {
+	var d time.Duration
+	if r.events != nil {
+		d = r.events.Reserve(1)
+	}
+	if r.bytes != nil {
+		if bytesWait := r.bytes.Reserve(size); bytesWait > d {
+			d = bytesWait
+		}
+	}
+	if d > 0 {
+		time.Sleep(d)
+	}
+}
+
+func (r *RateLimiter) allow(size float64) bool {
+	if r.events != nil && !r.events.Allow(1) {
+		return false
+	}
+	if r.bytes != nil && !r.bytes.Allow(size) {
+		if r.events != nil {
+			r.events.Return(1)
+		}
+		return false
+	}
+	return true
+}
+
+func (r *RateLimiter) emitSuppressionReport() error {
+	suppressed := atomic.SwapUint64(&r.suppressed, 0)
+	spilled := atomic.SwapUint64(&r.spilled, 0)
+	if suppressed <= 0 {
+		return nil
+	}
+
+	return r.sink.Send(Message{
+		"message":    fmt.Sprintf("%d events suppressed by rate limit", suppressed),
+		"suppressed": suppressed,
+		"spilled":    spilled,
+	})
+}
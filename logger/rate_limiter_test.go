@@ -0,0 +1,126 @@
+package logger
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/moznion/logglily/internal/api"
+)
+
+func TestRateLimiterShouldDropOverTheEventsRate(t *testing.T) {
+	sink := &recordingSink{}
+	r := NewRateLimiter(sink, 2, 0, RateLimitDrop)
+
+	now := time.Date(2018, 1, 5, 17, 11, 25, 0, time.UTC)
+	r.events.SetClock(func() time.Time {
+		return now
+	})
+
+	for i := 0; i < 5; i++ {
+		if err := r.Log(Message{"message": "hello"}); err != nil {
+			t.Error("unexpected err", err)
+		}
+	}
+	if len(sink.messages) != 2 {
+		t.Errorf("len(messages) == %d but wants %d", len(sink.messages), 2)
+	}
+	if r.Suppressed() != 3 {
+		t.Errorf("Suppressed() == %d but wants %d", r.Suppressed(), 3)
+	}
+
+	now = now.Add(time.Second)
+	r.Log(Message{"message": "hello"})
+	if len(sink.messages) != 3 {
+		t.Errorf("len(messages) == %d but wants %d", len(sink.messages), 3)
+	}
+
+	r.StartSuppressionReport(time.Hour)
+	r.StopSuppressionReport()
+
+	report := sink.messages[len(sink.messages)-1]
+	if report["suppressed"] != uint64(3) {
+		t.Errorf("suppressed == %v but wants %v", report["suppressed"], 3)
+	}
+	if r.Suppressed() != 0 {
+		t.Errorf("Suppressed() == %d but wants %d", r.Suppressed(), 0)
+	}
+}
+
+func TestRateLimiterShouldSpillOverTheBytesRate(t *testing.T) {
+	sink := &recordingSink{}
+	spill := &recordingSink{}
+
+	// {"message":"hello"} is 19 bytes
+	r := NewRateLimiter(sink, 100, 40, RateLimitSpill)
+	r.SetSpillSink(spill)
+
+	now := time.Date(2018, 1, 5, 17, 11, 25, 0, time.UTC)
+	clock := func() time.Time {
+		return now
+	}
+	r.events.SetClock(clock)
+	r.bytes.SetClock(clock)
+
+	for i := 0; i < 3; i++ {
+		r.Log(Message{"message": "hello"})
+	}
+	if len(sink.messages) != 2 {
+		t.Errorf("len(messages) == %d but wants %d", len(sink.messages), 2)
+	}
+	if len(spill.messages) != 1 {
+		t.Errorf("len(spill.messages) == %d but wants %d", len(spill.messages), 1)
+	}
+
+	// The token of events should be returned when bytes are over the limit.
+	now = now.Add(time.Second)
+	for i := 0; i < 2; i++ {
+		r.Log(Message{"message": "hello"})
+	}
+	if len(sink.messages) != 4 {
+		t.Errorf("len(messages) == %d but wants %d", len(sink.messages), 4)
+	}
+}
+
+func TestRateLimiterShouldBlockOverTheRate(t *testing.T) {
+	sink := &recordingSink{}
+	r := NewRateLimiter(sink, 20, 0, RateLimitBlock)
+	r.SetBurst(1, 0)
+
+	begin := time.Now()
+	for i := 0; i < 3; i++ {
+		r.Log(Message{"message": "hello"})
+	}
+	elapsed := time.Since(begin)
+
+	if len(sink.messages) != 3 {
+		t.Errorf("len(messages) == %d but wants %d", len(sink.messages), 3)
+	}
+	if elapsed < 90*time.Millisecond {
+		t.Errorf("elapsed == %v but it should be blocked", elapsed)
+	}
+	if r.Suppressed() != 0 {
+		t.Errorf("Suppressed() == %d but wants %d", r.Suppressed(), 0)
+	}
+}
+
+func TestRateLimiterShouldMeasureBodyEncodedByLogger(t *testing.T) {
+	l := NewSyncLogger([]string{"test-tag"}, "test-token", true)
+	l.APIClient = &api.DummyNopClient{}
+	l.SetProcessors(StaticFields(Message{"padding": strings.Repeat("x", 50)}))
+
+	// {"message":"hello"} is 19 bytes, but the encoded body is 82 bytes with the padding
+	r := NewRateLimiter(l, 0, 100, RateLimitDrop)
+	r.bytes.SetClock(func() time.Time {
+		return time.Date(2018, 1, 5, 17, 11, 25, 0, time.UTC)
+	})
+
+	for i := 0; i < 2; i++ {
+		if err := r.Log(Message{"message": "hello"}); err != nil {
+			t.Error("unexpected err", err)
+		}
+	}
+	if r.Suppressed() != 1 {
+		t.Errorf("Suppressed() == %d but wants %d", r.Suppressed(), 1)
+	}
+}
@@ -0,0 +1,284 @@
+package logger
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+)
+
+const defaultRedactionMask = "[REDACTED]"
+
+// RedactAction is the action to the value that matches the redaction rule.
+type RedactAction int
+
+const (
+	// RedactMask replaces the value with the mask, "[REDACTED]" by default.
+	RedactMask RedactAction = iota
+	// RedactDrop removes the field from the message.
+	RedactDrop
+	// RedactHash replaces the value with the salted hash, e.g. "sha256:5e88...".
+	// This keeps the value correlatable without revealing that.
+	RedactHash
+)
+
+// Redactor removes the sensitive values from the message before encoding.
+//
+// There are two kinds of rules;
+//   - Key rules match the field names. The pattern is an exact name or a glob (e.g. "*_token"),
+//     and a pattern that contains dots matches the nested path from the root (e.g. "user.password", "*.secret").
+//     A pattern without dots matches the field name at any depth. Key patterns are case-insensitive.
+//   - Value rules match the string values by the regular expression (e.g. emails, credit card numbers).
+//     The masking and hashing replace only the matched part; the dropping removes the whole field.
+//
+// Redactor traverses nested Message, map[string]interface{}, map[string]string, []interface{} and []string.
+// The other values (e.g. structs) are not traversed; please convert them to maps if they contain sensitive values.
+//
+// Please use this through SetRedactor() of the logger.
+type Redactor struct {
+	keyRules   []keyRedactionRule
+	valueRules []valueRedactionRule
+	mask       string
+	salt       []byte
+}
+
+type keyRedactionRule struct {
+	match  func(path []string) bool
+	action RedactAction
+}
+
+type valueRedactionRule struct {
+	pattern  *regexp.Regexp
+	validate func(matched string) bool
+	action   RedactAction
+}
+
+// NewRedactor creates an instance of Redactor that doesn't have any rule.
+func NewRedactor() *Redactor {
+	return &Redactor{
+		mask: defaultRedactionMask,
+	}
+}
+
+var (
+	jwtPattern        = regexp.MustCompile(`\beyJ[A-Za-z0-9_-]+\.[A-Za-z0-9_-]+\.[A-Za-z0-9_-]*`)
+	bearerPattern     = regexp.MustCompile(`(?i)\bbearer\s+[A-Za-z0-9\-._~+/]+=*`)
+	emailPattern      = regexp.MustCompile(`[A-Za-z0-9._%+\-]+@[A-Za-z0-9.\-]+\.[A-Za-z]{2,}`)
+	creditCardPattern = regexp.MustCompile(`\b\d(?:[ \-]?\d){12,18}\b`)
+)
+
+// NewDefaultRedactor creates an instance of Redactor that has the default rules;
+//   - masks the fields that are named like credentials, e.g. "password", "*token*", "authorization", "cookie", "api_key"
+//   - masks JWTs, bearer tokens, emails and credit card numbers (validated by Luhn algorithm) in string values
+func NewDefaultRedactor() *Redactor {
+	r := NewRedactor()
+
+	for _, pattern := range []string{
+		"*password*", "*passwd*", "*secret*", "*token*",
+		"authorization", "proxy-authorization", "cookie", "set-cookie",
+		"api_key", "apikey", "api-key", "private_key", "credit_card", "card_number", "cvv", "ssn",
+	} {
+		r.RedactKey(pattern, RedactMask)
+	}
+
+	r.RedactValue(jwtPattern, RedactMask)
+	r.RedactValue(bearerPattern, RedactMask)
+	r.RedactValue(emailPattern, RedactMask)
+	r.valueRules = append(r.valueRules, valueRedactionRule{
+		pattern:  creditCardPattern,
+		validate: isLuhnValid,
+		action:   RedactMask,
+	})
+
+	return r
+}
+
+// SetMask changes the mask of RedactMask. The default is "[REDACTED]".
+func (r *Redactor) SetMask(mask string) {
+	r.mask = mask
+}
+
+// SetSalt sets the salt of RedactHash. Please set the secret salt, or the hashed values can be guessed by brute force.
+func (r *Redactor) SetSalt(salt []byte) {
+	r.salt = salt
+}
+
+// RedactKey adds the rule that matches the field name by the exact name or the glob pattern.
+// This returns an error if the pattern is malformed.
+func (r *Redactor) RedactKey(pattern string, action RedactAction) error {
+	segments := strings.Split(strings.ToLower(pattern), ".")
+	for _, segment := range segments {
+		if _, err := path.Match(segment, ""); err != nil {
+			return fmt.Errorf("malformed key pattern [given: %s]: %s", pattern, err)
+		}
+	}
+
+	r.keyRules = append(r.keyRules, keyRedactionRule{
+		match: func(keyPath []string) bool {
+			if len(segments) == 1 {
+				return matchKeySegment(segments[0], keyPath[len(keyPath)-1])
+			}
+
+			if len(segments) != len(keyPath) {
+				return false
+			}
+			for i, segment := range segments {
+				if !matchKeySegment(segment, keyPath[i]) {
+					return false
+				}
+			}
+			return true
+		},
+		action: action,
+	})
+	return nil
+}
+
+// RedactKeyRegexp adds the rule that matches the dot-joined path of the field, e.g. "user.password", by the regular expression.
+func (r *Redactor) RedactKeyRegexp(pattern *regexp.Regexp, action RedactAction) {
+	r.keyRules = append(r.keyRules, keyRedactionRule{
+		match: func(keyPath []string) bool {
+			return pattern.MatchString(strings.Join(keyPath, "."))
+		},
+		action: action,
+	})
+}
+
+// RedactValue adds the rule that matches the string value by the regular expression.
+func (r *Redactor) RedactValue(pattern *regexp.Regexp, action RedactAction) {
+	r.valueRules = append(r.valueRules, valueRedactionRule{
+		pattern: pattern,
+		action:  action,
+	})
+}
+
+// Redact returns the redacted copy of the message. The given message is not modified.
+func (r *Redactor) Redact(message Message) Message {
+	return Message(r.redactMap(map[string]interface{}(message), nil))
+}
+
+func (r *Redactor) redactMap(m map[string]interface{}, parent []string) map[string]interface{} {
+	redacted := make(map[string]interface{}, len(m))
+	for k, v := range m {
+		keyPath := append(parent[:len(parent):len(parent)], k)
+		if v, ok := r.redactEntry(keyPath, v); ok {
+			redacted[k] = v
+		}
+	}
+	return redacted
+}
+
+// redactEntry returns the redacted value; the second value is false if the field should be dropped.
+func (r *Redactor) redactEntry(keyPath []string, v interface{}) (interface{}, bool) {
+	for _, rule := range r.keyRules {
+		if rule.match(keyPath) {
+			return r.apply(rule.action, v)
+		}
+	}
+	return r.redactValue(keyPath, v)
+}
+
+func (r *Redactor) redactValue(keyPath []string, v interface{}) (interface{}, bool) {
+	switch value := v.(type) {
+	case string:
+		return r.redactString(value)
+	case Message:
+		return Message(r.redactMap(map[string]interface{}(value), keyPath)), true
+	case map[string]interface{}:
+		return r.redactMap(value, keyPath), true
+	case map[string]string:
+		m := make(map[string]interface{}, len(value))
+		for k, s := range value {
+			m[k] = s
+		}
+		return r.redactMap(m, keyPath), true
+	case []interface{}:
+		redacted := make([]interface{}, 0, len(value))
+		for _, elem := range value {
+			if elem, ok := r.redactValue(keyPath, elem); ok {
+				redacted = append(redacted, elem)
+			}
+		}
+		return redacted, true
+	case []string:
+		redacted := make([]interface{}, 0, len(value))
+		for _, elem := range value {
+			if elem, ok := r.redactString(elem); ok {
+				redacted = append(redacted, elem)
+			}
+		}
+		return redacted, true
+	}
+	return v, true
+}
+
+func (r *Redactor) redactString(s string) (interface{}, bool) {
+	for _, rule := range r.valueRules {
+		matched := false
+		s = rule.pattern.ReplaceAllStringFunc(s, func(m string) string {
+			if rule.validate != nil && !rule.validate(m) {
+				return m
+			}
+			matched = true
+			if rule.action == RedactHash {
+				return r.hash(m)
+			}
+			return r.mask
+		})
+		if matched && rule.action == RedactDrop {
+			return nil, false
+		}
+	}
+	return s, true
+}
+
+func (r *Redactor) apply(action RedactAction, v interface{}) (interface{}, bool) {
+	switch action {
+	case RedactDrop:
+		return nil, false
+	case RedactHash:
+		if s, ok := v.(string); ok {
+			return r.hash(s), true
+		}
+		return r.hash(fmt.Sprint(v)), true
+	}
+	return r.mask, true
+}
+
+func (r *Redactor) hash(s string) string {
+	mac := hmac.New(sha256.New, r.salt)
+	mac.Write([]byte(s))
+	return "sha256:" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func matchKeySegment(pattern string, key string) bool {
+	matched, _ := path.Match(pattern, strings.ToLower(key))
+	return matched
+}
+
+// isLuhnValid checks the number that may contain spaces and hyphens by Luhn algorithm.
+func isLuhnValid(number string) bool {
+	sum := 0
+	double := false
+	digits := 0
+	for i := len(number) - 1; i >= 0; i-- {
+		c := number[i]
+		if c == ' ' || c == '-' {
+			continue
+		}
+		d := int(c - '0')
+		if double {
+			d *= 2
+			if d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+		double = !double
+		digits++
+	}
+	return digits >= 13 && sum%10 == 0
+}
@@ -0,0 +1,142 @@
+package logger
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/moznion/logglily/internal/api"
+)
+
+func TestDefaultRedactorShouldMaskCredentialKeys(t *testing.T) {
+	r := NewDefaultRedactor()
+
+	given := Message{
+		"message":       "login",
+		"Password":      "hunter2",
+		"access_token":  "abc",
+		"Authorization": "Basic dXNlcjpwYXNz",
+		"user": map[string]interface{}{
+			"name":    "john",
+			"api_key": 12345,
+		},
+		"headers": map[string]string{
+			"Cookie": "session=abc",
+			"Accept": "*/*",
+		},
+	}
+	got := r.Redact(given)
+
+	for _, key := range []string{"Password", "access_token", "Authorization"} {
+		if got[key] != "[REDACTED]" {
+			t.Errorf("got[%s] == %v but wants masked", key, got[key])
+		}
+	}
+	user := got["user"].(map[string]interface{})
+	if user["api_key"] != "[REDACTED]" || user["name"] != "john" {
+		t.Errorf("user == %v", user)
+	}
+	headers := got["headers"].(map[string]interface{})
+	if headers["Cookie"] != "[REDACTED]" || headers["Accept"] != "*/*" {
+		t.Errorf("headers == %v", headers)
+	}
+
+	if given["Password"] != "hunter2" {
+		t.Error("given message should not be modified")
+	}
+}
+
+func TestDefaultRedactorShouldMaskSensitiveValues(t *testing.T) {
+	r := NewDefaultRedactor()
+
+	cases := map[string]string{
+		"contact john.doe@example.com please":               "contact [REDACTED] please",
+		"Authorization: Bearer abc.def-ghi":                 "Authorization: [REDACTED]",
+		"token eyJhbGciOiJIUzI1NiJ9.eyJzdWIiOiIxIn0.sig_ok": "token [REDACTED]",
+		"card 4111 1111 1111 1111 charged":                  "card [REDACTED] charged",
+		"card 4111-1111-1111-1111":                          "card [REDACTED]",
+		"order 1234567890123 shipped":                       "order 1234567890123 shipped", // not Luhn valid
+	}
+	for given, expected := range cases {
+		got := r.Redact(Message{"message": given})
+		if got["message"] != expected {
+			t.Errorf("got == `%v` but wants `%v`", got["message"], expected)
+		}
+	}
+
+	got := r.Redact(Message{"emails": []interface{}{"a@example.com", map[string]interface{}{"to": "b@example.com"}}})
+	emails := got["emails"].([]interface{})
+	if emails[0] != "[REDACTED]" || emails[1].(map[string]interface{})["to"] != "[REDACTED]" {
+		t.Errorf("emails == %v", emails)
+	}
+}
+
+func TestRedactorKeyRules(t *testing.T) {
+	r := NewRedactor()
+	r.RedactKey("user.password", RedactDrop)
+	r.RedactKey("*.ip", RedactHash)
+	r.RedactKeyRegexp(regexp.MustCompile(`^session\.(id|key)$`), RedactMask)
+	r.SetSalt([]byte("salt"))
+	r.SetMask("***")
+
+	if err := r.RedactKey("[", RedactMask); err == nil {
+		t.Error("err should not be nil, but got nil")
+	}
+
+	got := r.Redact(Message{
+		"password": "top-level is not matched",
+		"user":     Message{"password": "secret", "name": "john"},
+		"client":   map[string]interface{}{"ip": "192.0.2.1"},
+		"ip":       "top-level is not matched",
+		"session":  map[string]interface{}{"id": "abc", "ttl": 60},
+	})
+
+	if got["password"] != "top-level is not matched" || got["ip"] != "top-level is not matched" {
+		t.Errorf("got == %v", got)
+	}
+	user := got["user"].(Message)
+	if _, ok := user["password"]; ok {
+		t.Errorf("user.password should be dropped: %v", user)
+	}
+	ip := got["client"].(map[string]interface{})["ip"].(string)
+	if !strings.HasPrefix(ip, "sha256:") || ip != r.hash("192.0.2.1") {
+		t.Errorf("client.ip == %v", ip)
+	}
+	if another := NewRedactor(); another.hash("192.0.2.1") == ip {
+		t.Error("hash should depend on the salt")
+	}
+	session := got["session"].(map[string]interface{})
+	if session["id"] != "***" || session["ttl"] != 60 {
+		t.Errorf("session == %v", session)
+	}
+}
+
+func TestRedactorValueRuleDrop(t *testing.T) {
+	r := NewRedactor()
+	r.RedactValue(regexp.MustCompile(`secret`), RedactDrop)
+
+	got := r.Redact(Message{"a": "this is secret", "b": "public"})
+	if _, ok := got["a"]; ok {
+		t.Errorf("a should be dropped: %v", got)
+	}
+	if got["b"] != "public" {
+		t.Errorf("b == %v but wants %v", got["b"], "public")
+	}
+}
+
+func TestSyncLoggerShouldRedactBeforeEncoding(t *testing.T) {
+	l := NewSyncLogger([]string{"test-tag"}, "test-token", true)
+	l.APIClient = &api.DummySuccClient{}
+	l.SetRedactor(NewDefaultRedactor())
+
+	stdout, err := captureLogStdoutCapture(func() error {
+		return l.LogFields(String("message", "login"), String("password", "hunter2"))
+	})
+	if err != nil {
+		t.Error("unexpected err", err)
+	}
+	expected := `{"message":"login","password":"[REDACTED]"}`
+	if stdout != expected {
+		t.Errorf("stdout == `%v` but wants `%v`", stdout, expected)
+	}
+}
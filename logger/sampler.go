@@ -0,0 +1,318 @@
+package logger
+
+import (
+	"fmt"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+const (
+	maximumSamplingKeys = 10000
+	maximumSummaryKeys  = 100
+	summaryOtherKey     = "(other)"
+
+	// defaultSamplingTick is the tick of PerKeyPolicy that is used instead of the invalid one.
+	defaultSamplingTick = time.Second
+)
+
+// SamplingPolicy decides whether the message is kept or sampled out.
+type SamplingPolicy interface {
+	// Sample returns true if the message should be kept.
+	Sample(message Message) bool
+}
+
+// SamplingPolicyFunc is an adapter to allow the use of ordinary functions as SamplingPolicy.
+type SamplingPolicyFunc func(message Message) bool
+
+// Sample calls f(message).
+func (f SamplingPolicyFunc) Sample(message Message) bool {
+	return f(message)
+}
+
+// MessageKey returns a function that extracts the value of the field as a string.
+// This is useful as a key function of PerKeyPolicy, e.g. MessageKey("message") groups the messages by the message template.
+func MessageKey(key string) func(message Message) string {
+	return func(message Message) string {
+		v, ok := message[key]
+		if !ok {
+			return ""
+		}
+		if s, ok := v.(string); ok {
+			return s
+		}
+		return fmt.Sprint(v)
+	}
+}
+
+// FixedRatePolicy keeps the messages randomly at the fixed rate.
+type FixedRatePolicy struct {
+	rate   float64
+	random func() float64
+}
+
+// NewFixedRatePolicy creates an instance of FixedRatePolicy.
+// `rate` is the ratio of the messages to keep; 0.0 drops all and 1.0 keeps all.
+func NewFixedRatePolicy(rate float64) *FixedRatePolicy {
+	return &FixedRatePolicy{
+		rate:   rate,
+		random: rand.Float64,
+	}
+}
+
+// Sample keeps the message at the rate.
+func (p *FixedRatePolicy) Sample(message Message) bool {
+	return p.random() < p.rate
+}
+
+// PerKeyPolicy keeps the first N messages per key for each tick, and then keeps every Mth message in that tick.
+//
+// e.g. NewPerKeyPolicy(MessageKey("message"), time.Second, 100, 10) keeps the first 100 messages
+// per message template per second, and then 1 in 10 messages.
+//
+// The counters are kept for 10000 keys at most. While all of them are active, the new keys share one counter.
+type PerKeyPolicy struct {
+	keyFunc    func(message Message) string
+	tick       time.Duration
+	first      uint64
+	thereafter uint64
+	counters   map[string]*samplingCounter
+	overflow   *samplingCounter
+	mutex      *sync.Mutex
+	clock      func() time.Time
+}
+
+type samplingCounter struct {
+	resetAt time.Time
+	count   uint64
+}
+
+// NewPerKeyPolicy creates an instance of PerKeyPolicy.
+//
+// `keyFunc` extracts the key from the message. `tick` is the period to reset the counters; if this is less or equal to 0, 1 second is used.
+// `first` is the number of the messages to keep for each tick.
+// `thereafter` is the interval of the messages to keep after that; if this is less or equal to 0, all of them are sampled out.
+func NewPerKeyPolicy(keyFunc func(message Message) string, tick time.Duration, first int, thereafter int) *PerKeyPolicy {
+	if tick <= 0 {
+		tick = defaultSamplingTick
+	}
+	if thereafter < 0 {
+		thereafter = 0
+	}
+	if first < 0 {
+		first = 0
+	}
+
+	return &PerKeyPolicy{
+		keyFunc:    keyFunc,
+		tick:       tick,
+		first:      uint64(first),
+		thereafter: uint64(thereafter),
+		counters:   make(map[string]*samplingCounter),
+		mutex:      &sync.Mutex{},
+		clock:      time.Now,
+	}
+}
+
+// Sample keeps the message according to the counter of the key.
+func (p *PerKeyPolicy) Sample(message Message) bool {
+	key := p.keyFunc(message)
+	now := p.clock()
+
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	counter, ok := p.counters[key]
+	if !ok && len(p.counters) >= maximumSamplingKeys {
+		// the sweep runs at most once per tick while the counters are full, because it scans all of them
+		if p.overflow == nil || !now.Before(p.overflow.resetAt) {
+			p.sweepExpiredCounters(now)
+			p.overflow = nil
+		}
+		if len(p.counters) >= maximumSamplingKeys {
+			if p.overflow == nil {
+				p.overflow = &samplingCounter{resetAt: now.Add(p.tick)}
+			}
+			return p.sampleBy(p.overflow)
+		}
+	}
+	if !ok || !now.Before(counter.resetAt) {
+		counter = &samplingCounter{resetAt: now.Add(p.tick)}
+		p.counters[key] = counter
+	}
+	return p.sampleBy(counter)
+}
+
+func (p *PerKeyPolicy) sampleBy(counter *samplingCounter) bool {
+	counter.count++
+	if counter.count <= p.first {
+		return true
+	}
+	if p.thereafter <= 0 {
+		return false
+	}
+	return (counter.count-p.first)%p.thereafter == 0
+}
+
+func (p *PerKeyPolicy) sweepExpiredCounters(now time.Time) {
+	for key, counter := range p.counters {
+		if !now.Before(counter.resetAt) {
+			delete(p.counters, key)
+		}
+	}
+}
+
+// Sampler is a wrapper of any logger that samples the messages to control the volume.
+//
+// Sampler asks SamplingPolicy whether the message is kept or sampled out,
+// but the messages that match the always-keep rules are kept regardless of that (e.g. errors).
+//
+// And Sampler counts the sampled-out messages. StartSummary() emits those counters periodically as a summary message.
+type Sampler struct {
+	sink       Sink
+	policy     SamplingPolicy
+	alwaysKeep []func(message Message) bool
+	summaryKey func(message Message) string
+	sampledOut map[string]uint64
+	total      uint64
+	mutex      *sync.Mutex
+	reporter   *periodicReporter
+}
+
+// NewSampler creates an instance of Sampler that sends the kept messages through the sink.
+func NewSampler(sink Sink, policy SamplingPolicy) *Sampler {
+	return &Sampler{
+		sink:       sink,
+		policy:     policy,
+		summaryKey: MessageKey("message"),
+		sampledOut: make(map[string]uint64),
+		mutex:      &sync.Mutex{},
+	}
+}
+
+// AlwaysKeep adds the rule; the message that matches the rule is never sampled out.
+//
+// NOTE: this method is not goroutine-safe. Please configure the sampler before logging.
+func (s *Sampler) AlwaysKeep(rule func(message Message) bool) {
+	s.alwaysKeep = append(s.alwaysKeep, rule)
+}
+
+// SetSummaryKey changes the function that groups the counters of the summary.
+// The default is MessageKey("message"). If nil is given, the summary has only the total count.
+//
+// NOTE: this method is not goroutine-safe. Please configure the sampler before logging.
+func (s *Sampler) SetSummaryKey(keyFunc func(message Message) string) {
+	s.summaryKey = keyFunc
+}
+
+// KeepLevels returns the rule that matches the message of the levels.
+// `levelKey` is the name of the level field; please see also LeveledLogger.
+//
+// e.g. sampler.AlwaysKeep(KeepLevels("level", ErrorLevel, FatalLevel))
+func KeepLevels(levelKey string, levels ...Level) func(message Message) bool {
+	names := make(map[string]bool, len(levels))
+	for _, level := range levels {
+		names[level.String()] = true
+	}
+
+	return func(message Message) bool {
+		name, ok := message[levelKey].(string)
+		return ok && names[name]
+	}
+}
+
+// Log sends the message through the underlying logger if the message is kept.
+// If the message is sampled out, this method returns nil.
+func (s *Sampler) Log(message Message) error {
+	if !s.keep(message) {
+		s.countSampledOut(message)
+		return nil
+	}
+	return s.sink.Send(message)
+}
+
+// Send is the same as Log(). This makes Sampler satisfy Sink.
+func (s *Sampler) Send(message Message) error {
+	return s.Log(message)
+}
+
+// SampledOut returns the number of the sampled-out messages that are not reported yet.
+func (s *Sampler) SampledOut() uint64 {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.total
+}
+
+// StartSummary starts emitting the summary message periodically, e.g.
+//
+//	{"message":"sampled out 120 events","sampled_out":120,"sampled_out_by_key":{"request finished":120}}
+//
+// The summary is emitted only when something is sampled out, and the counters are reset after that.
+// If the summary has been started already, this method does nothing.
+func (s *Sampler) StartSummary(interval time.Duration) {
+	if s.reporter != nil {
+		return
+	}
+	s.reporter = startPeriodicReporter(interval, func() {
+		s.emitSummary()
+	})
+}
+
+// StopSummary stops emitting the summary. The remained counters are emitted before stopping.
+func (s *Sampler) StopSummary() {
+	if s.reporter == nil {
+		return
+	}
+	s.reporter.stop()
+	s.reporter = nil
+}
+
+func (s *Sampler) keep(message Message) bool {
+	for _, rule := range s.alwaysKeep {
+		if rule(message) {
+			return true
+		}
+	}
+	return s.policy.Sample(message)
+}
+
+func (s *Sampler) countSampledOut(message Message) {
+	key := ""
+	if s.summaryKey != nil {
+		key = s.summaryKey(message)
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.total++
+	if s.summaryKey == nil {
+		return
+	}
+	if _, ok := s.sampledOut[key]; !ok && len(s.sampledOut) >= maximumSummaryKeys {
+		key = summaryOtherKey
+	}
+	s.sampledOut[key]++
+}
+
+func (s *Sampler) emitSummary() error {
+	s.mutex.Lock()
+	total := s.total
+	byKey := s.sampledOut
+	s.total = 0
+	s.sampledOut = make(map[string]uint64)
+	s.mutex.Unlock()
+
+	if total <= 0 {
+		return nil
+	}
+
+	summary := Message{
+		"message":     fmt.Sprintf("sampled out %d events", total),
+		"sampled_out": total,
+	}
+	if s.summaryKey != nil {
+		summary["sampled_out_by_key"] = byKey
+	}
+	return s.sink.Send(summary)
+}
@@ -0,0 +1,169 @@
+package logger
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestFixedRatePolicy(t *testing.T) {
+	p := NewFixedRatePolicy(0.25)
+
+	values := []float64{0.1, 0.3, 0.24, 0.25}
+	i := 0
+	p.random = func() float64 {
+		v := values[i]
+		i++
+		return v
+	}
+
+	expected := []bool{true, false, true, false}
+	for _, e := range expected {
+		if got := p.Sample(Message{}); got != e {
+			t.Errorf("got == %v but wants %v", got, e)
+		}
+	}
+}
+
+func TestPerKeyPolicy(t *testing.T) {
+	p := NewPerKeyPolicy(MessageKey("message"), time.Second, 2, 3)
+	now := time.Date(2018, 1, 5, 17, 11, 25, 0, time.UTC)
+	p.clock = func() time.Time {
+		return now
+	}
+
+	var kept []int
+	for i := 1; i <= 8; i++ {
+		if p.Sample(Message{"message": "hello"}) {
+			kept = append(kept, i)
+		}
+	}
+	expected := []int{1, 2, 5, 8}
+	if len(kept) != len(expected) {
+		t.Fatalf("kept == %v but wants %v", kept, expected)
+	}
+	for i := range expected {
+		if kept[i] != expected[i] {
+			t.Errorf("kept == %v but wants %v", kept, expected)
+		}
+	}
+
+	if !p.Sample(Message{"message": "another"}) {
+		t.Error("another key should be kept")
+	}
+
+	now = now.Add(time.Second)
+	if !p.Sample(Message{"message": "hello"}) {
+		t.Error("counter should be reset on the next tick")
+	}
+}
+
+func TestPerKeyPolicyWithoutThereafter(t *testing.T) {
+	p := NewPerKeyPolicy(MessageKey("message"), time.Minute, 1, 0)
+
+	if !p.Sample(Message{"message": "hello"}) {
+		t.Error("first message should be kept")
+	}
+	if p.Sample(Message{"message": "hello"}) {
+		t.Error("second message should be sampled out")
+	}
+}
+
+func TestPerKeyPolicyShouldUseDefaultTickForInvalidTick(t *testing.T) {
+	for _, tick := range []time.Duration{0, -time.Second} {
+		p := NewPerKeyPolicy(MessageKey("message"), tick, 1, 0)
+		if p.tick != defaultSamplingTick {
+			t.Errorf("tick == %v but wants %v", p.tick, defaultSamplingTick)
+		}
+
+		now := time.Date(2018, 1, 5, 17, 11, 25, 0, time.UTC)
+		p.clock = func() time.Time {
+			return now
+		}
+		if !p.Sample(Message{"message": "hello"}) {
+			t.Error("first message should be kept")
+		}
+		if p.Sample(Message{"message": "hello"}) {
+			t.Error("second message in the same tick should be sampled out")
+		}
+	}
+}
+
+func TestPerKeyPolicyShouldShareCounterOfNewKeysWhenFull(t *testing.T) {
+	p := NewPerKeyPolicy(MessageKey("message"), time.Second, 1, 0)
+	now := time.Date(2018, 1, 5, 17, 11, 25, 0, time.UTC)
+	p.clock = func() time.Time {
+		return now
+	}
+
+	for i := 0; i < maximumSamplingKeys; i++ {
+		p.Sample(Message{"message": fmt.Sprintf("key%d", i)})
+	}
+	if !p.Sample(Message{"message": "new1"}) {
+		t.Error("first new key should be kept by the shared counter")
+	}
+	if p.Sample(Message{"message": "new2"}) {
+		t.Error("second new key should share the counter and be sampled out")
+	}
+	if len(p.counters) != maximumSamplingKeys {
+		t.Errorf("len(p.counters) == %d but wants %d", len(p.counters), maximumSamplingKeys)
+	}
+
+	now = now.Add(time.Second)
+	if !p.Sample(Message{"message": "new2"}) {
+		t.Error("new key should be kept after the expired counters are swept")
+	}
+	if len(p.counters) != 1 {
+		t.Errorf("len(p.counters) == %d but wants %d", len(p.counters), 1)
+	}
+}
+
+func TestSamplerShouldKeepErrorsAndSummarize(t *testing.T) {
+	sink := &recordingSink{}
+	s := NewSampler(sink, NewFixedRatePolicy(0))
+	s.AlwaysKeep(KeepLevels("level", ErrorLevel, FatalLevel))
+
+	s.Log(Message{"message": "request finished", "level": "info"})
+	s.Log(Message{"message": "request finished", "level": "info"})
+	s.Log(Message{"message": "cache miss", "level": "debug"})
+	s.Log(Message{"message": "failed", "level": "error"})
+
+	if len(sink.messages) != 1 || sink.messages[0]["message"] != "failed" {
+		t.Fatalf("messages == %v but wants only the error", sink.messages)
+	}
+	if s.SampledOut() != 3 {
+		t.Errorf("SampledOut() == %d but wants %d", s.SampledOut(), 3)
+	}
+
+	s.StartSummary(time.Hour)
+	s.StopSummary()
+
+	if len(sink.messages) != 2 {
+		t.Fatalf("len(messages) == %d but wants %d", len(sink.messages), 2)
+	}
+	summary := sink.messages[1]
+	if summary["sampled_out"] != uint64(3) {
+		t.Errorf("sampled_out == %v but wants %v", summary["sampled_out"], 3)
+	}
+	byKey := summary["sampled_out_by_key"].(map[string]uint64)
+	if byKey["request finished"] != 2 || byKey["cache miss"] != 1 {
+		t.Errorf("sampled_out_by_key == %v", byKey)
+	}
+	if s.SampledOut() != 0 {
+		t.Errorf("SampledOut() == %d but wants %d", s.SampledOut(), 0)
+	}
+}
+
+func TestSamplerShouldNotEmitEmptySummary(t *testing.T) {
+	sink := &recordingSink{}
+	s := NewSampler(sink, NewFixedRatePolicy(1))
+
+	s.Log(Message{"message": "hello"})
+	s.StartSummary(time.Hour)
+	s.StartSummary(time.Hour) // must not start another reporter
+	s.StopSummary()
+
+	if len(sink.messages) != 1 {
+		t.Errorf("len(messages) == %d but wants %d", len(sink.messages), 1)
+	}
+}
@@ -2,8 +2,6 @@ package logger
 
 import (
 	"bytes"
-	"encoding/json"
-
 	"sync"
 
 	"time"
@@ -39,6 +37,8 @@ type SyncBulkLogger struct {
 	active               bool
 	flushTickerStoppedCh chan struct{}
 	stopFlushTickerCh    chan struct{}
+	sendErrorHandler     func(err error, failedMessages [][]byte)
+	encoder
 }
 
 // NewSyncBulkLogger creates an instance of SyncBulkLogger.
@@ -83,7 +83,12 @@ func (l *SyncBulkLogger) Log(message Message) (*SyncBulkResult, error) {
 		}, errors.New("in progress to shutdown. refused the message")
 	}
 
-	body, err := json.Marshal(message)
+	body, err := l.encodeMessage(message)
+	if err == errMessageDropped {
+		return &SyncBulkResult{
+			FailedMessages: nil,
+		}, nil
+	}
 	if err != nil {
 		return &SyncBulkResult{
 			FailedMessages: nil,
@@ -93,9 +98,59 @@ func (l *SyncBulkLogger) Log(message Message) (*SyncBulkResult, error) {
 	return l.post(body)
 }
 
+// Send logs the message into loggly as a bulk synchronously.
+//
+// This method is the same as Log() but it returns only the error;
+// the failed messages are discarded unless the handler is set by SetSendErrorHandler().
+func (l *SyncBulkLogger) Send(message Message) error {
+	result, err := l.Log(message)
+	l.handleSendResult(result, err)
+	return err
+}
+
+// SetSendErrorHandler sets the handler that is called with the error and the failed messages of Send().
+// Please set this before starting logging if the failed messages must be cared.
+func (l *SyncBulkLogger) SetSendErrorHandler(handler func(err error, failedMessages [][]byte)) {
+	l.sendErrorHandler = handler
+}
+
+// With returns a child logger that merges the fields into each message and sends that through this logger.
+func (l *SyncBulkLogger) With(fields Message) *BoundLogger {
+	return NewBoundLogger(l, fields)
+}
+
+// LogFields logs the fields into loggly as a bulk synchronously.
+//
+// This method encodes the fields into the pooled buffer directly, without building an intermediate Message.
+func (l *SyncBulkLogger) LogFields(fields ...Field) (*SyncBulkResult, error) {
+	if !l.active {
+		return &SyncBulkResult{
+			FailedMessages: nil,
+		}, errors.New("in progress to shutdown. refused the message")
+	}
+
+	buf := getBuffer()
+	var err error
+	buf.bs, err = l.appendFields(buf.bs, fields)
+	if err == errMessageDropped {
+		putBuffer(buf)
+		return &SyncBulkResult{
+			FailedMessages: nil,
+		}, nil
+	}
+	if err != nil {
+		putBuffer(buf)
+		return &SyncBulkResult{
+			FailedMessages: nil,
+		}, err
+	}
+
+	return l.post(detachBuffer(buf))
+}
+
 // Flush flushes remained messages that are in the buffer.
 func (l *SyncBulkLogger) Flush() (*SyncBulkResult, error) {
-	return l.flush(l.bufferInitializer)
+	return l.flushBuffer()
 }
 
 // Shutdown attempts to shutting down.
@@ -103,7 +158,22 @@ func (l *SyncBulkLogger) Shutdown() {
 	l.active = false
 	l.stopFlushTickerCh <- notifier
 	<-l.flushTickerStoppedCh
-	l.flush(l.bufferInitializer)
+	l.flushBuffer()
+}
+
+func (l *SyncBulkLogger) sendEncoded(body []byte) error {
+	if !l.active {
+		return errors.New("in progress to shutdown. refused the message")
+	}
+	result, err := l.post(body)
+	l.handleSendResult(result, err)
+	return err
+}
+
+func (l *SyncBulkLogger) handleSendResult(result *SyncBulkResult, err error) {
+	if err != nil && l.sendErrorHandler != nil {
+		l.sendErrorHandler(err, result.FailedMessages)
+	}
 }
 
 func (l *SyncBulkLogger) post(body []byte) (*SyncBulkResult, error) {
@@ -128,6 +198,13 @@ func (l *SyncBulkLogger) post(body []byte) (*SyncBulkResult, error) {
 	})
 }
 
+// flushBuffer flushes the buffer with holding the mutex that post() holds, so that it doesn't race with Log().
+func (l *SyncBulkLogger) flushBuffer() (*SyncBulkResult, error) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	return l.flush(l.bufferInitializer)
+}
+
 func (l *SyncBulkLogger) flush(bufferSweeper func()) (*SyncBulkResult, error) {
 	l.flushMutex.Lock()
 	defer l.flushMutex.Unlock()
@@ -144,14 +221,14 @@ func (l *SyncBulkLogger) flush(bufferSweeper func()) (*SyncBulkResult, error) {
 	resp, err := l.APIClient.LogAsBulk(payload)
 	if err != nil {
 		return &SyncBulkResult{
-			FailedMessages: l.logs,
+			FailedMessages: l.detachLogs(),
 		}, err
 	}
 	defer resp.Body.Close()
 
 	if err := checkHTTPResponse(resp); err != nil {
 		return &SyncBulkResult{
-			FailedMessages: l.logs,
+			FailedMessages: l.detachLogs(),
 		}, err
 	}
 
@@ -160,6 +237,14 @@ func (l *SyncBulkLogger) flush(bufferSweeper func()) (*SyncBulkResult, error) {
 	}, nil
 }
 
+// detachLogs hands the buffered messages over to the caller.
+// The buffer must not share its backing array with the returned one, or following Log() overwrites that.
+func (l *SyncBulkLogger) detachLogs() [][]byte {
+	logs := l.logs
+	l.logs = nil
+	return logs
+}
+
 func (l *SyncBulkLogger) bufferInitializer() {
 	l.logs = l.logs[:0]
 	l.currentPayloadSize = 0
@@ -179,7 +264,7 @@ func (l *SyncBulkLogger) startPeriodicallyFlushing(flushIntervalMillis int) {
 		for {
 			select {
 			case <-ticker.C:
-				l.flush(l.bufferInitializer)
+				l.flushBuffer()
 			case <-l.stopFlushTickerCh:
 				break loop
 			}
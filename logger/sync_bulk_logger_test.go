@@ -298,3 +298,140 @@ func TestSyncBulkLogger_DisabledPeriodicallyFlush(t *testing.T) {
 		t.Error("l.currentPayloadSize should not be 0 but come 0")
 	}
 }
+
+func TestSyncBulkLoggerLogFieldsShouldBeSuccessfully(t *testing.T) {
+	l, err := NewSyncBulkLogger([]string{"test-tag"}, "test-token", true, 215, 0)
+	if err != nil {
+		t.Error("unexpected err", err)
+	}
+
+	l.APIClient = &api.DummySuccClient{}
+
+	l.LogFields(String("From", "john"), String("Message", "msg1"), String("timestamp", "2018-01-05T17:11:25.494Z"))
+	l.Log(Message{"Message": "msg2", "From": "john", "timestamp": "2018-01-05T17:11:25.495Z"})
+
+	// the buffered message doesn't keep the capacity of the pooled buffer
+	if len(l.logs) != 2 || cap(l.logs[0]) != len(l.logs[0]) {
+		t.Errorf("len(l.logs) == %d, cap(l.logs[0]) == %d but wants %d, %d", len(l.logs), cap(l.logs[0]), 2, len(l.logs[0]))
+	}
+
+	stdout, _, err := captureLogStdoutCaptureWithFailedMessagesList(func() (*SyncBulkResult, error) {
+		return l.LogFields(String("From", "john"), String("Message", "msg3"), String("timestamp", "2018-01-05T17:11:25.496Z"))
+	})
+	if err != nil {
+		t.Error("unexpected err", err)
+	}
+	expected := `{"From":"john","Message":"msg1","timestamp":"2018-01-05T17:11:25.494Z"}
+{"From":"john","Message":"msg2","timestamp":"2018-01-05T17:11:25.495Z"}`
+	if stdout != expected {
+		t.Errorf("stdout == `%v` but wants `%v`", stdout, expected)
+	}
+
+	if len(l.logs) != 1 {
+		t.Errorf("len(l.logs) == %d but wants %d", len(l.logs), 1)
+	}
+
+	expected = `{"From":"john","Message":"msg3","timestamp":"2018-01-05T17:11:25.496Z"}`
+	if string(l.logs[0]) != expected {
+		t.Errorf("log == %v but wants %v", string(l.logs[0]), expected)
+	}
+}
+
+func TestSyncBulkLoggerLogFieldsShouldKeepFailedMessages(t *testing.T) {
+	l, _ := NewSyncBulkLogger([]string{"test-tag"}, "test-token", true, 215, 0)
+	l.APIClient = &api.DummyErrClient{}
+
+	l.LogFields(String("Message", "msg1"))
+	result, err := l.Flush()
+	if err == nil {
+		t.Error("err should not be nil, but got nil")
+	}
+
+	// The failed messages must not share the pooled buffers that are recycled.
+	l.LogFields(String("Message", "msg2"))
+	if string(result.FailedMessages[0]) != `{"Message":"msg1"}` {
+		t.Errorf("failedMessages[0] == %s but wants %s", result.FailedMessages[0], `{"Message":"msg1"}`)
+	}
+}
+
+func BenchmarkSyncBulkLogger_Log(b *testing.B) {
+	l, _ := NewSyncBulkLogger([]string{"test-tag"}, "test-token", true, 1024*1024, 0)
+	l.APIClient = &api.DummyNopClient{}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		l.Log(Message{
+			"message":  "request finished",
+			"method":   "GET",
+			"status":   200,
+			"duration": 1500 * time.Microsecond,
+			"ok":       true,
+		})
+	}
+}
+
+func BenchmarkSyncBulkLogger_LogFields(b *testing.B) {
+	l, _ := NewSyncBulkLogger([]string{"test-tag"}, "test-token", true, 1024*1024, 0)
+	l.APIClient = &api.DummyNopClient{}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		l.LogFields(
+			String("message", "request finished"),
+			String("method", "GET"),
+			Int("status", 200),
+			Duration("duration", 1500*time.Microsecond),
+			Bool("ok", true),
+		)
+	}
+}
+
+func TestSyncBulkLoggerShouldStampOnLog(t *testing.T) {
+	l, _ := NewSyncBulkLogger([]string{"test-tag"}, "test-token", true, 1024, 0)
+	l.APIClient = &api.DummyNopClient{}
+
+	now := time.Date(2018, 1, 5, 17, 11, 25, 494000000, time.UTC)
+	timestamper := NewTimestamper()
+	timestamper.Clock = func() time.Time {
+		now = now.Add(time.Second)
+		return now
+	}
+	l.SetTimestamper(timestamper)
+
+	l.Log(Message{"Message": "msg1"})
+	l.LogFields(String("Message", "msg2"))
+
+	expected := []string{
+		`{"Message":"msg1","timestamp":"2018-01-05T17:11:26.494Z"}`,
+		`{"timestamp":"2018-01-05T17:11:27.494Z","Message":"msg2"}`,
+	}
+	for i, e := range expected {
+		if string(l.logs[i]) != e {
+			t.Errorf("l.logs[%d] == `%s` but wants `%s`", i, l.logs[i], e)
+		}
+	}
+}
+
+func TestSyncBulkLoggerSendShouldNotifyFailedMessagesToHandler(t *testing.T) {
+	l, _ := NewSyncBulkLogger([]string{"test-tag"}, "test-token", true, 215, 10000000)
+	l.APIClient = &api.DummyErrClient{}
+
+	var handledErr error
+	var handledMessages [][]byte
+	l.SetSendErrorHandler(func(err error, failedMessages [][]byte) {
+		handledErr = err
+		handledMessages = failedMessages
+	})
+
+	for _, msg := range []string{"msg1", "msg2", "msg3"} {
+		l.Send(Message{"Message": msg, "From": "john", "timestamp": "2018-01-05T17:11:25.494Z"})
+	}
+	if handledErr == nil {
+		t.Error("handler should be called with the error")
+	}
+	if len(handledMessages) != 2 {
+		t.Errorf("len(handledMessages) == %d but wants %d", len(handledMessages), 2)
+	}
+}
@@ -1,8 +1,6 @@
 package logger
 
 import (
-	"encoding/json"
-
 	"github.com/moznion/logglily/api"
 	internalAPI "github.com/moznion/logglily/internal/api"
 )
@@ -15,6 +13,7 @@ import (
 // If performance is required, please consider using asynchronous logger.
 type SyncLogger struct {
 	APIClient api.Client
+	encoder
 }
 
 // NewSyncLogger creates an instance of SyncLogger.
@@ -26,11 +25,47 @@ func NewSyncLogger(tags []string, token string, isHTTPS bool) *SyncLogger {
 
 // Log logs message into loggly through event API synchronously.
 func (l *SyncLogger) Log(message Message) error {
-	body, err := json.Marshal(message)
+	body, err := l.encodeMessage(message)
+	if err == errMessageDropped {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	return l.log(body)
+}
+
+// Send logs message into loggly through event API synchronously. This is the same as Log().
+func (l *SyncLogger) Send(message Message) error {
+	return l.Log(message)
+}
+
+// With returns a child logger that merges the fields into each message and sends that through this logger.
+func (l *SyncLogger) With(fields Message) *BoundLogger {
+	return NewBoundLogger(l, fields)
+}
+
+// LogFields logs the fields into loggly through event API synchronously.
+//
+// This method encodes the fields without building an intermediate Message.
+func (l *SyncLogger) LogFields(fields ...Field) error {
+	body, err := l.encodeFields(fields)
+	if err == errMessageDropped {
+		return nil
+	}
 	if err != nil {
 		return err
 	}
 
+	return l.log(body)
+}
+
+func (l *SyncLogger) sendEncoded(body []byte) error {
+	return l.log(body)
+}
+
+func (l *SyncLogger) log(body []byte) error {
 	res, err := l.APIClient.Log(body)
 	if err != nil {
 		return err
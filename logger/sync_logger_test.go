@@ -63,3 +63,20 @@ func TestSyncLogShouldBeFailWithHTTPFail(t *testing.T) {
 		t.Error("err should not be nil, but got nil")
 	}
 }
+
+func TestSyncLoggerLogFieldsShouldBeSuccessfully(t *testing.T) {
+	l := NewSyncLogger([]string{"test-tag"}, "test-token", true)
+	l.APIClient = &api.DummySuccClient{}
+
+	stdout, err := captureLogStdoutCapture(func() error {
+		return l.LogFields(String("Message", "test-msg"), String("From", "john doe"))
+	})
+	if err != nil {
+		t.Error("unexpected err", err)
+	}
+
+	expected := `{"Message":"test-msg","From":"john doe"}`
+	if stdout != expected {
+		t.Errorf("stdout == `%v` but wants `%v`", stdout, expected)
+	}
+}
@@ -0,0 +1,105 @@
+package logger
+
+import "time"
+
+const defaultTimestampKey = "timestamp"
+
+// Timestamper injects the timestamp into the message at the time Log() is called.
+//
+// Bulk loggers postpone sending the message by up to `flushIntervalMillis`,
+// so the time that loggly receives the message is not the time that the event happened.
+// Timestamper stamps the message on enqueueing; that is consistent even if the message is resent.
+//
+// The timestamp is ISO-8601 format that loggly can recognize, e.g. "2018-01-05T17:11:25.494Z".
+// If the message already has the field of the key, Timestamper never overwrites that.
+//
+// Please use this through SetTimestamper() of the logger.
+type Timestamper struct {
+	// Key is the name of the timestamp field. If this is empty, "timestamp" is used.
+	Key string
+
+	// Precision is the precision of the fractional seconds;
+	// time.Second, time.Millisecond, time.Microsecond or time.Nanosecond.
+	// If this is zero, time.Millisecond is used.
+	Precision time.Duration
+
+	// UTC normalizes the timestamp into UTC. If this is false, the timestamp has the offset of the clock's location.
+	UTC bool
+
+	// Clock returns the current time. If this is nil, time.Now is used.
+	Clock func() time.Time
+}
+
+// NewTimestamper creates an instance of Timestamper with the default settings;
+// "timestamp" key, millisecond precision and UTC normalization.
+func NewTimestamper() *Timestamper {
+	return &Timestamper{
+		Key:       defaultTimestampKey,
+		Precision: time.Millisecond,
+		UTC:       true,
+		Clock:     time.Now,
+	}
+}
+
+// Format formats the time according to the settings.
+func (t *Timestamper) Format(tm time.Time) string {
+	return string(t.appendTime(nil, tm))
+}
+
+func (t *Timestamper) key() string {
+	if t.Key == "" {
+		return defaultTimestampKey
+	}
+	return t.Key
+}
+
+func (t *Timestamper) now() time.Time {
+	if t.Clock == nil {
+		return time.Now()
+	}
+	return t.Clock()
+}
+
+func (t *Timestamper) layout() string {
+	switch precision := t.Precision; {
+	case precision >= time.Second:
+		return "2006-01-02T15:04:05Z07:00"
+	case precision >= time.Millisecond, precision <= 0:
+		return "2006-01-02T15:04:05.000Z07:00"
+	case precision >= time.Microsecond:
+		return "2006-01-02T15:04:05.000000Z07:00"
+	default:
+		return "2006-01-02T15:04:05.000000000Z07:00"
+	}
+}
+
+func (t *Timestamper) appendTime(dst []byte, tm time.Time) []byte {
+	if t.UTC {
+		tm = tm.UTC()
+	}
+	return tm.AppendFormat(dst, t.layout())
+}
+
+// appendEntry appends the timestamp as the key/value pair of JSON object.
+func (t *Timestamper) appendEntry(dst []byte) []byte {
+	dst = appendJSONString(dst, t.key())
+	dst = append(dst, ':', '"')
+	dst = t.appendTime(dst, t.now())
+	return append(dst, '"')
+}
+
+// stamp returns the message that has the timestamp.
+// The given message is not modified; this returns a shallow copy if it is necessary to add the timestamp.
+func (t *Timestamper) stamp(message Message) Message {
+	key := t.key()
+	if _, ok := message[key]; ok {
+		return message
+	}
+
+	stamped := make(Message, len(message)+1)
+	for k, v := range message {
+		stamped[k] = v
+	}
+	stamped[key] = t.Format(t.now())
+	return stamped
+}
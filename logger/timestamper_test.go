@@ -0,0 +1,88 @@
+package logger
+
+import (
+	"testing"
+	"time"
+)
+
+func fixedClock() time.Time {
+	return time.Date(2018, 1, 6, 15, 57, 48, 165123456, time.FixedZone("JST", 9*60*60))
+}
+
+func TestTimestamperFormat(t *testing.T) {
+	ts := NewTimestamper()
+	ts.Clock = fixedClock
+
+	cases := []struct {
+		precision time.Duration
+		utc       bool
+		expected  string
+	}{
+		{time.Second, true, "2018-01-06T06:57:48Z"},
+		{time.Millisecond, true, "2018-01-06T06:57:48.165Z"},
+		{0, true, "2018-01-06T06:57:48.165Z"},
+		{time.Microsecond, true, "2018-01-06T06:57:48.165123Z"},
+		{time.Nanosecond, true, "2018-01-06T06:57:48.165123456Z"},
+		{time.Millisecond, false, "2018-01-06T15:57:48.165+09:00"},
+	}
+
+	for _, c := range cases {
+		ts.Precision = c.precision
+		ts.UTC = c.utc
+		if got := ts.Format(fixedClock()); got != c.expected {
+			t.Errorf("got == `%v` but wants `%v`", got, c.expected)
+		}
+	}
+}
+
+func TestTimestamperStampShouldNotOverwrite(t *testing.T) {
+	ts := NewTimestamper()
+	ts.Clock = fixedClock
+
+	given := Message{"message": "hello"}
+	stamped := ts.stamp(given)
+	if stamped["timestamp"] != "2018-01-06T06:57:48.165Z" {
+		t.Errorf("timestamp == `%v` but wants `%v`", stamped["timestamp"], "2018-01-06T06:57:48.165Z")
+	}
+	if _, ok := given["timestamp"]; ok {
+		t.Error("given message should not be modified")
+	}
+
+	given = Message{"message": "hello", "timestamp": "user-provided"}
+	stamped = ts.stamp(given)
+	if stamped["timestamp"] != "user-provided" {
+		t.Errorf("timestamp == `%v` but wants `%v`", stamped["timestamp"], "user-provided")
+	}
+}
+
+func TestTimestamperWithCustomKey(t *testing.T) {
+	e := &encoder{}
+	e.SetTimestamper(&Timestamper{Key: "@ts", Precision: time.Second, UTC: true, Clock: fixedClock})
+
+	body, err := e.encodeMessage(Message{"message": "hello"})
+	if err != nil {
+		t.Fatal("unexpected err", err)
+	}
+	expected := `{"@ts":"2018-01-06T06:57:48Z","message":"hello"}`
+	if string(body) != expected {
+		t.Errorf("got == `%s` but wants `%s`", body, expected)
+	}
+
+	body, err = e.encodeFields([]Field{String("message", "hello")})
+	if err != nil {
+		t.Fatal("unexpected err", err)
+	}
+	expected = `{"@ts":"2018-01-06T06:57:48Z","message":"hello"}`
+	if string(body) != expected {
+		t.Errorf("got == `%s` but wants `%s`", body, expected)
+	}
+
+	body, err = e.encodeFields([]Field{String("message", "hello"), String("@ts", "user-provided")})
+	if err != nil {
+		t.Fatal("unexpected err", err)
+	}
+	expected = `{"message":"hello","@ts":"user-provided"}`
+	if string(body) != expected {
+		t.Errorf("got == `%s` but wants `%s`", body, expected)
+	}
+}
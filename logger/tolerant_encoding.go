@@ -0,0 +1,367 @@
+package logger
+
+import (
+	"encoding"
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	defaultMaxEncodingDepth  = 32
+	defaultMaxEncodingFields = 1000
+	defaultEncodingErrorsKey = "_encoding_errors"
+)
+
+// TolerantEncoding is a setting of the encoding that never drops a whole message on unmarshalable values.
+//
+// By default, if the message contains a value that `json.Marshal` cannot encode
+// (e.g. a channel, a func, NaN, a cyclic structure or a type whose MarshalJSON fails),
+// the whole message is lost with the foreground error.
+//
+// With TolerantEncoding, such values are substituted with the descriptive placeholder strings like "[unsupported type: chan int]",
+// and the message records what happened in the errors field, e.g.
+//
+//	{"message":"hello","callback":"[unsupported type: func()]","_encoding_errors":["callback: unsupported type: func()"]}
+//
+// And this caps the nesting depth and the number of fields of each object/array of such a message.
+// The message that `json.Marshal` can encode is sent as it is, without the sanitization.
+//
+// Please use this through SetTolerantEncoding() of the logger.
+type TolerantEncoding struct {
+	// MaxDepth is the maximum nesting depth. Deeper values are substituted. If this is less or equal to 0, 32 is used.
+	MaxDepth int
+
+	// MaxFields is the maximum number of fields of each object, and elements of each array.
+	// The exceeded ones are truncated. If this is less or equal to 0, 1000 is used.
+	MaxFields int
+
+	// ErrorsKey is the name of the field that records the encoding errors. If this is empty, "_encoding_errors" is used.
+	ErrorsKey string
+}
+
+// NewTolerantEncoding creates an instance of TolerantEncoding with the default settings.
+func NewTolerantEncoding() *TolerantEncoding {
+	return &TolerantEncoding{
+		MaxDepth:  defaultMaxEncodingDepth,
+		MaxFields: defaultMaxEncodingFields,
+		ErrorsKey: defaultEncodingErrorsKey,
+	}
+}
+
+// Sanitize returns a copy of the message that `json.Marshal` can always encode.
+func (t *TolerantEncoding) Sanitize(message Message) Message {
+	s := &sanitizer{
+		maxDepth:  t.MaxDepth,
+		maxFields: t.MaxFields,
+		visiting:  make(map[uintptr]bool),
+	}
+	if s.maxDepth <= 0 {
+		s.maxDepth = defaultMaxEncodingDepth
+	}
+	if s.maxFields <= 0 {
+		s.maxFields = defaultMaxEncodingFields
+	}
+
+	sanitized, _ := s.sanitizeMap("", reflect.ValueOf(map[string]interface{}(message)), 0).(map[string]interface{})
+	if sanitized == nil {
+		sanitized = make(map[string]interface{})
+	}
+	if len(s.errors) > 0 {
+		errorsKey := t.ErrorsKey
+		if errorsKey == "" {
+			errorsKey = defaultEncodingErrorsKey
+		}
+		sanitized[errorsKey] = s.errors
+	}
+	return Message(sanitized)
+}
+
+type sanitizer struct {
+	maxDepth  int
+	maxFields int
+	errors    []string
+	visiting  map[uintptr]bool
+}
+
+var (
+	jsonMarshalerType = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+	textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+)
+
+func (s *sanitizer) fail(path string, format string, args ...interface{}) string {
+	reason := fmt.Sprintf(format, args...)
+	if path == "" {
+		path = "."
+	}
+	s.errors = append(s.errors, path+": "+reason)
+	return "[" + reason + "]"
+}
+
+func (s *sanitizer) sanitize(path string, v reflect.Value, depth int) interface{} {
+	if !v.IsValid() {
+		return nil
+	}
+	if depth > s.maxDepth {
+		return s.fail(path, "max depth exceeded")
+	}
+
+	if v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return nil
+		}
+	}
+
+	if v.Type().Implements(jsonMarshalerType) {
+		return s.marshalJSON(path, v)
+	}
+	if v.Type().Implements(textMarshalerType) {
+		return s.marshalText(path, v)
+	}
+
+	switch v.Kind() {
+	case reflect.Bool, reflect.String,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return v.Interface()
+	case reflect.Float32, reflect.Float64:
+		f := v.Float()
+		if math.IsNaN(f) || math.IsInf(f, 0) {
+			return s.fail(path, "unsupported value: %v", f)
+		}
+		return v.Interface()
+	case reflect.Interface:
+		return s.sanitize(path, v.Elem(), depth)
+	case reflect.Ptr:
+		ptr := v.Pointer()
+		if s.visiting[ptr] {
+			return s.fail(path, "cyclic reference: %s", v.Type())
+		}
+		s.visiting[ptr] = true
+		defer delete(s.visiting, ptr)
+		return s.sanitize(path, v.Elem(), depth)
+	case reflect.Map:
+		return s.sanitizeMap(path, v, depth)
+	case reflect.Slice:
+		if v.IsNil() {
+			return nil
+		}
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			// []byte is encoded as base64 string
+			return v.Interface()
+		}
+		ptr := v.Pointer()
+		if s.visiting[ptr] {
+			return s.fail(path, "cyclic reference: %s", v.Type())
+		}
+		s.visiting[ptr] = true
+		defer delete(s.visiting, ptr)
+		return s.sanitizeArray(path, v, depth)
+	case reflect.Array:
+		return s.sanitizeArray(path, v, depth)
+	case reflect.Struct:
+		return s.sanitizeStruct(path, v, depth)
+	}
+
+	return s.fail(path, "unsupported type: %s", v.Type())
+}
+
+func (s *sanitizer) marshalJSON(path string, v reflect.Value) (result interface{}) {
+	defer func() {
+		if r := recover(); r != nil {
+			result = s.fail(path, "panic in MarshalJSON: %v", r)
+		}
+	}()
+
+	b, err := v.Interface().(json.Marshaler).MarshalJSON()
+	if err != nil {
+		return s.fail(path, "marshal error: %s", err)
+	}
+	if !json.Valid(b) {
+		return s.fail(path, "marshal error: invalid JSON from %s", v.Type())
+	}
+	return json.RawMessage(b)
+}
+
+func (s *sanitizer) marshalText(path string, v reflect.Value) (result interface{}) {
+	defer func() {
+		if r := recover(); r != nil {
+			result = s.fail(path, "panic in MarshalText: %v", r)
+		}
+	}()
+
+	b, err := v.Interface().(encoding.TextMarshaler).MarshalText()
+	if err != nil {
+		return s.fail(path, "marshal error: %s", err)
+	}
+	return string(b)
+}
+
+func (s *sanitizer) sanitizeMap(path string, v reflect.Value, depth int) interface{} {
+	if v.IsNil() {
+		return nil
+	}
+
+	ptr := v.Pointer()
+	if s.visiting[ptr] {
+		return s.fail(path, "cyclic reference: %s", v.Type())
+	}
+	s.visiting[ptr] = true
+	defer delete(s.visiting, ptr)
+
+	entries := make(mapEntries, 0, v.Len())
+	for _, k := range v.MapKeys() {
+		key, ok := s.mapKey(k)
+		if !ok {
+			return s.fail(path, "unsupported map key type: %s", v.Type().Key())
+		}
+		entries = append(entries, mapEntry{key: key, value: v.MapIndex(k)})
+	}
+	sort.Sort(entries)
+
+	if len(entries) > s.maxFields {
+		s.fail(path, "%d fields truncated", len(entries)-s.maxFields)
+		entries = entries[:s.maxFields]
+	}
+
+	sanitized := make(map[string]interface{}, len(entries))
+	for _, e := range entries {
+		sanitized[e.key] = s.sanitize(joinPath(path, e.key), e.value, depth+1)
+	}
+	return sanitized
+}
+
+type mapEntry struct {
+	key   string
+	value reflect.Value
+}
+
+// mapEntries sorts the entries by the key, so that the truncated fields are stable.
+type mapEntries []mapEntry
+
+func (e mapEntries) Len() int           { return len(e) }
+func (e mapEntries) Less(i, j int) bool { return e[i].key < e[j].key }
+func (e mapEntries) Swap(i, j int)      { e[i], e[j] = e[j], e[i] }
+
+func (s *sanitizer) mapKey(k reflect.Value) (string, bool) {
+	if k.Kind() == reflect.String {
+		return k.String(), true
+	}
+	if k.Type().Implements(textMarshalerType) {
+		b, err := k.Interface().(encoding.TextMarshaler).MarshalText()
+		return string(b), err == nil
+	}
+	switch k.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(k.Int(), 10), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return strconv.FormatUint(k.Uint(), 10), true
+	}
+	return "", false
+}
+
+func (s *sanitizer) sanitizeArray(path string, v reflect.Value, depth int) interface{} {
+	n := v.Len()
+	if n > s.maxFields {
+		s.fail(path, "%d elements truncated", n-s.maxFields)
+		n = s.maxFields
+	}
+
+	sanitized := make([]interface{}, n)
+	for i := 0; i < n; i++ {
+		sanitized[i] = s.sanitize(path+"["+strconv.Itoa(i)+"]", v.Index(i), depth+1)
+	}
+	return sanitized
+}
+
+func (s *sanitizer) sanitizeStruct(path string, v reflect.Value, depth int) interface{} {
+	sanitized := make(map[string]interface{})
+	s.collectStructFields(path, v, depth, sanitized)
+
+	if len(sanitized) > s.maxFields {
+		keys := make([]string, 0, len(sanitized))
+		for k := range sanitized {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		s.fail(path, "%d fields truncated", len(keys)-s.maxFields)
+		for _, k := range keys[s.maxFields:] {
+			delete(sanitized, k)
+		}
+	}
+	return sanitized
+}
+
+// collectStructFields collects the exported fields according to the `json` tags, like as `encoding/json` does roughly.
+func (s *sanitizer) collectStructFields(path string, v reflect.Value, depth int, dst map[string]interface{}) {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := field.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+
+		name, opts := tag, ""
+		if idx := strings.Index(tag, ","); idx >= 0 {
+			name, opts = tag[:idx], tag[idx+1:]
+		}
+
+		fv := v.Field(i)
+		if field.Anonymous && name == "" {
+			ft := field.Type
+			if ft.Kind() == reflect.Ptr {
+				if fv.IsNil() {
+					continue
+				}
+				ft, fv = ft.Elem(), fv.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				s.collectStructFields(path, fv, depth, dst)
+				continue
+			}
+		}
+
+		if field.PkgPath != "" {
+			// unexported
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		if strings.Contains(opts, "omitempty") && isEmptyValue(fv) {
+			continue
+		}
+
+		dst[name] = s.sanitize(joinPath(path, name), fv, depth+1)
+	}
+}
+
+func isEmptyValue(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
+		return v.Len() == 0
+	case reflect.Bool:
+		return !v.Bool()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int() == 0
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return v.Uint() == 0
+	case reflect.Float32, reflect.Float64:
+		return v.Float() == 0
+	case reflect.Interface, reflect.Ptr:
+		return v.IsNil()
+	}
+	return false
+}
+
+func joinPath(parent string, key string) string {
+	if parent == "" {
+		return key
+	}
+	return parent + "." + key
+}
@@ -0,0 +1,196 @@
+package logger
+
+import (
+	"encoding/json"
+	"errors"
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/moznion/logglily/internal/api"
+)
+
+type failingMarshaler struct{}
+
+func (failingMarshaler) MarshalJSON() ([]byte, error) {
+	return nil, errors.New("boom")
+}
+
+type node struct {
+	Name string `json:"name"`
+	Next *node  `json:"next,omitempty"`
+}
+
+func TestTolerantEncodingShouldSubstituteUnmarshalableValues(t *testing.T) {
+	given := Message{
+		"message":  "hello",
+		"callback": func() {},
+		"ch":       make(chan int),
+		"ratio":    math.NaN(),
+		"broken":   failingMarshaler{},
+		"at":       time.Date(2017, 1, 2, 3, 4, 5, 0, time.UTC),
+		"nested":   map[string]interface{}{"c": complex(1, 2)},
+	}
+	got := NewTolerantEncoding().Sanitize(given)
+
+	body, err := json.Marshal(got)
+	if err != nil {
+		t.Fatal("unexpected err", err)
+	}
+
+	var decoded map[string]interface{}
+	if err := json.Unmarshal(body, &decoded); err != nil {
+		t.Fatal("unexpected err", err)
+	}
+	expected := map[string]interface{}{
+		"message":  "hello",
+		"callback": "[unsupported type: func()]",
+		"ch":       "[unsupported type: chan int]",
+		"ratio":    "[unsupported value: NaN]",
+		"broken":   "[marshal error: boom]",
+		"at":       "2017-01-02T03:04:05Z",
+		"nested":   map[string]interface{}{"c": "[unsupported type: complex128]"},
+		"_encoding_errors": []interface{}{
+			"broken: marshal error: boom",
+			"callback: unsupported type: func()",
+			"ch: unsupported type: chan int",
+			"nested.c: unsupported type: complex128",
+			"ratio: unsupported value: NaN",
+		},
+	}
+	if !reflect.DeepEqual(decoded, expected) {
+		t.Errorf("decoded == %v but wants %v", decoded, expected)
+	}
+
+	if _, ok := given["_encoding_errors"]; ok {
+		t.Error("given message should not be modified")
+	}
+}
+
+func TestTolerantEncodingShouldBreakCycles(t *testing.T) {
+	n := &node{Name: "a"}
+	n.Next = &node{Name: "b", Next: n}
+
+	m := map[string]interface{}{}
+	m["self"] = m
+
+	got := NewTolerantEncoding().Sanitize(Message{"node": n, "map": m})
+	body, err := json.Marshal(got)
+	if err != nil {
+		t.Fatal("unexpected err", err)
+	}
+
+	s := string(body)
+	if !strings.Contains(s, `"node":{"name":"a","next":{"name":"b","next":"[cyclic reference: *logger.node]"}}`) {
+		t.Errorf("unexpected node encoding: %s", s)
+	}
+	if !strings.Contains(s, `"map":{"self":"[cyclic reference: map[string]interface {}]"}`) {
+		t.Errorf("unexpected map encoding: %s", s)
+	}
+}
+
+func TestTolerantEncodingShouldCapDepthAndFields(t *testing.T) {
+	tolerant := &TolerantEncoding{MaxDepth: 2, MaxFields: 2, ErrorsKey: "errs"}
+
+	got := tolerant.Sanitize(Message{
+		"deep": map[string]interface{}{"a": map[string]interface{}{"b": 1}},
+		"list": []int{1, 2, 3, 4},
+	})
+
+	expected := Message{
+		"deep": map[string]interface{}{"a": map[string]interface{}{"b": "[max depth exceeded]"}},
+		"list": []interface{}{1, 2},
+		"errs": []string{"deep.a.b: max depth exceeded", "list: 2 elements truncated"},
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("got == %v but wants %v", got, expected)
+	}
+
+	got = tolerant.Sanitize(Message{"a": 1, "b": 2, "c": 3})
+	if len(got) != 3 || got["c"] != nil || !reflect.DeepEqual(got["errs"], []string{".: 1 fields truncated"}) {
+		t.Errorf("got == %v", got)
+	}
+}
+
+func TestTolerantEncodingShouldFollowJSONTags(t *testing.T) {
+	type embedded struct {
+		ID int `json:"id"`
+	}
+	type value struct {
+		embedded
+		Name    string `json:"name"`
+		Ignored string `json:"-"`
+		Empty   string `json:",omitempty"`
+		Plain   bool
+		hidden  int
+		Fn      func() `json:"fn"`
+	}
+
+	got := NewTolerantEncoding().Sanitize(Message{"v": value{embedded: embedded{ID: 1}, Name: "x", Ignored: "y", hidden: 1}})
+	expected := map[string]interface{}{
+		"id":    1,
+		"name":  "x",
+		"Plain": false,
+		"fn":    "[unsupported type: func()]",
+	}
+	if !reflect.DeepEqual(got["v"], expected) {
+		t.Errorf("got == %v but wants %v", got["v"], expected)
+	}
+	if !reflect.DeepEqual(got["_encoding_errors"], []string{"v.fn: unsupported type: func()"}) {
+		t.Errorf("unexpected errors: %v", got["_encoding_errors"])
+	}
+}
+
+func TestSyncLoggerShouldNotDropMessageWithTolerantEncoding(t *testing.T) {
+	l := NewSyncLogger([]string{"test-tag"}, "test-token", true)
+	l.APIClient = &api.DummySuccClient{}
+
+	_, err := captureLogStdoutCapture(func() error {
+		return l.Log(Message{"message": "hello", "ch": make(chan int)})
+	})
+	if err == nil {
+		t.Error("should return error without TolerantEncoding")
+	}
+
+	l.SetTolerantEncoding(NewTolerantEncoding())
+	stdout, err := captureLogStdoutCapture(func() error {
+		return l.LogFields(String("message", "hello"), Object("ch", make(chan int)))
+	})
+	if err != nil {
+		t.Error("unexpected err", err)
+	}
+	expected := `{"_encoding_errors":["ch: unsupported type: chan int"],"ch":"[unsupported type: chan int]","message":"hello"}`
+	if stdout != expected {
+		t.Errorf("stdout == `%v` but wants `%v`", stdout, expected)
+	}
+}
+
+func TestSyncLoggerShouldNotSanitizeEncodableMessage(t *testing.T) {
+	l := NewSyncLogger([]string{"test-tag"}, "test-token", true)
+	l.APIClient = &api.DummySuccClient{}
+	l.SetTolerantEncoding(&TolerantEncoding{MaxFields: 1})
+
+	stdout, err := captureLogStdoutCapture(func() error {
+		return l.Log(Message{"message": "hello", "user": "john"})
+	})
+	if err != nil {
+		t.Error("unexpected err", err)
+	}
+	expected := `{"message":"hello","user":"john"}`
+	if stdout != expected {
+		t.Errorf("stdout == `%v` but wants `%v`", stdout, expected)
+	}
+
+	stdout, err = captureLogStdoutCapture(func() error {
+		return l.LogFields(String("message", "hello"), Object("user", map[string]string{"name": "john", "role": "admin"}))
+	})
+	if err != nil {
+		t.Error("unexpected err", err)
+	}
+	expected = `{"message":"hello","user":{"name":"john","role":"admin"}}`
+	if stdout != expected {
+		t.Errorf("stdout == `%v` but wants `%v`", stdout, expected)
+	}
+}
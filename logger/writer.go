@@ -0,0 +1,225 @@
+package logger
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"strings"
+	"sync"
+)
+
+const (
+	defaultWriterMessageKey = "message"
+
+	// maxWriterLineBytes is the maximum length of the line that Writer buffers. The longer line is split.
+	maxWriterLineBytes = 1024 * 1024
+)
+
+// Writer is an `io.Writer` adapter over the logger; this sends each line that is written as a message.
+// This is useful to send the output of `*log.Logger` and the third-party libraries that write into `io.Writer`, e.g.
+//
+//	w := logger.NewWriter(l)
+//	w.SetFields(logger.Message{"source": "stdlib"})
+//	log.SetOutput(w)
+//
+// Each line is converted into the message as follows;
+//   - the line that is a JSON object is parsed into Message (this can be disabled by SetJSONParsing(false));
+//     the numbers are kept as json.Number, so the large integers (e.g. IDs) don't lose the precision
+//   - the other lines are wrapped under the message key, e.g. {"message":"the line"}
+//
+// The static fields are added into each message; those don't overwrite the fields that the line has.
+//
+// If SetMultiline() is given, the continuation lines (e.g. stack traces) are joined into the previous line.
+// In that case the last event is held until the next event starts, so please call Flush() or Close() at the end.
+//
+// Writer is goroutine-safe.
+type Writer struct {
+	sink         Sink
+	messageKey   string
+	fields       Message
+	parseJSON    bool
+	continuation func(line string) bool
+
+	mu      sync.Mutex
+	partial []byte
+	pending []string
+}
+
+// NewWriter creates a new Writer that sends the lines through the sink.
+func NewWriter(sink Sink) *Writer {
+	return &Writer{
+		sink:       sink,
+		messageKey: defaultWriterMessageKey,
+		parseJSON:  true,
+	}
+}
+
+// SetMessageKey changes the key that the plain lines are wrapped under. The default is "message".
+func (w *Writer) SetMessageKey(key string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.messageKey = key
+}
+
+// SetFields sets the static fields that are added into each message, e.g. logger.Message{"source": "stdlib"}.
+func (w *Writer) SetFields(fields Message) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.fields = fields
+}
+
+// SetJSONParsing enables or disables to parse the JSON lines into Message. This is enabled by default.
+func (w *Writer) SetJSONParsing(enabled bool) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.parseJSON = enabled
+}
+
+// SetMultiline sets the predicate that reports whether the line continues the previous event.
+// The continuation lines are joined into the previous line with "\n". If nil is given, each line is an event; this is the default.
+//
+// IndentedLines is the predicate for the common stack traces.
+func (w *Writer) SetMultiline(continuation func(line string) bool) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.continuation = continuation
+}
+
+// IndentedLines reports whether the line is indented or empty.
+// This matches the continuation lines of Java-style stack traces ("\tat ...").
+// NOTE: this doesn't join a Go traceback into an event, because the "goroutine N [running]:" lines
+// and the function lines (e.g. "main.main()") of that are not indented.
+func IndentedLines(line string) bool {
+	return line == "" || line[0] == ' ' || line[0] == '\t'
+}
+
+// Write splits the bytes into lines and sends them.
+// The last line that doesn't end with newline is buffered until the following Write(), Flush() or Close().
+//
+// This returns the first error of the sink, but consumes the whole bytes anyway.
+func (w *Writer) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	var firstErr error
+	data := p
+	for len(data) > 0 {
+		i := bytes.IndexByte(data, '\n')
+		if i < 0 {
+			w.partial = append(w.partial, data...)
+			if len(w.partial) >= maxWriterLineBytes {
+				firstErr = keepFirstErr(firstErr, w.line(w.partial))
+				w.partial = w.partial[:0]
+			}
+			break
+		}
+
+		line := data[:i]
+		if len(w.partial) > 0 {
+			w.partial = append(w.partial, line...)
+			line = w.partial
+		}
+		firstErr = keepFirstErr(firstErr, w.line(line))
+		w.partial = w.partial[:0]
+		data = data[i+1:]
+	}
+	return len(p), firstErr
+}
+
+// Flush sends the buffered line and the pending multiline event.
+func (w *Writer) Flush() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	var firstErr error
+	if len(w.partial) > 0 {
+		firstErr = w.line(w.partial)
+		w.partial = w.partial[:0]
+	}
+	return keepFirstErr(firstErr, w.emitPending())
+}
+
+// Close flushes the buffered lines. Writer is still usable after Close().
+func (w *Writer) Close() error {
+	return w.Flush()
+}
+
+func (w *Writer) line(b []byte) error {
+	line := strings.TrimRight(string(b), "\r")
+
+	if w.continuation == nil {
+		if strings.TrimSpace(line) == "" {
+			return nil
+		}
+		return w.send([]string{line})
+	}
+
+	if len(w.pending) > 0 && w.continuation(line) {
+		w.pending = append(w.pending, line)
+		return nil
+	}
+
+	err := w.emitPending()
+	if strings.TrimSpace(line) != "" {
+		w.pending = append(w.pending, line)
+	}
+	return err
+}
+
+func (w *Writer) emitPending() error {
+	if len(w.pending) == 0 {
+		return nil
+	}
+
+	lines := w.pending
+	w.pending = nil
+
+	// drop the trailing empty lines
+	for len(lines) > 1 && strings.TrimSpace(lines[len(lines)-1]) == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return w.send(lines)
+}
+
+func (w *Writer) send(lines []string) error {
+	var message Message
+	if w.parseJSON && len(lines) == 1 {
+		message = parseJSONLine(lines[0])
+	}
+	if message == nil {
+		message = Message{w.messageKey: strings.Join(lines, "\n")}
+	}
+
+	for k, v := range w.fields {
+		if _, ok := message[k]; !ok {
+			message[k] = v
+		}
+	}
+	return w.sink.Send(message)
+}
+
+func parseJSONLine(line string) Message {
+	trimmed := strings.TrimSpace(line)
+	if !strings.HasPrefix(trimmed, "{") {
+		return nil
+	}
+
+	decoder := json.NewDecoder(strings.NewReader(trimmed))
+	decoder.UseNumber()
+	var message Message
+	if err := decoder.Decode(&message); err != nil {
+		return nil
+	}
+	if _, err := decoder.Token(); err != io.EOF {
+		// the line has the trailing data after the object
+		return nil
+	}
+	return message
+}
+
+func keepFirstErr(firstErr error, err error) error {
+	if firstErr != nil {
+		return firstErr
+	}
+	return err
+}
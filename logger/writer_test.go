@@ -0,0 +1,106 @@
+package logger
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"reflect"
+	"testing"
+)
+
+func TestWriterShouldSendEachLine(t *testing.T) {
+	sink := &recordingSink{}
+	w := NewWriter(sink)
+	w.SetFields(Message{"source": "stdlib", "level": "info"})
+
+	stdlog := log.New(w, "", 0)
+	stdlog.Print("hello")
+	w.Write([]byte(`{"message":"json line","level":"warn"}` + "\r\n\n   \nnot {json}\n"))
+
+	expected := []Message{
+		{"message": "hello", "source": "stdlib", "level": "info"},
+		{"message": "json line", "source": "stdlib", "level": "warn"},
+		{"message": "not {json}", "source": "stdlib", "level": "info"},
+	}
+	if !reflect.DeepEqual(sink.messages, expected) {
+		t.Errorf("messages == %v but wants %v", sink.messages, expected)
+	}
+}
+
+func TestWriterShouldBufferPartialLine(t *testing.T) {
+	sink := &recordingSink{}
+	w := NewWriter(sink)
+	w.SetMessageKey("msg")
+	w.SetJSONParsing(false)
+
+	w.Write([]byte("hel"))
+	w.Write([]byte(`lo {"a":1}`))
+	if len(sink.messages) != 0 {
+		t.Fatalf("partial line should be buffered: %v", sink.messages)
+	}
+	w.Write([]byte("\n{\"a\":1}\nrest"))
+	w.Close()
+
+	expected := []Message{
+		{"msg": `hello {"a":1}`},
+		{"msg": `{"a":1}`},
+		{"msg": "rest"},
+	}
+	if !reflect.DeepEqual(sink.messages, expected) {
+		t.Errorf("messages == %v but wants %v", sink.messages, expected)
+	}
+}
+
+func TestWriterShouldJoinMultilineEvents(t *testing.T) {
+	sink := &recordingSink{}
+	w := NewWriter(sink)
+	w.SetMultiline(IndentedLines)
+
+	w.Write([]byte("Exception in thread \"main\" java.lang.NullPointerException\n\tat Main.run(Main.java:10)\n\tat Main.main(Main.java:3)\n"))
+	w.Write([]byte("next event\n"))
+	if len(sink.messages) != 1 {
+		t.Fatalf("len(messages) == %d but wants 1", len(sink.messages))
+	}
+	w.Write([]byte("  continued\n\n"))
+	w.Flush()
+
+	expected := []Message{
+		{"message": "Exception in thread \"main\" java.lang.NullPointerException\n\tat Main.run(Main.java:10)\n\tat Main.main(Main.java:3)"},
+		{"message": "next event\n  continued"},
+	}
+	if !reflect.DeepEqual(sink.messages, expected) {
+		t.Errorf("messages == %v but wants %v", sink.messages, expected)
+	}
+}
+
+func TestWriterShouldReturnSinkError(t *testing.T) {
+	w := NewWriter(SinkFunc(func(message Message) error {
+		return errors.New("boom")
+	}))
+
+	n, err := w.Write([]byte("a\nb\n"))
+	if n != 4 || err == nil || err.Error() != "boom" {
+		t.Errorf("n == %d, err == %v", n, err)
+	}
+}
+
+func TestWriterShouldKeepPrecisionOfLargeIntegers(t *testing.T) {
+	sink := &recordingSink{}
+	w := NewWriter(sink)
+
+	w.Write([]byte(`{"id":9007199254740993,"ratio":0.5}` + "\n" + `{"id":1} trailing` + "\n"))
+
+	if len(sink.messages) != 2 {
+		t.Fatalf("messages == %v", sink.messages)
+	}
+	if id := sink.messages[0]["id"]; id != json.Number("9007199254740993") {
+		t.Errorf("id == %#v but wants %q", id, "9007199254740993")
+	}
+	body, _ := json.Marshal(sink.messages[0])
+	if string(body) != `{"id":9007199254740993,"ratio":0.5}` {
+		t.Errorf("body == %s", body)
+	}
+	if sink.messages[1]["message"] != `{"id":1} trailing` {
+		t.Errorf("the line that has the trailing data should be wrapped: %v", sink.messages[1])
+	}
+}
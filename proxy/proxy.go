@@ -0,0 +1,296 @@
+// Package proxy provides the local HTTP proxy that is compatible with the loggly HTTP API.
+//
+// The short-lived processes open their own connections to loggly for each event. The proxy accepts the events
+// on the same routes as loggly locally, i.e.
+//
+//	POST /inputs/{token}/tag/{tags}/   # an event
+//	POST /bulk/{token}/tag/{tags}/     # newline-separated events
+//
+// and it re-batches them per the token and the tags through AsyncBulkLogger. The clients only have to point their base URL
+// at the proxy; please see api.NewClient.
+package proxy
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/moznion/logglily/api"
+	"github.com/moznion/logglily/logger"
+)
+
+const (
+	// maxEventSize and maxBulkSize are the limits of loggly.
+	maxEventSize = 1024 * 1024
+	maxBulkSize  = 5 * 1024 * 1024
+
+	defaultIdleTimeout = 5 * time.Minute
+
+	// routeQueueSize is the number of the requests that are queued for a route; the further requests wait for the queue.
+	routeQueueSize = 100
+)
+
+var okResponse = []byte(`{"response":"ok"}`)
+
+// Proxy is the http.Handler that accepts the events and forwards them into loggly.
+//
+// Each pair of the token and the tags has its own AsyncBulkLogger that is created lazily,
+// and that is shut down after it has been idle for the idle timeout.
+// The periodic flushing of the loggers is disabled; please call Flush periodically.
+//
+// NOTE: The proxy responds "ok" after the events are queued; a worker of each route buffers them into the logger
+// in the order of the requests. The errors of the delivery are notified to the error handler because the client has gone already.
+type Proxy struct {
+	isHTTPS     bool
+	idleTimeout time.Duration
+	onError     func(err error)
+	apiClient   func(token string, tags []string) api.Client
+	now         func() time.Time
+
+	mu       sync.Mutex
+	routes   map[string]*route
+	closed   bool
+	shutdown sync.WaitGroup
+}
+
+// route is the destination of the pair of the token and the tags.
+type route struct {
+	token    string
+	tags     []string
+	logger   *logger.AsyncBulkLogger
+	queue    chan [][]byte
+	done     chan struct{}
+	pending  sync.WaitGroup
+	lastUsed time.Time
+}
+
+// NewProxy creates a new Proxy.
+func NewProxy(isHTTPS bool) *Proxy {
+	return &Proxy{
+		isHTTPS:     isHTTPS,
+		idleTimeout: defaultIdleTimeout,
+		onError:     func(err error) {},
+		now:         time.Now,
+		routes:      make(map[string]*route),
+	}
+}
+
+// SetErrorHandler sets the handler that is called with the errors of the delivery. It is called from the multiple goroutines.
+func (p *Proxy) SetErrorHandler(handler func(err error)) {
+	p.onError = handler
+}
+
+// SetIdleTimeout changes the duration to keep the idle logger. The default is 5 minutes.
+func (p *Proxy) SetIdleTimeout(timeout time.Duration) {
+	p.idleTimeout = timeout
+}
+
+// SetAPIClient sets the function that creates the API client for the token and the tags.
+// By default, the loggers use the default API client of loggly.
+func (p *Proxy) SetAPIClient(newClient func(token string, tags []string) api.Client) {
+	p.apiClient = newClient
+}
+
+func (p *Proxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	kind, token, tags, ok := parsePath(r.URL.Path)
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	limit := int64(maxEventSize)
+	if kind == "bulk" {
+		limit = maxBulkSize
+	}
+	body, err := ioutil.ReadAll(http.MaxBytesReader(w, r.Body, limit))
+	if err != nil {
+		http.Error(w, "request body is too large", http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	var events [][]byte
+	if kind == "bulk" {
+		events = splitBulk(body)
+	} else if event := normalizeEvent(body); event != nil {
+		events = [][]byte{event}
+	}
+
+	if err := p.log(token, tags, events); err != nil {
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(okResponse)
+}
+
+// parsePath parses "/{inputs|bulk}/{token}[/tag/{tags}][/]".
+func parsePath(path string) (string, string, []string, bool) {
+	parts := strings.Split(strings.Trim(path, "/"), "/")
+	if len(parts) < 2 || (parts[0] != "inputs" && parts[0] != "bulk") || parts[1] == "" {
+		return "", "", nil, false
+	}
+
+	var tags []string
+	switch {
+	case len(parts) == 2:
+	case len(parts) == 4 && parts[2] == "tag":
+		for _, tag := range strings.Split(parts[3], ",") {
+			if tag = strings.TrimSpace(tag); tag != "" {
+				tags = append(tags, tag)
+			}
+		}
+	default:
+		return "", "", nil, false
+	}
+	return parts[0], parts[1], tags, true
+}
+
+// splitBulk splits the bulk payload into the events.
+func splitBulk(body []byte) [][]byte {
+	var events [][]byte
+	for _, line := range bytes.Split(body, []byte{'\n'}) {
+		if line = bytes.TrimRight(line, "\r"); len(bytes.TrimSpace(line)) > 0 {
+			events = append(events, line)
+		}
+	}
+	return events
+}
+
+// normalizeEvent makes the event a line to be a part of the bulk payload. The JSON is compacted,
+// and the plain text that has the newlines is wrapped into JSON under "message" because the bulk payload cannot hold them.
+func normalizeEvent(body []byte) []byte {
+	body = bytes.TrimSpace(body)
+	if len(body) == 0 {
+		return nil
+	}
+	if json.Valid(body) {
+		buf := &bytes.Buffer{}
+		if err := json.Compact(buf, body); err == nil {
+			return buf.Bytes()
+		}
+	}
+	if bytes.IndexByte(body, '\n') < 0 {
+		return body
+	}
+	b, _ := json.Marshal(logger.Message{"message": string(body)})
+	return b
+}
+
+// log queues the events into the route of the token and the tags, in the order of the request.
+//
+// This doesn't wait for the logger; the worker of the route sends the bulk that is over the threshold.
+// This blocks only while the queue of the route is full.
+func (p *Proxy) log(token string, tags []string, events [][]byte) error {
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		return errors.New("proxy is shutting down")
+	}
+	if len(events) == 0 {
+		p.mu.Unlock()
+		return nil
+	}
+
+	key := token + "/" + strings.Join(tags, ",")
+	rt, ok := p.routes[key]
+	if !ok {
+		l, err := logger.NewAsyncBulkLogger(tags, token, p.isHTTPS, maxBulkSize, 0)
+		if err != nil {
+			p.mu.Unlock()
+			return err
+		}
+		if p.apiClient != nil {
+			l.APIClient = p.apiClient(token, tags)
+		}
+		rt = &route{token: token, tags: tags, logger: l, queue: make(chan [][]byte, routeQueueSize), done: make(chan struct{})}
+		p.routes[key] = rt
+		go p.work(rt)
+	}
+	rt.lastUsed = p.now()
+	// The queue of the route must not be closed until the events are queued.
+	rt.pending.Add(1)
+	p.mu.Unlock()
+	defer rt.pending.Done()
+
+	rt.queue <- events
+	return nil
+}
+
+// work buffers the queued events into the logger of the route in the order of the requests.
+func (p *Proxy) work(rt *route) {
+	defer close(rt.done)
+	for events := range rt.queue {
+		result, err := rt.logger.LogRawBatch(events)
+		if err != nil {
+			p.onError(fmt.Errorf("failed to log [token=%s, tags=%s]: %s", rt.token, strings.Join(rt.tags, ","), err))
+			continue
+		}
+		p.handleResult(rt, result)
+	}
+}
+
+func (p *Proxy) handleResult(rt *route, result *logger.AsyncBulkResult) {
+	err := <-result.AsyncErrChan
+	failed := <-result.FailedMessagesChan
+	if err != nil {
+		p.onError(fmt.Errorf("failed to send %d events [token=%s, tags=%s]: %s", len(failed), rt.token, strings.Join(rt.tags, ","), err))
+	}
+}
+
+// Flush flushes the buffered events of all loggers, and shuts down the loggers that have been idle.
+func (p *Proxy) Flush() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	now := p.now()
+	for key, rt := range p.routes {
+		if now.Sub(rt.lastUsed) >= p.idleTimeout {
+			delete(p.routes, key)
+			p.shutdownRoute(rt)
+			continue
+		}
+		rt.pending.Add(1)
+		result := rt.logger.Flush()
+		go func(rt *route) {
+			defer rt.pending.Done()
+			p.handleResult(rt, result)
+		}(rt)
+	}
+}
+
+// shutdownRoute shuts down the logger after the events that are queued are buffered.
+func (p *Proxy) shutdownRoute(rt *route) {
+	p.shutdown.Add(1)
+	go func() {
+		defer p.shutdown.Done()
+		rt.pending.Wait()
+		close(rt.queue)
+		<-rt.done
+		p.handleResult(rt, rt.logger.Shutdown())
+	}()
+}
+
+// Close refuses the new events, and shuts down all loggers. This waits until the buffered events are sent.
+func (p *Proxy) Close() {
+	p.mu.Lock()
+	p.closed = true
+	for key, rt := range p.routes {
+		delete(p.routes, key)
+		p.shutdownRoute(rt)
+	}
+	p.mu.Unlock()
+
+	p.shutdown.Wait()
+}
@@ -0,0 +1,181 @@
+package proxy
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/moznion/logglily/api"
+	internalAPI "github.com/moznion/logglily/internal/api"
+)
+
+func newTestProxy(status int) (*Proxy, *internalAPI.RecordingClient, *[]error) {
+	mu := &sync.Mutex{}
+	client := &internalAPI.RecordingClient{Status: status}
+	var errs []error
+
+	p := NewProxy(true)
+	p.SetAPIClient(func(token string, tags []string) api.Client {
+		return client.Labeled(token + "/" + strings.Join(tags, ","))
+	})
+	p.SetErrorHandler(func(err error) {
+		mu.Lock()
+		defer mu.Unlock()
+		errs = append(errs, err)
+	})
+	return p, client, &errs
+}
+
+func post(t *testing.T, p *Proxy, path string, body string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	p.ServeHTTP(w, httptest.NewRequest("POST", path, strings.NewReader(body)))
+	return w
+}
+
+func TestProxyShouldRebatchEventsPerTokenAndTags(t *testing.T) {
+	p, client, errs := newTestProxy(0)
+
+	for _, req := range []struct{ path, body string }{
+		{"/inputs/token1/tag/a,b/", "{\n  \"message\": \"pretty\"\n}\n"},
+		{"/inputs/token1/tag/a,b", "plain"},
+		{"/bulk/token1/tag/a,b/", "{\"n\":1}\r\n\n{\"n\":2}\n"},
+		{"/inputs/token1/tag/c/", "multi\nline"},
+		{"/inputs/token2", `{"n":3}`},
+	} {
+		w := post(t, p, req.path, req.body)
+		if w.Code != http.StatusOK || w.Body.String() != `{"response":"ok"}` {
+			t.Errorf("%s responds %d %s", req.path, w.Code, w.Body)
+		}
+	}
+	p.Close()
+
+	bulks := client.LinesByLabel()
+	expected := map[string][]string{
+		"token1/a,b": {`{"message":"pretty"}`, `plain`, `{"n":1}`, `{"n":2}`},
+		"token1/c":   {`{"message":"multi\nline"}`},
+		"token2/":    {`{"n":3}`},
+	}
+	if !reflect.DeepEqual(bulks, expected) {
+		t.Errorf("bulks == %q but wants %q", bulks, expected)
+	}
+	if len(*errs) != 0 {
+		t.Errorf("errors == %v", *errs)
+	}
+}
+
+func TestProxyShouldRejectUnknownRoutesAndMethods(t *testing.T) {
+	p, _, _ := newTestProxy(0)
+	defer p.Close()
+
+	for _, path := range []string{"/", "/inputs/", "/apiv2/search", "/inputs/token/unknown/x/", "/bulk/token/tag/a/extra/"} {
+		if w := post(t, p, path, "x"); w.Code != http.StatusNotFound {
+			t.Errorf("%s responds %d but wants 404", path, w.Code)
+		}
+	}
+
+	w := httptest.NewRecorder()
+	p.ServeHTTP(w, httptest.NewRequest("GET", "/inputs/token/tag/a/", nil))
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("GET responds %d but wants 405", w.Code)
+	}
+
+	if w := post(t, p, "/inputs/token/", strings.Repeat("x", maxEventSize+1)); w.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("too large event responds %d but wants 413", w.Code)
+	}
+}
+
+func TestProxyShouldFlushAndEvictIdleLoggers(t *testing.T) {
+	p, client, _ := newTestProxy(0)
+	now := time.Date(2018, 1, 5, 0, 0, 0, 0, time.UTC)
+	p.now = func() time.Time { return now }
+	p.SetIdleTimeout(time.Minute)
+
+	post(t, p, "/inputs/token/tag/a/", "first")
+	post(t, p, "/inputs/token/tag/b/", "second")
+
+	now = now.Add(30 * time.Second)
+	post(t, p, "/inputs/token/tag/b/", "third")
+
+	now = now.Add(40 * time.Second)
+	p.Flush()
+	if _, ok := p.routes["token/a"]; ok {
+		t.Error("idle logger should be evicted")
+	}
+	if _, ok := p.routes["token/b"]; !ok {
+		t.Error("active logger should be kept")
+	}
+
+	p.Close()
+	bulks := client.LinesByLabel()
+	if len(bulks["token/a"]) != 1 || len(bulks["token/b"]) != 2 {
+		t.Errorf("bulks == %q", bulks)
+	}
+}
+
+func TestProxyShouldNotifyDeliveryErrors(t *testing.T) {
+	p, _, errs := newTestProxy(http.StatusForbidden)
+	post(t, p, "/inputs/token/tag/a/", "event")
+	p.Close()
+
+	if len(*errs) != 1 || !strings.Contains((*errs)[0].Error(), "failed to send 1 events [token=token, tags=a]") {
+		t.Errorf("errors == %v", *errs)
+	}
+}
+
+func TestProxyShouldRefuseEventsAfterClose(t *testing.T) {
+	p, _, _ := newTestProxy(0)
+	p.Close()
+	if w := post(t, p, "/inputs/token/", "event"); w.Code != http.StatusServiceUnavailable {
+		t.Errorf("responds %d but wants 503", w.Code)
+	}
+}
+
+func TestProxyShouldRespondWithoutWaitingForDelivery(t *testing.T) {
+	p, client, _ := newTestProxy(0)
+	entered := make(chan struct{}, 1)
+	release := make(chan struct{})
+	client.Respond = func(body []byte) (int, error) {
+		select {
+		case entered <- struct{}{}:
+		default:
+		}
+		<-release
+		return http.StatusOK, nil
+	}
+
+	// blocks the logger by the delivery of the first event
+	post(t, p, "/inputs/token/", "first")
+	deadline := time.After(5 * time.Second)
+wait:
+	for {
+		p.Flush()
+		select {
+		case <-entered:
+			break wait
+		case <-deadline:
+			t.Fatal("the delivery is not started")
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+
+	responded := make(chan int, 1)
+	go func() { responded <- post(t, p, "/bulk/token/", "second\nthird").Code }()
+	select {
+	case code := <-responded:
+		if code != http.StatusOK {
+			t.Errorf("responds %d but wants 200", code)
+		}
+	case <-time.After(5 * time.Second):
+		t.Error("the request should not wait for the delivery")
+	}
+
+	close(release)
+	p.Close()
+	if lines := client.LinesByLabel()["token/"]; !reflect.DeepEqual(lines, []string{"first", "second", "third"}) {
+		t.Errorf("lines == %q", lines)
+	}
+}
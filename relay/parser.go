@@ -0,0 +1,251 @@
+package relay
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/moznion/logglily/logger"
+)
+
+// The keys of the fields that are parsed from the syslog message.
+const (
+	FieldFacility       = "facility"
+	FieldSeverity       = "severity"
+	FieldTimestamp      = "timestamp"
+	FieldHostname       = "hostname"
+	FieldApp            = "app"
+	FieldProcID         = "proc_id"
+	FieldMsgID          = "msg_id"
+	FieldStructuredData = "structured_data"
+	FieldMessage        = "message"
+)
+
+var facilities = []string{
+	"kern", "user", "mail", "daemon", "auth", "syslog", "lpr", "news",
+	"uucp", "cron", "authpriv", "ftp", "ntp", "security", "console", "solaris-cron",
+	"local0", "local1", "local2", "local3", "local4", "local5", "local6", "local7",
+}
+
+var severities = []string{"emerg", "alert", "crit", "err", "warning", "notice", "info", "debug"}
+
+const nilValue = "-"
+
+var utf8BOM = []byte{0xef, 0xbb, 0xbf}
+
+// Parse parses the syslog message of RFC5424 or RFC3164 into Message, e.g.
+//
+//	<165>1 2003-10-11T22:14:15.003Z mymachine evntslog - ID47 [exampleSDID@32473 iut="3"] An application event
+//	<34>Oct 11 22:14:15 mymachine su[123]: 'su root' failed for lonvick on /dev/pts/8
+//
+// The fields that are nil ("-") or missing are omitted. The structured data is parsed into the map of the maps,
+// e.g. {"exampleSDID@32473": {"iut": "3"}}. The timestamp of RFC3164 doesn't have the year and the time zone,
+// so that is assumed to be in the local time of the current year.
+func Parse(b []byte) (logger.Message, error) {
+	return parse(b, time.Now())
+}
+
+func parse(b []byte, now time.Time) (logger.Message, error) {
+	b = bytes.TrimRight(b, "\r\n\x00")
+
+	pri, rest, err := parsePriority(b)
+	if err != nil {
+		return nil, err
+	}
+	message := logger.Message{
+		FieldFacility: facilityName(pri / 8),
+		FieldSeverity: severities[pri%8],
+	}
+
+	if len(rest) >= 2 && rest[0] == '1' && rest[1] == ' ' {
+		return message, parse5424(rest[2:], message)
+	}
+	parse3164(rest, message, now)
+	return message, nil
+}
+
+func parsePriority(b []byte) (int, []byte, error) {
+	if len(b) < 3 || b[0] != '<' {
+		return 0, nil, errors.New("priority is missing")
+	}
+	head := b
+	if len(head) > 5 {
+		head = head[:5]
+	}
+	end := bytes.IndexByte(head, '>')
+	if end < 2 {
+		return 0, nil, errors.New("priority is malformed")
+	}
+	pri, err := strconv.Atoi(string(b[1:end]))
+	if err != nil || pri < 0 || pri > 191 {
+		return 0, nil, fmt.Errorf("priority is malformed [given: %s]", b[1:end])
+	}
+	return pri, b[end+1:], nil
+}
+
+func facilityName(code int) string {
+	if code < len(facilities) {
+		return facilities[code]
+	}
+	return strconv.Itoa(code)
+}
+
+// parse5424 parses the rest of RFC5424 message after the version.
+func parse5424(b []byte, message logger.Message) error {
+	keys := []string{FieldTimestamp, FieldHostname, FieldApp, FieldProcID, FieldMsgID}
+	for _, key := range keys {
+		var token []byte
+		token, b = nextToken(b)
+		if token == nil {
+			return fmt.Errorf("%s is missing", key)
+		}
+		if string(token) == nilValue {
+			continue
+		}
+		message[key] = string(token)
+	}
+
+	if len(b) == 0 {
+		return errors.New("structured data is missing")
+	}
+	if b[0] == '-' {
+		b = b[1:]
+	} else {
+		sd, rest, err := parseStructuredData(b)
+		if err != nil {
+			return err
+		}
+		message[FieldStructuredData] = sd
+		b = rest
+	}
+
+	if len(b) > 0 && b[0] == ' ' {
+		b = bytes.TrimPrefix(b[1:], utf8BOM)
+		if len(b) > 0 {
+			message[FieldMessage] = toString(b)
+		}
+	}
+	return nil
+}
+
+func nextToken(b []byte) ([]byte, []byte) {
+	if len(b) == 0 {
+		return nil, b
+	}
+	i := bytes.IndexByte(b, ' ')
+	if i < 0 {
+		return b, nil
+	}
+	return b[:i], b[i+1:]
+}
+
+// parseStructuredData parses the elements like `[id param="value" ...][id2 ...]`.
+func parseStructuredData(b []byte) (map[string]interface{}, []byte, error) {
+	sd := make(map[string]interface{})
+	for len(b) > 0 && b[0] == '[' {
+		b = b[1:]
+		end := bytes.IndexAny(b, " ]")
+		if end <= 0 {
+			return nil, nil, errors.New("structured data is malformed")
+		}
+		id := string(b[:end])
+		b = b[end:]
+
+		params := make(map[string]interface{})
+		for {
+			if len(b) == 0 {
+				return nil, nil, errors.New("structured data is not terminated")
+			}
+			if b[0] == ']' {
+				b = b[1:]
+				break
+			}
+			b = b[1:] // space
+
+			eq := bytes.IndexByte(b, '=')
+			if eq <= 0 || len(b) < eq+2 || b[eq+1] != '"' {
+				return nil, nil, fmt.Errorf("parameter of %s is malformed", id)
+			}
+			name := string(b[:eq])
+			value, rest, err := parseParamValue(b[eq+2:])
+			if err != nil {
+				return nil, nil, fmt.Errorf("parameter %s of %s is malformed: %s", name, id, err)
+			}
+			params[name] = value
+			b = rest
+		}
+		sd[id] = params
+	}
+	return sd, b, nil
+}
+
+// parseParamValue parses the quoted value after the opening quote; `\"`, `\\` and `\]` are unescaped.
+func parseParamValue(b []byte) (string, []byte, error) {
+	var value []byte
+	for i := 0; i < len(b); i++ {
+		switch b[i] {
+		case '\\':
+			if i+1 < len(b) && (b[i+1] == '"' || b[i+1] == '\\' || b[i+1] == ']') {
+				i++
+			}
+			value = append(value, b[i])
+		case '"':
+			return toString(value), b[i+1:], nil
+		default:
+			value = append(value, b[i])
+		}
+	}
+	return "", nil, errors.New("value is not terminated")
+}
+
+// parse3164 parses the rest of RFC3164 message after the priority. That is lenient because the format varies in the wild;
+// the hostname is often omitted (e.g. the messages via /dev/log), and the message that has no timestamp is taken as the content.
+func parse3164(b []byte, message logger.Message, now time.Time) {
+	const stampLayout = "Jan _2 15:04:05"
+	if len(b) >= len(stampLayout) {
+		if t, err := time.ParseInLocation(stampLayout, string(b[:len(stampLayout)]), now.Location()); err == nil {
+			t = t.AddDate(now.Year(), 0, 0)
+			if t.After(now.AddDate(0, 0, 1)) {
+				// the message of the last December that is received in January
+				t = t.AddDate(-1, 0, 0)
+			}
+			message[FieldTimestamp] = t.Format(time.RFC3339)
+			b = bytes.TrimLeft(b[len(stampLayout):], " ")
+
+			if token, rest := nextToken(b); token != nil && !isTag(token) && rest != nil {
+				message[FieldHostname] = string(token)
+				b = rest
+			}
+		}
+	}
+
+	if token, rest := nextToken(b); token != nil && isTag(token) {
+		tag := string(bytes.TrimSuffix(token, []byte{':'}))
+		if i := strings.IndexByte(tag, '['); i > 0 && strings.HasSuffix(tag, "]") {
+			message[FieldProcID] = tag[i+1 : len(tag)-1]
+			tag = tag[:i]
+		}
+		message[FieldApp] = tag
+		b = rest
+	}
+
+	if len(b) > 0 {
+		message[FieldMessage] = toString(b)
+	}
+}
+
+// isTag returns true if the token looks like the TAG of RFC3164, e.g. "sshd[123]:" or "cron:".
+func isTag(token []byte) bool {
+	return bytes.HasSuffix(token, []byte{':'}) || bytes.HasSuffix(token, []byte{']'})
+}
+
+func toString(b []byte) string {
+	if utf8.Valid(b) {
+		return string(b)
+	}
+	return strings.ToValidUTF8(string(b), string(utf8.RuneError))
+}
@@ -0,0 +1,89 @@
+package relay
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/moznion/logglily/logger"
+)
+
+func TestParse(t *testing.T) {
+	now := time.Date(2018, 1, 5, 10, 0, 0, 0, time.UTC)
+
+	for given, expected := range map[string]logger.Message{
+		`<165>1 2003-10-11T22:14:15.003Z mymachine.example.com evntslog - ID47 [exampleSDID@32473 iut="3" eventSource="Application"][examplePriority@32473 class="high"] An application event`: {
+			"facility":  "local4",
+			"severity":  "notice",
+			"timestamp": "2003-10-11T22:14:15.003Z",
+			"hostname":  "mymachine.example.com",
+			"app":       "evntslog",
+			"msg_id":    "ID47",
+			"structured_data": map[string]interface{}{
+				"exampleSDID@32473":     map[string]interface{}{"iut": "3", "eventSource": "Application"},
+				"examplePriority@32473": map[string]interface{}{"class": "high"},
+			},
+			"message": "An application event",
+		},
+		"<34>1 2003-10-11T22:14:15.003Z mymachine su 123 - - \xef\xbb\xbf'su root' failed\n": {
+			"facility":  "auth",
+			"severity":  "crit",
+			"timestamp": "2003-10-11T22:14:15.003Z",
+			"hostname":  "mymachine",
+			"app":       "su",
+			"proc_id":   "123",
+			"message":   "'su root' failed",
+		},
+		`<13>1 - - - - - [id escaped="a\"b\\c\]d"]`: {
+			"facility":        "user",
+			"severity":        "notice",
+			"structured_data": map[string]interface{}{"id": map[string]interface{}{"escaped": `a"b\c]d`}},
+		},
+		`<34>Oct 11 22:14:15 mymachine su[123]: 'su root' failed for lonvick`: {
+			"facility":  "auth",
+			"severity":  "crit",
+			"timestamp": "2017-10-11T22:14:15Z",
+			"hostname":  "mymachine",
+			"app":       "su",
+			"proc_id":   "123",
+			"message":   "'su root' failed for lonvick",
+		},
+		`<78>Jan  5 09:59:00 CRON[42]: (root) CMD (backup)`: {
+			"facility":  "cron",
+			"severity":  "info",
+			"timestamp": "2018-01-05T09:59:00Z",
+			"app":       "CRON",
+			"proc_id":   "42",
+			"message":   "(root) CMD (backup)",
+		},
+		`<14>plain message from the appliance`: {
+			"facility": "user",
+			"severity": "info",
+			"message":  "plain message from the appliance",
+		},
+	} {
+		got, err := parse([]byte(given), now)
+		if err != nil {
+			t.Errorf("parse(%q) returns error: %s", given, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, expected) {
+			t.Errorf("parse(%q) == %v but wants %v", given, got, expected)
+		}
+	}
+}
+
+func TestParseShouldFailWithMalformedMessage(t *testing.T) {
+	for _, given := range []string{
+		"no priority",
+		"<192>too large priority",
+		"<abc>not number",
+		"<13>1 2003-10-11T22:14:15.003Z host",
+		`<13>1 - - - - - [id param="unterminated]`,
+		`<13>1 - - - - - [id param]`,
+	} {
+		if _, err := Parse([]byte(given)); err == nil {
+			t.Errorf("Parse(%q) should fail", given)
+		}
+	}
+}
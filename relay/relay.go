@@ -0,0 +1,374 @@
+// Package relay receives the syslog messages and forwards them into loggly through AsyncBulkLogger.
+//
+// This is for the appliances and the daemons that can only emit syslog. Relay listens on UDP, TCP and Unix sockets,
+// parses RFC5424 and RFC3164 messages into Message (please see Parse), and forwards them with batching of the bulk logger.
+package relay
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strconv"
+	"sync"
+	"sync/atomic"
+
+	"github.com/moznion/logglily/logger"
+)
+
+// The keys of the fields that Relay adds.
+const (
+	FieldRemoteAddr = "remote_addr"
+	FieldParseError = "parse_error"
+)
+
+const (
+	defaultQueueSize    = 10000
+	maxBatchSize        = 1000
+	maxMessageSize      = 64 * 1024
+	maxOctetCountDigits = 8
+)
+
+// ErrClosed is returned by the Serve methods after Close is called.
+var ErrClosed = errors.New("relay is closed")
+
+// Relay receives the syslog messages and forwards them.
+//
+// The received messages are queued into the bounded queue, and a worker forwards them into the logger.
+// The worker buffers the messages in the order of arrival. When the logger falls behind (i.e. the bulk post blocks the worker),
+// the worker stops taking the messages and the queue fills up; then the readers of the stream connections (TCP and Unix stream) stop reading,
+// so the senders are pushed back. The datagrams (UDP and Unix datagram) cannot be pushed back, so they are dropped and counted.
+type Relay struct {
+	logger     *logger.AsyncBulkLogger
+	queue      chan logger.Message
+	onError    func(err error)
+	dropped    uint64
+	mu         sync.Mutex
+	closed     bool
+	closers    map[io.Closer]struct{}
+	connWG     sync.WaitGroup
+	workerDone chan struct{}
+}
+
+// NewRelay creates a new Relay that forwards the messages into the logger.
+// If `queueSize` is less or equal to 0, the default size (10000) is used.
+func NewRelay(l *logger.AsyncBulkLogger, queueSize int) *Relay {
+	if queueSize <= 0 {
+		queueSize = defaultQueueSize
+	}
+	r := &Relay{
+		logger:     l,
+		queue:      make(chan logger.Message, queueSize),
+		onError:    func(err error) {},
+		closers:    make(map[io.Closer]struct{}),
+		workerDone: make(chan struct{}),
+	}
+	go r.work()
+	return r
+}
+
+// SetErrorHandler sets the handler that is called with the errors of the connections and the delivery.
+// It is called from the multiple goroutines.
+func (r *Relay) SetErrorHandler(handler func(err error)) {
+	r.onError = handler
+}
+
+// Dropped returns the number of the datagrams that have been dropped because the queue was full.
+func (r *Relay) Dropped() uint64 {
+	return atomic.LoadUint64(&r.dropped)
+}
+
+// ListenAndServe listens on the address and serves that until Close is called.
+// The network is one of "udp", "tcp", "unix" (stream) and "unixgram" (datagram, e.g. /dev/log).
+func (r *Relay) ListenAndServe(network, address string) error {
+	switch network {
+	case "udp", "udp4", "udp6", "unixgram":
+		if network == "unixgram" {
+			if err := removeStaleSocket(network, address); err != nil {
+				return err
+			}
+		}
+		conn, err := net.ListenPacket(network, address)
+		if err != nil {
+			return err
+		}
+		if network == "unixgram" {
+			// unlike the listener of "unix", the datagram socket doesn't remove its file on closing
+			conn = &unlinkingPacketConn{PacketConn: conn, path: address}
+		}
+		return r.ServePacket(conn)
+	case "tcp", "tcp4", "tcp6", "unix":
+		if network == "unix" {
+			if err := removeStaleSocket(network, address); err != nil {
+				return err
+			}
+		}
+		l, err := net.Listen(network, address)
+		if err != nil {
+			return err
+		}
+		return r.Serve(l)
+	default:
+		return fmt.Errorf("unsupported network [given: %s]", network)
+	}
+}
+
+// removeStaleSocket removes the socket file that the previous process has left, e.g. when that was killed.
+// The file that is not a socket, or that is still served by someone, is kept; listening on that fails then.
+func removeStaleSocket(network, path string) error {
+	info, err := os.Lstat(path)
+	if err != nil || info.Mode()&os.ModeSocket == 0 {
+		return nil
+	}
+	if conn, err := net.Dial(network, path); err == nil {
+		conn.Close()
+		return nil
+	}
+	return os.Remove(path)
+}
+
+// unlinkingPacketConn removes the socket file on closing.
+type unlinkingPacketConn struct {
+	net.PacketConn
+	path string
+}
+
+func (c *unlinkingPacketConn) Close() error {
+	err := c.PacketConn.Close()
+	os.Remove(c.path)
+	return err
+}
+
+// ServePacket serves the datagrams; each datagram is a message.
+func (r *Relay) ServePacket(conn net.PacketConn) error {
+	if !r.track(conn) {
+		conn.Close()
+		return ErrClosed
+	}
+	defer r.untrack(conn)
+
+	buf := make([]byte, maxMessageSize)
+	for {
+		n, addr, err := conn.ReadFrom(buf)
+		if err != nil {
+			if r.isClosed() {
+				return ErrClosed
+			}
+			return err
+		}
+		message := r.parse(buf[:n], addr)
+		select {
+		case r.queue <- message:
+		default:
+			atomic.AddUint64(&r.dropped, 1)
+		}
+	}
+}
+
+// Serve accepts the stream connections; the messages are framed by the octet counting (RFC6587),
+// or delimited by the newlines (or NUL) if the frame doesn't start with the digit.
+func (r *Relay) Serve(l net.Listener) error {
+	if !r.track(l) {
+		l.Close()
+		return ErrClosed
+	}
+	defer r.untrack(l)
+
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			if r.isClosed() {
+				return ErrClosed
+			}
+			return err
+		}
+		if !r.track(conn) {
+			conn.Close()
+			return ErrClosed
+		}
+		go func() {
+			defer r.untrack(conn)
+			defer conn.Close()
+			if err := r.serveConn(conn); err != nil && !r.isClosed() {
+				r.onError(fmt.Errorf("%s: %s", conn.RemoteAddr(), err))
+			}
+		}()
+	}
+}
+
+func (r *Relay) serveConn(conn net.Conn) error {
+	reader := bufio.NewReaderSize(conn, maxMessageSize)
+	for {
+		frame, err := readFrame(reader)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if len(frame) == 0 {
+			continue
+		}
+		// blocks while the queue is full; that pushes back the sender
+		r.queue <- r.parse(frame, conn.RemoteAddr())
+	}
+}
+
+// readFrame reads a frame by the octet counting or the non-transparent framing.
+func readFrame(reader *bufio.Reader) ([]byte, error) {
+	first, err := reader.Peek(1)
+	if err != nil {
+		return nil, err
+	}
+
+	if first[0] >= '1' && first[0] <= '9' {
+		length, err := reader.ReadSlice(' ')
+		if err != nil {
+			return nil, err
+		}
+		if len(length) > maxOctetCountDigits+1 {
+			return nil, fmt.Errorf("octet count is too long [given: %s]", length)
+		}
+		n, err := strconv.Atoi(string(length[:len(length)-1]))
+		if err != nil {
+			return nil, fmt.Errorf("octet count is malformed [given: %s]", length)
+		}
+		if n > maxMessageSize {
+			// don't allocate the size that the peer claims; the stream cannot be resynchronized after this
+			return nil, fmt.Errorf("octet count exceeds the maximum message size %d [given: %d]", maxMessageSize, n)
+		}
+		frame := make([]byte, n)
+		if _, err := io.ReadFull(reader, frame); err != nil {
+			return nil, err
+		}
+		return frame, nil
+	}
+
+	var frame []byte
+	for {
+		b, err := reader.ReadByte()
+		if err == io.EOF && len(frame) > 0 {
+			return frame, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if b == '\n' || b == 0 {
+			return bytes.TrimRight(frame, "\r"), nil
+		}
+		if len(frame) < maxMessageSize {
+			frame = append(frame, b)
+		}
+	}
+}
+
+// parse parses the message; the message that cannot be parsed is forwarded as it is with the error.
+func (r *Relay) parse(b []byte, addr net.Addr) logger.Message {
+	message, err := Parse(b)
+	if err != nil {
+		if message == nil {
+			message = logger.Message{}
+		}
+		message[FieldMessage] = toString(bytes.TrimRight(b, "\r\n\x00"))
+		message[FieldParseError] = err.Error()
+	}
+	if addr != nil {
+		// the unnamed Unix socket has no address
+		if s := addr.String(); s != "" && s != "<nil>" {
+			message[FieldRemoteAddr] = s
+		}
+	}
+	return message
+}
+
+// work forwards the queued messages into the logger. The messages that are queued at once are logged as a batch.
+func (r *Relay) work() {
+	defer close(r.workerDone)
+	batch := make([]logger.Message, 0, maxBatchSize)
+	for message := range r.queue {
+		batch = append(batch[:0], message)
+	drain:
+		for len(batch) < maxBatchSize {
+			select {
+			case message, ok := <-r.queue:
+				if !ok {
+					break drain
+				}
+				batch = append(batch, message)
+			default:
+				break drain
+			}
+		}
+
+		result, err := r.logger.LogBatch(batch)
+		if err != nil {
+			r.onError(fmt.Errorf("failed to log: %s", err))
+			continue
+		}
+		r.handleResult(result)
+	}
+}
+
+func (r *Relay) handleResult(result *logger.AsyncBulkResult) {
+	err := <-result.AsyncErrChan
+	failed := <-result.FailedMessagesChan
+	if err != nil {
+		r.onError(fmt.Errorf("failed to send %d messages: %s", len(failed), err))
+	}
+}
+
+// Flush flushes the messages that are buffered in the logger. The errors are notified to the error handler.
+func (r *Relay) Flush() {
+	r.handleResult(r.logger.Flush())
+}
+
+// track registers the closer to close that on Close. This returns false if the relay is already closed.
+func (r *Relay) track(c io.Closer) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.closed {
+		return false
+	}
+	r.closers[c] = struct{}{}
+	r.connWG.Add(1)
+	return true
+}
+
+func (r *Relay) untrack(c io.Closer) {
+	r.mu.Lock()
+	delete(r.closers, c)
+	r.mu.Unlock()
+	r.connWG.Done()
+}
+
+func (r *Relay) isClosed() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.closed
+}
+
+// Close stops listening, forwards the queued messages into the logger, and shuts down the logger.
+// The messages that the logger failed to send are notified to the error handler.
+func (r *Relay) Close() error {
+	r.mu.Lock()
+	if r.closed {
+		r.mu.Unlock()
+		return nil
+	}
+	r.closed = true
+	for c := range r.closers {
+		c.Close()
+	}
+	r.mu.Unlock()
+
+	r.connWG.Wait()
+
+	close(r.queue)
+	<-r.workerDone
+
+	r.handleResult(r.logger.Shutdown())
+	return nil
+}
@@ -0,0 +1,215 @@
+package relay
+
+import (
+	"bufio"
+	"encoding/json"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/moznion/logglily/internal/api"
+	"github.com/moznion/logglily/logger"
+)
+
+func messages(client *api.RecordingClient) []map[string]interface{} {
+	var messages []map[string]interface{}
+	for _, line := range client.Lines() {
+		var m map[string]interface{}
+		json.Unmarshal([]byte(line), &m)
+		messages = append(messages, m)
+	}
+	return messages
+}
+
+func texts(client *api.RecordingClient) []string {
+	var texts []string
+	for _, m := range messages(client) {
+		text, _ := m["message"].(string)
+		texts = append(texts, text)
+	}
+	return texts
+}
+
+func newTestRelay(t *testing.T, queueSize int) (*Relay, *api.RecordingClient) {
+	l, err := logger.NewAsyncBulkLogger(nil, "token", true, 5*1024*1024, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := &api.RecordingClient{}
+	l.APIClient = client
+	return NewRelay(l, queueSize), client
+}
+
+func waitFor(t *testing.T, condition func() bool) {
+	deadline := time.Now().Add(5 * time.Second)
+	for !condition() {
+		if time.Now().After(deadline) {
+			t.Fatal("timed out")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestRelayShouldForwardDatagrams(t *testing.T) {
+	r, client := newTestRelay(t, 0)
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	served := make(chan error, 1)
+	go func() { served <- r.ServePacket(conn) }()
+
+	sender, err := net.Dial("udp", conn.LocalAddr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	sender.Write([]byte("<14>Jan  5 09:59:00 host app: first"))
+	sender.Write([]byte("not syslog"))
+	sender.Close()
+
+	waitFor(t, func() bool { return len(texts(client)) == 2 })
+	r.Close()
+	if err := <-served; err != ErrClosed {
+		t.Errorf("ServePacket returns %v but wants ErrClosed", err)
+	}
+
+	if texts := texts(client); strings.Join(texts, "|") != "first|not syslog" {
+		t.Fatalf("messages == %q", texts)
+	}
+	for _, m := range messages(client) {
+		if m["remote_addr"] == nil {
+			t.Errorf("remote_addr is missing: %v", m)
+		}
+		if m["message"] == "not syslog" && m["parse_error"] == nil {
+			t.Errorf("parse_error is missing: %v", m)
+		}
+		if m["message"] == "first" && (m["hostname"] != "host" || m["app"] != "app") {
+			t.Errorf("message is not parsed: %v", m)
+		}
+	}
+}
+
+func TestRelayShouldForwardStreamFrames(t *testing.T) {
+	r, client := newTestRelay(t, 0)
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	served := make(chan error, 1)
+	go func() { served <- r.Serve(l) }()
+
+	conn, err := net.Dial("tcp", l.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	framed := "<14>1 - - - - - - octet\ncounted"
+	conn.Write([]byte(strconv.Itoa(len(framed)) + " " + framed[:len(framed)-7]))
+	conn.Write([]byte(framed[len(framed)-7:] + "<14>1 - - - - - - newline\n<14>1 - - - - - - nul\x00<14>1 - - - - - - eof"))
+	conn.Close()
+
+	waitFor(t, func() bool { return len(texts(client)) == 4 })
+	r.Close()
+	if err := <-served; err != ErrClosed {
+		t.Errorf("Serve returns %v but wants ErrClosed", err)
+	}
+
+	expected := []string{"octet\ncounted", "newline", "nul", "eof"}
+	if texts := texts(client); strings.Join(texts, "|") != strings.Join(expected, "|") {
+		t.Errorf("messages == %q but wants %q", texts, expected)
+	}
+}
+
+func TestRelayShouldDropDatagramsWhenQueueIsFull(t *testing.T) {
+	r, client := newTestRelay(t, 1)
+	block := make(chan struct{})
+	entered := make(chan struct{}, 1)
+	client.Respond = func(body []byte) (int, error) {
+		select {
+		case entered <- struct{}{}:
+		default:
+		}
+		<-block
+		return http.StatusOK, nil
+	}
+
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go r.ServePacket(conn)
+
+	sender, err := net.Dial("udp", conn.LocalAddr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sender.Close()
+
+	// blocks the worker by the bulk post of the periodic flushing
+	sender.Write([]byte("<14>message"))
+	<-entered
+	for i := 0; i < 5; i++ {
+		sender.Write([]byte("<14>message"))
+	}
+	waitFor(t, func() bool { return r.Dropped() >= 3 })
+
+	close(block)
+	r.Close()
+}
+
+func TestListenAndServeShouldRejectUnsupportedNetwork(t *testing.T) {
+	r, _ := newTestRelay(t, 0)
+	defer r.Close()
+	if err := r.ListenAndServe("ip", "127.0.0.1"); err == nil {
+		t.Error("unsupported network should be an error")
+	}
+}
+
+func TestReadFrameShouldRejectOversizedOctetCount(t *testing.T) {
+	reader := bufio.NewReader(strings.NewReader("99999999 <14>message"))
+	if _, err := readFrame(reader); err == nil {
+		t.Error("octet count over the maximum message size should be an error")
+	}
+}
+
+func TestListenAndServeShouldReplaceStaleUnixgramSocket(t *testing.T) {
+	dir, err := ioutil.TempDir("", "relay")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "log.sock")
+
+	// the socket file that the killed process has left
+	stale, err := net.ListenPacket("unixgram", path)
+	if err != nil {
+		t.Skip("unixgram is not supported:", err)
+	}
+	stale.Close()
+
+	r, client := newTestRelay(t, 0)
+	served := make(chan error, 1)
+	go func() { served <- r.ListenAndServe("unixgram", path) }()
+
+	waitFor(t, func() bool {
+		sender, err := net.Dial("unixgram", path)
+		if err != nil {
+			return false
+		}
+		defer sender.Close()
+		sender.Write([]byte("<14>1 - - - - - - hello"))
+		return len(texts(client)) > 0
+	})
+	r.Close()
+	if err := <-served; err != ErrClosed {
+		t.Errorf("ListenAndServe returns %v but wants ErrClosed", err)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("socket file should be removed on closing: %v", err)
+	}
+}
@@ -0,0 +1,296 @@
+// Package retrieval provides the client of the retrieval API of loggly to search the events, e.g.
+//
+//	c := retrieval.NewClient("your-subdomain", apiToken)
+//	it := c.Iterate(retrieval.Query{Query: "tag:web AND json.level:error", From: "-1h"})
+//	for it.Next() {
+//		event := it.Event()
+//		fmt.Println(event.Time(), event.LogMsg)
+//	}
+//	if err := it.Err(); err != nil {
+//		// handle the error
+//	}
+//
+// NOTE: The retrieval API needs the API token that is different from the customer token to send the events.
+// Please see retrievaltest package for the fake server for the tests.
+package retrieval
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/moznion/logglily/internal"
+)
+
+// Order is the order of the events by the timestamp.
+type Order string
+
+const (
+	// OrderDesc is the order from the newest event; this is the default of loggly.
+	OrderDesc Order = "desc"
+	// OrderAsc is the order from the oldest event.
+	OrderAsc Order = "asc"
+)
+
+// Query is the parameters of the search.
+type Query struct {
+	// Query is the search query of loggly, e.g. "tag:web AND json.status:500". Empty means "*".
+	Query string
+	// From and Until are the time range; they accept the relative time (e.g. "-1h", "-30m", "now")
+	// and the absolute time. Please use FormatTime for time.Time. The defaults of loggly are "-24h" and "now".
+	From  string
+	Until string
+	// Order is the order of the events. The default of loggly is OrderDesc.
+	Order Order
+	// Size is the number of the events per page. The default of loggly is 50.
+	Size int
+}
+
+// FormatTime formats the time for From and Until of Query.
+func FormatTime(t time.Time) string {
+	return t.UTC().Format("2006-01-02T15:04:05.000Z")
+}
+
+func (q Query) values() url.Values {
+	v := url.Values{}
+	query := q.Query
+	if query == "" {
+		query = "*"
+	}
+	v.Set("q", query)
+	if q.From != "" {
+		v.Set("from", q.From)
+	}
+	if q.Until != "" {
+		v.Set("until", q.Until)
+	}
+	if q.Order != "" {
+		v.Set("order", string(q.Order))
+	}
+	if q.Size > 0 {
+		v.Set("size", strconv.Itoa(q.Size))
+	}
+	return v
+}
+
+// Search is the search that is created by the search API; the events are retrieved by its ID.
+type Search struct {
+	ID          string  `json:"id"`
+	Status      string  `json:"status"`
+	DateFrom    int64   `json:"date_from"`
+	DateTo      int64   `json:"date_to"`
+	ElapsedTime float64 `json:"elapsed_time"`
+}
+
+// Event is an event that is retrieved.
+type Event struct {
+	ID string `json:"id"`
+	// Timestamp is the milliseconds since the epoch; please use Time method.
+	Timestamp int64    `json:"timestamp"`
+	Tags      []string `json:"tags"`
+	// LogMsg is the raw message as it was sent.
+	LogMsg   string   `json:"logmsg"`
+	LogTypes []string `json:"logtypes"`
+	// Event is the parsed fields per the log type, e.g. "json", "http" and "syslog".
+	Event map[string]json.RawMessage `json:"event"`
+}
+
+// Time returns the timestamp of the event.
+func (e Event) Time() time.Time {
+	return time.Unix(0, e.Timestamp*int64(time.Millisecond))
+}
+
+// JSON returns the fields of the JSON event. This returns nil if the event is not JSON.
+func (e Event) JSON() (map[string]interface{}, error) {
+	raw, ok := e.Event["json"]
+	if !ok {
+		return nil, nil
+	}
+	var fields map[string]interface{}
+	if err := json.Unmarshal(raw, &fields); err != nil {
+		return nil, err
+	}
+	return fields, nil
+}
+
+// EventsPage is a page of the events of the search.
+type EventsPage struct {
+	TotalEvents int     `json:"total_events"`
+	Page        int     `json:"page"`
+	Events      []Event `json:"events"`
+}
+
+// APIError is the error that the retrieval API responds.
+type APIError struct {
+	StatusCode int
+	Message    string
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("failed to call retrieval API [status=%d, msg=%s]", e.StatusCode, e.Message)
+}
+
+// Client is the client of the retrieval API.
+type Client struct {
+	baseURL string
+	token   string
+	client  *http.Client
+}
+
+// NewClient creates a new Client of the account; the subdomain is the one of https://{subdomain}.loggly.com.
+func NewClient(subdomain string, apiToken string) *Client {
+	return NewClientWithBaseURL("https://"+subdomain+".loggly.com", apiToken)
+}
+
+// NewClientWithBaseURL creates a new Client that calls the base URL instead of loggly, e.g. the fake server.
+func NewClientWithBaseURL(baseURL string, apiToken string) *Client {
+	return &Client{
+		baseURL: strings.TrimRight(baseURL, "/"),
+		token:   apiToken,
+		client:  http.DefaultClient,
+	}
+}
+
+// SetHTTPClient changes the HTTP client. The default is http.DefaultClient.
+func (c *Client) SetHTTPClient(client *http.Client) {
+	c.client = client
+}
+
+// Search creates a new search.
+func (c *Client) Search(q Query) (*Search, error) {
+	var body struct {
+		RSID Search `json:"rsid"`
+	}
+	if err := c.get("/apiv2/search", q.values(), &body); err != nil {
+		return nil, err
+	}
+	return &body.RSID, nil
+}
+
+// Events retrieves the page of the events of the search; the page starts from 0.
+func (c *Client) Events(searchID string, page int) (*EventsPage, error) {
+	v := url.Values{}
+	v.Set("rsid", searchID)
+	v.Set("page", strconv.Itoa(page))
+
+	var body EventsPage
+	if err := c.get("/apiv2/events", v, &body); err != nil {
+		return nil, err
+	}
+	return &body, nil
+}
+
+func (c *Client) get(path string, v url.Values, body interface{}) error {
+	req, err := http.NewRequest("GET", c.baseURL+path+"?"+v.Encode(), nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "bearer "+c.token)
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("User-Agent", fmt.Sprintf("logglily/%s; https://github.com/moznion/logglily", internal.Version))
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	b, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return &APIError{StatusCode: resp.StatusCode, Message: string(b)}
+	}
+	if err := json.Unmarshal(b, body); err != nil {
+		return fmt.Errorf("failed to decode the response of %s: %s", path, err)
+	}
+	return nil
+}
+
+// Iterator iterates the events of the search over the pages.
+type Iterator struct {
+	client *Client
+	query  Query
+
+	search  *Search
+	page    int
+	events  []Event
+	index   int
+	fetched int
+	total   int
+	done    bool
+	err     error
+}
+
+// Iterate returns the Iterator of the events of the query. The search is created at the first call of Next.
+func (c *Client) Iterate(q Query) *Iterator {
+	return &Iterator{client: c, query: q, index: -1}
+}
+
+// Next advances to the next event; this returns false at the end or on the error. Please check Err after that.
+func (it *Iterator) Next() bool {
+	if it.err != nil {
+		return false
+	}
+	if it.index+1 < len(it.events) {
+		it.index++
+		return true
+	}
+	if it.done {
+		return false
+	}
+
+	if it.search == nil {
+		search, err := it.client.Search(it.query)
+		if err != nil {
+			it.err = err
+			return false
+		}
+		it.search = search
+	}
+
+	page, err := it.client.Events(it.search.ID, it.page)
+	if err != nil {
+		it.err = err
+		return false
+	}
+	it.page++
+	it.total = page.TotalEvents
+	it.fetched += len(page.Events)
+	it.events = page.Events
+	it.index = -1
+	if len(page.Events) == 0 || it.fetched >= it.total {
+		it.done = true
+	}
+	if len(it.events) == 0 {
+		return false
+	}
+	it.index++
+	return true
+}
+
+// Event returns the current event.
+func (it *Iterator) Event() Event {
+	return it.events[it.index]
+}
+
+// Err returns the error that stops the iteration.
+func (it *Iterator) Err() error {
+	return it.err
+}
+
+// Search returns the search; that is nil until the first call of Next.
+func (it *Iterator) Search() *Search {
+	return it.search
+}
+
+// Total returns the number of the events that match the query; that is known after the first call of Next.
+func (it *Iterator) Total() int {
+	return it.total
+}
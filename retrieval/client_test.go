@@ -0,0 +1,122 @@
+package retrieval
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestSearchShouldSendQueryParameters(t *testing.T) {
+	var got url.Values
+	var auth string
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/apiv2/search" {
+			t.Errorf("unexpected path: %s", r.URL.Path)
+		}
+		got, auth = r.URL.Query(), r.Header.Get("Authorization")
+		fmt.Fprint(w, `{"rsid":{"status":"SCHEDULED","date_from":1505800000000,"elapsed_time":0.001,"date_to":1505886400000,"id":"728480292"}}`)
+	}))
+	defer s.Close()
+
+	c := NewClientWithBaseURL(s.URL+"/", "api-token")
+	search, err := c.Search(Query{
+		Query: "tag:web AND json.status:500",
+		From:  FormatTime(time.Date(2017, 9, 19, 5, 46, 40, 0, time.UTC)),
+		Until: "now",
+		Order: OrderAsc,
+		Size:  100,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := Search{ID: "728480292", Status: "SCHEDULED", DateFrom: 1505800000000, DateTo: 1505886400000, ElapsedTime: 0.001}
+	if *search != expected {
+		t.Errorf("got %+v, expected %+v", *search, expected)
+	}
+	if auth != "bearer api-token" {
+		t.Errorf("unexpected authorization: %s", auth)
+	}
+	expectedValues := url.Values{
+		"q":     {"tag:web AND json.status:500"},
+		"from":  {"2017-09-19T05:46:40.000Z"},
+		"until": {"now"},
+		"order": {"asc"},
+		"size":  {"100"},
+	}
+	if !reflect.DeepEqual(got, expectedValues) {
+		t.Errorf("got %v, expected %v", got, expectedValues)
+	}
+}
+
+func TestIteratorShouldFollowPages(t *testing.T) {
+	var pages []string
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/apiv2/search":
+			if q := r.URL.Query().Get("q"); q != "*" {
+				t.Errorf("empty query should be *, but got %q", q)
+			}
+			fmt.Fprint(w, `{"rsid":{"id":"1"}}`)
+		case "/apiv2/events":
+			page := r.URL.Query().Get("page")
+			pages = append(pages, page)
+			switch page {
+			case "0":
+				fmt.Fprint(w, `{"total_events":3,"page":0,"events":[
+					{"id":"a","timestamp":1505800000000,"tags":["web"],"logmsg":"{\"n\":1}","logtypes":["json"],"event":{"json":{"n":1}}},
+					{"id":"b","timestamp":1505800001000,"logmsg":"plain","event":{}}]}`)
+			case "1":
+				fmt.Fprint(w, `{"total_events":3,"page":1,"events":[{"id":"c","timestamp":1505800002000,"logmsg":"last"}]}`)
+			default:
+				t.Errorf("unexpected page: %s", page)
+			}
+		}
+	}))
+	defer s.Close()
+
+	it := NewClientWithBaseURL(s.URL, "api-token").Iterate(Query{Size: 2})
+	var ids []string
+	for it.Next() {
+		ids = append(ids, it.Event().ID)
+	}
+	if err := it.Err(); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(ids, []string{"a", "b", "c"}) || !reflect.DeepEqual(pages, []string{"0", "1"}) {
+		t.Errorf("unexpected iteration: ids=%q, pages=%q", ids, pages)
+	}
+	if it.Total() != 3 || it.Search().ID != "1" {
+		t.Errorf("unexpected total or search: %d, %+v", it.Total(), it.Search())
+	}
+}
+
+func TestIteratorShouldStopAtError(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, `{"message":"unauthorized"}`, http.StatusUnauthorized)
+	}))
+	defer s.Close()
+
+	it := NewClientWithBaseURL(s.URL, "wrong").Iterate(Query{})
+	if it.Next() {
+		t.Fatal("Next should be false")
+	}
+	apiErr, ok := it.Err().(*APIError)
+	if !ok || apiErr.StatusCode != http.StatusUnauthorized {
+		t.Errorf("unexpected error: %v", it.Err())
+	}
+}
+
+func TestEventShouldDecodeFields(t *testing.T) {
+	e := Event{Timestamp: 1505800000123, Event: nil}
+	if !e.Time().Equal(time.Date(2017, 9, 19, 5, 46, 40, 123000000, time.UTC)) {
+		t.Errorf("unexpected time: %s", e.Time())
+	}
+	if fields, err := e.JSON(); fields != nil || err != nil {
+		t.Errorf("JSON of non-JSON event should be nil: %v, %v", fields, err)
+	}
+}
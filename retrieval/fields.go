@@ -0,0 +1,107 @@
+package retrieval
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strconv"
+)
+
+// FieldsQuery is the parameters of the field discovery.
+type FieldsQuery struct {
+	// Query, From and Until are the same as the ones of Query; they narrow down the events to count the fields.
+	Query string
+	From  string
+	Until string
+	// FacetSize is the maximum number of the fields or the values. The default of loggly is used if this is 0.
+	FacetSize int
+}
+
+func (q FieldsQuery) values() url.Values {
+	v := Query{Query: q.Query, From: q.From, Until: q.Until}.values()
+	if q.FacetSize > 0 {
+		v.Set("facet_size", strconv.Itoa(q.FacetSize))
+	}
+	return v
+}
+
+// Term is the field name or the value with the number of the events that have that.
+type Term struct {
+	Term  string `json:"term"`
+	Count int    `json:"count"`
+}
+
+// Fields is the fields that loggly has indexed for the events.
+type Fields struct {
+	TotalEvents      int    `json:"total_events"`
+	UniqueFieldCount int    `json:"unique_field_count"`
+	Fields           []Term `json:"fields"`
+}
+
+// FieldValues is the top values of a field.
+type FieldValues struct {
+	Field            string
+	TotalEvents      int
+	UniqueFieldCount int
+	Values           []Term
+}
+
+// UnmarshalJSON decodes the response that has the values under the name of the field, e.g.
+//
+//	{"json.level":[{"term":"error","count":10}],"total_events":10,"unique_field_count":1}
+func (v *FieldValues) UnmarshalJSON(b []byte) error {
+	var body map[string]json.RawMessage
+	if err := json.Unmarshal(b, &body); err != nil {
+		return err
+	}
+	for key, raw := range body {
+		var err error
+		switch key {
+		case "total_events":
+			err = json.Unmarshal(raw, &v.TotalEvents)
+		case "unique_field_count":
+			err = json.Unmarshal(raw, &v.UniqueFieldCount)
+		default:
+			v.Field = key
+			err = json.Unmarshal(raw, &v.Values)
+		}
+		if err != nil {
+			return fmt.Errorf("invalid %s: %s", key, err)
+		}
+	}
+	return nil
+}
+
+// MarshalJSON encodes the values in the same form as the response.
+func (v FieldValues) MarshalJSON() ([]byte, error) {
+	values := v.Values
+	if values == nil {
+		values = []Term{}
+	}
+	return json.Marshal(map[string]interface{}{
+		v.Field:              values,
+		"total_events":       v.TotalEvents,
+		"unique_field_count": v.UniqueFieldCount,
+	})
+}
+
+// Fields lists the fields of the events that match the query, e.g. "json.level" and "syslog.appName".
+func (c *Client) Fields(q FieldsQuery) (*Fields, error) {
+	var body Fields
+	if err := c.get("/apiv2/fields", q.values(), &body); err != nil {
+		return nil, err
+	}
+	return &body, nil
+}
+
+// FieldValues lists the top values of the field of the events that match the query.
+func (c *Client) FieldValues(field string, q FieldsQuery) (*FieldValues, error) {
+	var body FieldValues
+	if err := c.get("/apiv2/fields/"+url.PathEscape(field)+"/", q.values(), &body); err != nil {
+		return nil, err
+	}
+	if body.Field == "" {
+		body.Field = field
+	}
+	return &body, nil
+}
@@ -0,0 +1,66 @@
+package retrieval
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"testing"
+)
+
+func TestFieldsShouldListFields(t *testing.T) {
+	var got url.Values
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/apiv2/fields" {
+			t.Errorf("unexpected path: %s", r.URL.Path)
+		}
+		got = r.URL.Query()
+		fmt.Fprint(w, `{"total_events":8,"unique_field_count":2,"fields":[{"term":"json.level","count":8},{"term":"tag","count":5}]}`)
+	}))
+	defer s.Close()
+
+	fields, err := NewClientWithBaseURL(s.URL, "api-token").Fields(FieldsQuery{Query: "tag:web", From: "-1h", FacetSize: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := &Fields{TotalEvents: 8, UniqueFieldCount: 2, Fields: []Term{{Term: "json.level", Count: 8}, {Term: "tag", Count: 5}}}
+	if !reflect.DeepEqual(fields, expected) {
+		t.Errorf("got %+v, expected %+v", fields, expected)
+	}
+	expectedValues := url.Values{"q": {"tag:web"}, "from": {"-1h"}, "facet_size": {"10"}}
+	if !reflect.DeepEqual(got, expectedValues) {
+		t.Errorf("got %v, expected %v", got, expectedValues)
+	}
+}
+
+func TestFieldValuesShouldDecodeValuesUnderFieldName(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/apiv2/fields/json.level/" {
+			t.Errorf("unexpected path: %s", r.URL.Path)
+		}
+		fmt.Fprint(w, `{"json.level":[{"term":"error","count":6},{"term":"info","count":2}],"total_events":8,"unique_field_count":2}`)
+	}))
+	defer s.Close()
+
+	values, err := NewClientWithBaseURL(s.URL, "api-token").FieldValues("json.level", FieldsQuery{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := &FieldValues{
+		Field:            "json.level",
+		TotalEvents:      8,
+		UniqueFieldCount: 2,
+		Values:           []Term{{Term: "error", Count: 6}, {Term: "info", Count: 2}},
+	}
+	if !reflect.DeepEqual(values, expected) {
+		t.Errorf("got %+v, expected %+v", values, expected)
+	}
+
+	b, _ := json.Marshal(values)
+	var decoded FieldValues
+	if err := json.Unmarshal(b, &decoded); err != nil || !reflect.DeepEqual(&decoded, expected) {
+		t.Errorf("round trip failed: %s, %v", b, err)
+	}
+}
@@ -0,0 +1,403 @@
+// Package retrievaltest provides the fake server of the retrieval API for the tests, e.g.
+//
+//	s := retrievaltest.NewServer("api-token")
+//	defer s.Close()
+//	s.AddEvents(retrievaltest.NewEvent(time.Now(), `{"level":"error"}`, "web"))
+//	c := s.Client()
+//
+// The fake supports a subset of the search query: "*", the words (that match the raw message),
+// "tag:NAME", "json.KEY:VALUE", and "AND" between them. "NOT" and "OR" are not supported.
+// The fields of the events are "tag", "logtype" and "json.KEY" of the scalar values of the JSON events.
+package retrievaltest
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/moznion/logglily/retrieval"
+)
+
+const (
+	defaultSize = 50
+	maxSize     = 5000
+)
+
+// Server is the fake server of the retrieval API.
+type Server struct {
+	*httptest.Server
+	token string
+	now   func() time.Time
+
+	mu       sync.Mutex
+	events   []retrieval.Event
+	searches map[string]searchResult
+	queries  []retrieval.Query
+	nextID   int
+}
+
+// searchResult is the events of a search, and the page size.
+type searchResult struct {
+	events []retrieval.Event
+	size   int
+}
+
+// NewServer starts a new fake server that accepts the API token.
+func NewServer(apiToken string) *Server {
+	s := &Server{
+		token:    apiToken,
+		now:      time.Now,
+		searches: make(map[string]searchResult),
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/apiv2/search", s.handleSearch)
+	mux.HandleFunc("/apiv2/events", s.handleEvents)
+	mux.HandleFunc("/apiv2/fields", s.handleFields)
+	mux.HandleFunc("/apiv2/fields/", s.handleFields)
+	s.Server = httptest.NewServer(s.authorize(mux))
+	return s
+}
+
+// NewEvent creates the event of the message. If the message is a JSON object, that is parsed as the "json" event.
+func NewEvent(timestamp time.Time, message string, tags ...string) retrieval.Event {
+	e := retrieval.Event{
+		Timestamp: timestamp.UnixNano() / int64(time.Millisecond),
+		Tags:      tags,
+		LogMsg:    message,
+		Event:     map[string]json.RawMessage{},
+	}
+	var fields map[string]interface{}
+	if err := json.Unmarshal([]byte(message), &fields); err == nil {
+		e.LogTypes = []string{"json"}
+		e.Event["json"] = json.RawMessage(message)
+	}
+	return e
+}
+
+// Client returns the client that calls the fake server.
+func (s *Server) Client() *retrieval.Client {
+	return retrieval.NewClientWithBaseURL(s.URL, s.token)
+}
+
+// SetClock changes the clock that resolves the relative time of the queries.
+func (s *Server) SetClock(now func() time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.now = now
+}
+
+// AddEvents adds the events to be searched. The events that have no ID are given the IDs.
+func (s *Server) AddEvents(events ...retrieval.Event) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, e := range events {
+		if e.ID == "" {
+			s.nextID++
+			e.ID = fmt.Sprintf("event-%d", s.nextID)
+		}
+		s.events = append(s.events, e)
+	}
+}
+
+// Queries returns the queries of the searches that have been created.
+func (s *Server) Queries() []retrieval.Query {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]retrieval.Query(nil), s.queries...)
+}
+
+func (s *Server) authorize(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "bearer "+s.token {
+			http.Error(w, `{"message":"unauthorized"}`, http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (s *Server) handleSearch(w http.ResponseWriter, r *http.Request) {
+	v := r.URL.Query()
+	q := retrieval.Query{
+		Query: v.Get("q"),
+		From:  v.Get("from"),
+		Until: v.Get("until"),
+		Order: retrieval.Order(v.Get("order")),
+	}
+	size := defaultSize
+	if v.Get("size") != "" {
+		n, err := strconv.Atoi(v.Get("size"))
+		if err != nil || n <= 0 || n > maxSize {
+			badRequest(w, "invalid size: "+v.Get("size"))
+			return
+		}
+		size, q.Size = n, n
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	events, from, until, err := s.filter(q.Query, q.From, q.Until)
+	if err != nil {
+		badRequest(w, err.Error())
+		return
+	}
+	sort.SliceStable(events, func(i, j int) bool {
+		if q.Order == retrieval.OrderAsc {
+			return events[i].Timestamp < events[j].Timestamp
+		}
+		return events[i].Timestamp > events[j].Timestamp
+	})
+
+	s.nextID++
+	id := strconv.Itoa(s.nextID)
+	s.searches[id] = searchResult{events: events, size: size}
+	s.queries = append(s.queries, q)
+
+	writeJSON(w, map[string]retrieval.Search{"rsid": {
+		ID:       id,
+		Status:   "SCHEDULED",
+		DateFrom: from.UnixNano() / int64(time.Millisecond),
+		DateTo:   until.UnixNano() / int64(time.Millisecond),
+	}})
+}
+
+func (s *Server) handleEvents(w http.ResponseWriter, r *http.Request) {
+	v := r.URL.Query()
+	page, err := strconv.Atoi(v.Get("page"))
+	if v.Get("page") == "" {
+		page, err = 0, nil
+	}
+	if err != nil || page < 0 {
+		badRequest(w, "invalid page: "+v.Get("page"))
+		return
+	}
+
+	s.mu.Lock()
+	result, ok := s.searches[v.Get("rsid")]
+	s.mu.Unlock()
+	if !ok {
+		http.Error(w, `{"message":"search not found"}`, http.StatusNotFound)
+		return
+	}
+
+	events := []retrieval.Event{}
+	if start := page * result.size; start < len(result.events) {
+		end := start + result.size
+		if end > len(result.events) {
+			end = len(result.events)
+		}
+		events = result.events[start:end]
+	}
+	writeJSON(w, retrieval.EventsPage{TotalEvents: len(result.events), Page: page, Events: events})
+}
+
+func (s *Server) handleFields(w http.ResponseWriter, r *http.Request) {
+	v := r.URL.Query()
+	facetSize := defaultSize
+	if v.Get("facet_size") != "" {
+		n, err := strconv.Atoi(v.Get("facet_size"))
+		if err != nil || n <= 0 || n > maxSize {
+			badRequest(w, "invalid facet_size: "+v.Get("facet_size"))
+			return
+		}
+		facetSize = n
+	}
+	field := strings.Trim(strings.TrimPrefix(r.URL.Path, "/apiv2/fields"), "/")
+
+	s.mu.Lock()
+	events, _, _, err := s.filter(v.Get("q"), v.Get("from"), v.Get("until"))
+	s.mu.Unlock()
+	if err != nil {
+		badRequest(w, err.Error())
+		return
+	}
+
+	counts := map[string]int{}
+	for _, e := range events {
+		for name, values := range fieldsOf(e) {
+			if field == "" {
+				counts[name]++
+				continue
+			}
+			if name == field {
+				for _, value := range values {
+					counts[value]++
+				}
+			}
+		}
+	}
+	terms := make([]retrieval.Term, 0, len(counts))
+	for term, count := range counts {
+		terms = append(terms, retrieval.Term{Term: term, Count: count})
+	}
+	sort.Slice(terms, func(i, j int) bool {
+		if terms[i].Count != terms[j].Count {
+			return terms[i].Count > terms[j].Count
+		}
+		return terms[i].Term < terms[j].Term
+	})
+	unique := len(terms)
+	if len(terms) > facetSize {
+		terms = terms[:facetSize]
+	}
+
+	if field == "" {
+		writeJSON(w, retrieval.Fields{TotalEvents: len(events), UniqueFieldCount: unique, Fields: terms})
+		return
+	}
+	writeJSON(w, retrieval.FieldValues{Field: field, TotalEvents: len(events), UniqueFieldCount: unique, Values: terms})
+}
+
+// fieldsOf returns the values of the fields of the event.
+func fieldsOf(e retrieval.Event) map[string][]string {
+	fields := map[string][]string{}
+	fields["tag"] = append(fields["tag"], e.Tags...)
+	fields["logtype"] = append(fields["logtype"], e.LogTypes...)
+
+	var flatten func(prefix string, v interface{})
+	flatten = func(prefix string, v interface{}) {
+		switch v := v.(type) {
+		case map[string]interface{}:
+			for key, value := range v {
+				flatten(prefix+"."+key, value)
+			}
+		case []interface{}:
+			for _, value := range v {
+				flatten(prefix, value)
+			}
+		case nil:
+		default:
+			fields[prefix] = append(fields[prefix], fmt.Sprint(v))
+		}
+	}
+	if j, err := e.JSON(); err == nil && j != nil {
+		flatten("json", j)
+	}
+
+	for name, values := range fields {
+		if len(values) == 0 {
+			delete(fields, name)
+		}
+	}
+	return fields
+}
+
+// filter returns the events that match the query in the time range; please hold the lock.
+func (s *Server) filter(query string, fromValue string, untilValue string) ([]retrieval.Event, time.Time, time.Time, error) {
+	now := s.now()
+	from, err := parseTime(fromValue, "-24h", now)
+	if err != nil {
+		return nil, from, now, err
+	}
+	until, err := parseTime(untilValue, "now", now)
+	if err != nil {
+		return nil, from, until, err
+	}
+	match, err := compile(query)
+	if err != nil {
+		return nil, from, until, err
+	}
+
+	var events []retrieval.Event
+	for _, e := range s.events {
+		if t := e.Time(); !t.Before(from) && !t.After(until) && match(e) {
+			events = append(events, e)
+		}
+	}
+	return events, from, until, nil
+}
+
+// parseTime parses "now", the relative time like "-1h" (s, m, h, d and w), and RFC3339.
+func parseTime(s string, defaultValue string, now time.Time) (time.Time, error) {
+	if s == "" {
+		s = defaultValue
+	}
+	if s == "now" {
+		return now, nil
+	}
+	if strings.HasPrefix(s, "-") && len(s) > 2 {
+		n, err := strconv.Atoi(s[1 : len(s)-1])
+		if err == nil {
+			units := map[byte]time.Duration{'s': time.Second, 'm': time.Minute, 'h': time.Hour, 'd': 24 * time.Hour, 'w': 7 * 24 * time.Hour}
+			if unit, ok := units[s[len(s)-1]]; ok {
+				return now.Add(-time.Duration(n) * unit), nil
+			}
+		}
+	}
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid time: %s", s)
+	}
+	return t, nil
+}
+
+// compile compiles the subset of the search query into the matcher.
+func compile(query string) (func(e retrieval.Event) bool, error) {
+	var matchers []func(e retrieval.Event) bool
+	for _, term := range strings.Fields(query) {
+		switch {
+		case term == "*" || term == "AND":
+		case term == "OR" || term == "NOT":
+			return nil, fmt.Errorf("%s is not supported by the fake server", term)
+		case strings.HasPrefix(term, "tag:"):
+			tag := strings.TrimPrefix(term, "tag:")
+			matchers = append(matchers, func(e retrieval.Event) bool {
+				for _, t := range e.Tags {
+					if t == tag {
+						return true
+					}
+				}
+				return false
+			})
+		case strings.HasPrefix(term, "json.") && strings.Contains(term, ":"):
+			i := strings.Index(term, ":")
+			path, value := strings.Split(term[len("json."):i], "."), strings.Trim(term[i+1:], `"`)
+			matchers = append(matchers, func(e retrieval.Event) bool {
+				fields, err := e.JSON()
+				if err != nil || fields == nil {
+					return false
+				}
+				var v interface{} = fields
+				for _, key := range path {
+					m, ok := v.(map[string]interface{})
+					if !ok {
+						return false
+					}
+					v = m[key]
+				}
+				return v != nil && fmt.Sprint(v) == value
+			})
+		default:
+			word := strings.ToLower(strings.Trim(term, `"`))
+			matchers = append(matchers, func(e retrieval.Event) bool {
+				return strings.Contains(strings.ToLower(e.LogMsg), word)
+			})
+		}
+	}
+
+	return func(e retrieval.Event) bool {
+		for _, match := range matchers {
+			if !match(e) {
+				return false
+			}
+		}
+		return true
+	}, nil
+}
+
+func writeJSON(w http.ResponseWriter, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(body)
+}
+
+func badRequest(w http.ResponseWriter, message string) {
+	b, _ := json.Marshal(map[string]string{"message": message})
+	http.Error(w, string(b), http.StatusBadRequest)
+}
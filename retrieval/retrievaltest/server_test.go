@@ -0,0 +1,113 @@
+package retrievaltest
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/moznion/logglily/retrieval"
+)
+
+func TestServerShouldSearchEvents(t *testing.T) {
+	now := time.Date(2018, 1, 5, 12, 0, 0, 0, time.UTC)
+	s := NewServer("api-token")
+	defer s.Close()
+	s.SetClock(func() time.Time { return now })
+	s.AddEvents(
+		NewEvent(now.Add(-3*time.Hour), `{"level":"error","message":"too old"}`, "web"),
+		NewEvent(now.Add(-30*time.Minute), `{"level":"error","message":"first"}`, "web"),
+		NewEvent(now.Add(-20*time.Minute), `{"level":"info","message":"info"}`, "web"),
+		NewEvent(now.Add(-10*time.Minute), `{"level":"error","message":"other tag"}`, "batch"),
+		NewEvent(now.Add(-5*time.Minute), `{"level":"error","message":"second"}`, "web"),
+		NewEvent(now.Add(-time.Minute), "plain Error line", "web"),
+	)
+
+	for _, tc := range []struct {
+		query    retrieval.Query
+		expected []string
+	}{
+		{
+			query:    retrieval.Query{Query: "tag:web AND json.level:error", From: "-1h", Order: retrieval.OrderAsc, Size: 1},
+			expected: []string{"event-2", "event-5"},
+		},
+		{
+			query:    retrieval.Query{Query: "error", From: "-1h", Until: retrieval.FormatTime(now.Add(-2 * time.Minute))},
+			expected: []string{"event-5", "event-4", "event-2"},
+		},
+		{
+			query:    retrieval.Query{},
+			expected: []string{"event-6", "event-5", "event-4", "event-3", "event-2", "event-1"},
+		},
+	} {
+		it := s.Client().Iterate(tc.query)
+		var ids []string
+		for it.Next() {
+			ids = append(ids, it.Event().ID)
+		}
+		if err := it.Err(); err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(ids, tc.expected) {
+			t.Errorf("%+v: got %q, expected %q", tc.query, ids, tc.expected)
+		}
+	}
+
+	if queries := s.Queries(); len(queries) != 3 || queries[0].Size != 1 {
+		t.Errorf("unexpected queries: %+v", queries)
+	}
+}
+
+func TestServerShouldRejectUnauthorizedAndUnsupportedQueries(t *testing.T) {
+	s := NewServer("api-token")
+	defer s.Close()
+
+	if _, err := retrieval.NewClientWithBaseURL(s.URL, "wrong").Search(retrieval.Query{}); err == nil {
+		t.Error("unauthorized search should fail")
+	}
+	_, err := s.Client().Search(retrieval.Query{Query: "a OR b"})
+	if apiErr, ok := err.(*retrieval.APIError); !ok || apiErr.StatusCode != 400 {
+		t.Errorf("unsupported query should be bad request, but got %v", err)
+	}
+}
+
+func TestServerShouldCountFieldsAndValues(t *testing.T) {
+	now := time.Now()
+	s := NewServer("api-token")
+	defer s.Close()
+	s.AddEvents(
+		NewEvent(now.Add(-3*time.Hour), `{"level":"debug"}`, "web"),
+		NewEvent(now.Add(-30*time.Minute), `{"level":"error","http":{"status":500}}`, "web"),
+		NewEvent(now.Add(-20*time.Minute), `{"level":"info"}`, "web"),
+		NewEvent(now.Add(-10*time.Minute), `{"level":"error"}`, "web"),
+		NewEvent(now.Add(-5*time.Minute), "plain", "batch"),
+	)
+	c := s.Client()
+
+	fields, err := c.Fields(retrieval.FieldsQuery{From: "-1h"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectedFields := []retrieval.Term{
+		{Term: "tag", Count: 4},
+		{Term: "json.level", Count: 3},
+		{Term: "logtype", Count: 3},
+		{Term: "json.http.status", Count: 1},
+	}
+	if fields.TotalEvents != 4 || !reflect.DeepEqual(fields.Fields, expectedFields) {
+		t.Errorf("unexpected fields: %+v", fields)
+	}
+
+	values, err := c.FieldValues("json.level", retrieval.FieldsQuery{Query: "tag:web", From: "-1h", FacetSize: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectedValues := &retrieval.FieldValues{
+		Field:            "json.level",
+		TotalEvents:      3,
+		UniqueFieldCount: 2,
+		Values:           []retrieval.Term{{Term: "error", Count: 2}},
+	}
+	if !reflect.DeepEqual(values, expectedValues) {
+		t.Errorf("got %+v, expected %+v", values, expectedValues)
+	}
+}
@@ -0,0 +1,11 @@
+// Package sloghandler provides the `log/slog` Handler that writes the records through the loggers of logglily.
+//
+// Example:
+//
+//	l, _ := logger.NewAsyncBulkLogger([]string{"tag"}, "token", true, 5*1024*1024, 1000)
+//	slog.SetDefault(slog.New(sloghandler.New(l, nil)))
+//	slog.Info("hello", "user", "john", slog.Group("req", "method", "GET", "path", "/"))
+//	// => {"level":"info","message":"hello","req":{"method":"GET","path":"/"},"timestamp":"...","user":"john"}
+//
+// This package requires Go 1.21 or later; with the older versions, this package is empty.
+package sloghandler
@@ -0,0 +1,227 @@
+//go:build go1.21
+// +build go1.21
+
+package sloghandler
+
+import (
+	"context"
+	"log/slog"
+	"math"
+	"runtime"
+	"strings"
+
+	"github.com/moznion/logglily/logger"
+)
+
+const (
+	defaultLevelKey   = "level"
+	defaultMessageKey = "message"
+	defaultTimeKey    = "timestamp"
+	defaultSourceKey  = "source"
+)
+
+// Options is the options of Handler.
+type Options struct {
+	// Level is the minimum level of the records to be handled. If this is nil, slog.LevelInfo is used.
+	Level slog.Leveler
+
+	// LevelKey is the name of the level field. If this is empty, "level" is used.
+	// The level is lowercase, e.g. "info", as well as the one of logger.LeveledLogger.
+	LevelKey string
+
+	// MessageKey is the name of the message field. If this is empty, "message" is used; that is the field that Loggly shows as the event message.
+	MessageKey string
+
+	// TimeKey is the name of the timestamp field. If this is empty, "timestamp" is used; that is the field that Loggly uses as the event time.
+	// If the record doesn't have the time, this field is omitted.
+	TimeKey string
+
+	// AddSource adds the source code position of the record into "source" field, e.g. {"file":"/path/to/main.go","function":"main.main","line":42}.
+	AddSource bool
+}
+
+// Handler is the `slog.Handler` that converts the records into logger.Message and sends that to the sink.
+// Please give any logger of logglily (typically AsyncBulkLogger) as the sink.
+//
+// The attributes are mapped into JSON as follows, so Loggly indexes those as the typed fields;
+//   - the groups are encoded as the nested objects, so Loggly indexes those as "group.key"
+//   - time.Duration is encoded as nanoseconds number like as logger.Duration() field
+//   - NaN and infinities are encoded as strings; "NaN", "+Inf" and "-Inf"
+//   - errors are encoded as the structured objects by the logger
+//   - slog.LogValuer is resolved
+//
+// The level field and the message field always overwrite the attributes of the same names.
+type Handler struct {
+	sink       logger.Sink
+	level      slog.Leveler
+	levelKey   string
+	messageKey string
+	timeKey    string
+	addSource  bool
+
+	// groups are the names of the opened groups; attrs[i] is the attributes that are added while i groups are opened
+	groups []string
+	attrs  [][]slog.Attr
+}
+
+// New creates a new Handler. If opts is nil, the default options are used.
+func New(sink logger.Sink, opts *Options) *Handler {
+	if opts == nil {
+		opts = &Options{}
+	}
+
+	h := &Handler{
+		sink:       sink,
+		level:      opts.Level,
+		levelKey:   opts.LevelKey,
+		messageKey: opts.MessageKey,
+		timeKey:    opts.TimeKey,
+		addSource:  opts.AddSource,
+		attrs:      make([][]slog.Attr, 1),
+	}
+	if h.level == nil {
+		h.level = slog.LevelInfo
+	}
+	if h.levelKey == "" {
+		h.levelKey = defaultLevelKey
+	}
+	if h.messageKey == "" {
+		h.messageKey = defaultMessageKey
+	}
+	if h.timeKey == "" {
+		h.timeKey = defaultTimeKey
+	}
+	return h
+}
+
+// Enabled reports whether the handler handles the records at the given level.
+func (h *Handler) Enabled(_ context.Context, level slog.Level) bool {
+	return level >= h.level.Level()
+}
+
+// Handle converts the record into logger.Message and sends that to the sink.
+// This returns the foreground error of the sink.
+func (h *Handler) Handle(_ context.Context, r slog.Record) error {
+	var inner map[string]interface{}
+	for i := len(h.groups); i >= 0; i-- {
+		m := make(map[string]interface{})
+		addAttrs(m, h.attrs[i])
+		if i == len(h.groups) {
+			r.Attrs(func(a slog.Attr) bool {
+				addAttr(m, a)
+				return true
+			})
+		} else if len(inner) > 0 {
+			m[h.groups[i]] = inner
+		}
+		inner = m
+	}
+
+	message := logger.Message(inner)
+	if !r.Time.IsZero() {
+		message[h.timeKey] = r.Time
+	}
+	if h.addSource && r.PC != 0 {
+		frame, _ := runtime.CallersFrames([]uintptr{r.PC}).Next()
+		message[defaultSourceKey] = map[string]interface{}{
+			"function": frame.Function,
+			"file":     frame.File,
+			"line":     frame.Line,
+		}
+	}
+	message[h.levelKey] = strings.ToLower(r.Level.String())
+	message[h.messageKey] = r.Message
+
+	return h.sink.Send(message)
+}
+
+// WithAttrs returns a new Handler that has the given attributes in addition.
+func (h *Handler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	if len(attrs) == 0 {
+		return h
+	}
+
+	h2 := h.clone()
+	last := len(h2.attrs) - 1
+	h2.attrs[last] = append(h2.attrs[last][:len(h2.attrs[last]):len(h2.attrs[last])], attrs...)
+	return h2
+}
+
+// WithGroup returns a new Handler that puts the following attributes into the group.
+func (h *Handler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+
+	h2 := h.clone()
+	h2.groups = append(h2.groups, name)
+	h2.attrs = append(h2.attrs, nil)
+	return h2
+}
+
+func (h *Handler) clone() *Handler {
+	h2 := *h
+	h2.groups = append([]string(nil), h.groups...)
+	h2.attrs = append([][]slog.Attr(nil), h.attrs...)
+	return &h2
+}
+
+func addAttrs(m map[string]interface{}, attrs []slog.Attr) {
+	for _, a := range attrs {
+		addAttr(m, a)
+	}
+}
+
+func addAttr(m map[string]interface{}, a slog.Attr) {
+	a.Value = a.Value.Resolve()
+	if a.Equal(slog.Attr{}) {
+		return
+	}
+
+	if a.Value.Kind() == slog.KindGroup {
+		attrs := a.Value.Group()
+		if len(attrs) == 0 {
+			return
+		}
+		if a.Key == "" {
+			// inline the group
+			addAttrs(m, attrs)
+			return
+		}
+		group := make(map[string]interface{}, len(attrs))
+		addAttrs(group, attrs)
+		m[a.Key] = group
+		return
+	}
+
+	m[a.Key] = attrValue(a.Value)
+}
+
+func attrValue(v slog.Value) interface{} {
+	switch v.Kind() {
+	case slog.KindString:
+		return v.String()
+	case slog.KindInt64:
+		return v.Int64()
+	case slog.KindUint64:
+		return v.Uint64()
+	case slog.KindFloat64:
+		f := v.Float64()
+		switch {
+		case math.IsNaN(f):
+			return "NaN"
+		case math.IsInf(f, 1):
+			return "+Inf"
+		case math.IsInf(f, -1):
+			return "-Inf"
+		}
+		return f
+	case slog.KindBool:
+		return v.Bool()
+	case slog.KindDuration:
+		return int64(v.Duration())
+	case slog.KindTime:
+		return v.Time()
+	}
+	return v.Any()
+}
@@ -0,0 +1,140 @@
+//go:build go1.21
+// +build go1.21
+
+package sloghandler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"math"
+	"strings"
+	"testing"
+	"testing/slogtest"
+	"time"
+
+	"github.com/moznion/logglily/internal/api"
+	"github.com/moznion/logglily/logger"
+)
+
+type recordingSink struct {
+	messages []logger.Message
+}
+
+func (s *recordingSink) Send(message logger.Message) error {
+	s.messages = append(s.messages, message)
+	return nil
+}
+
+func TestHandlerShouldSatisfySlogtest(t *testing.T) {
+	sink := &recordingSink{}
+	h := New(sink, &Options{
+		Level:      slog.LevelDebug,
+		MessageKey: slog.MessageKey,
+		TimeKey:    slog.TimeKey,
+	})
+
+	err := slogtest.TestHandler(h, func() []map[string]any {
+		results := make([]map[string]any, len(sink.messages))
+		for i, m := range sink.messages {
+			results[i] = map[string]any(m)
+		}
+		return results
+	})
+	if err != nil {
+		t.Error(err)
+	}
+}
+
+type userValuer struct {
+	name string
+}
+
+func (u userValuer) LogValue() slog.Value {
+	return slog.GroupValue(slog.String("name", u.name), slog.String("password", "****"))
+}
+
+func TestHandlerShouldMapAttributes(t *testing.T) {
+	sink := &recordingSink{}
+	l := slog.New(New(sink, nil)).With("service", "api").WithGroup("req")
+
+	l.Info("hello",
+		"method", "GET",
+		"latency", 1500*time.Millisecond,
+		"ratio", math.NaN(),
+		"user", userValuer{name: "john"},
+		"error", errors.New("boom"),
+	)
+
+	if len(sink.messages) != 1 {
+		t.Fatalf("len(messages) == %d", len(sink.messages))
+	}
+	m := sink.messages[0]
+	delete(m, "timestamp")
+
+	body, err := json.Marshal(m)
+	if err != nil {
+		t.Fatal("unexpected err", err)
+	}
+	expected := `{"level":"info","message":"hello","req":{"error":{},"latency":1500000000,"method":"GET","ratio":"NaN","user":{"name":"john","password":"****"}},"service":"api"}`
+	if string(body) != expected {
+		t.Errorf("got == `%s` but wants `%s`", body, expected)
+	}
+	if _, ok := m["req"].(map[string]interface{})["error"].(error); !ok {
+		t.Error("error should be kept as it is to be structured by the logger")
+	}
+}
+
+func TestHandlerShouldFilterByLevel(t *testing.T) {
+	sink := &recordingSink{}
+	level := &slog.LevelVar{}
+	level.Set(slog.LevelWarn)
+	l := slog.New(New(sink, &Options{Level: level, LevelKey: "severity"}))
+
+	l.Info("dropped")
+	l.Error("kept")
+	level.Set(slog.LevelDebug)
+	l.Debug("kept")
+
+	if len(sink.messages) != 2 {
+		t.Fatalf("len(messages) == %d", len(sink.messages))
+	}
+	if sink.messages[0]["severity"] != "error" || sink.messages[1]["severity"] != "debug" {
+		t.Errorf("messages == %v", sink.messages)
+	}
+}
+
+func TestHandlerShouldAddSource(t *testing.T) {
+	sink := &recordingSink{}
+	l := slog.New(New(sink, &Options{AddSource: true}))
+
+	l.InfoContext(context.Background(), "hello")
+
+	source := sink.messages[0]["source"].(map[string]interface{})
+	if !strings.HasSuffix(source["function"].(string), "TestHandlerShouldAddSource") {
+		t.Errorf("source == %v", source)
+	}
+	if !strings.HasSuffix(source["file"].(string), "handler_test.go") {
+		t.Errorf("source == %v", source)
+	}
+}
+
+func TestHandlerShouldNotBeInjectedAsCaller(t *testing.T) {
+	client := &api.RecordingClient{}
+	sink := logger.NewSyncLogger([]string{"test-tag"}, "test-token", true)
+	sink.APIClient = client
+	sink.SetCaller("caller")
+
+	slog.New(New(sink, nil)).Info("hello")
+
+	var decoded struct {
+		Caller logger.Frame `json:"caller"`
+	}
+	if err := json.Unmarshal([]byte(client.Events()[0]), &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasSuffix(decoded.Caller.Function, "TestHandlerShouldNotBeInjectedAsCaller") {
+		t.Errorf("caller == %+v", decoded.Caller)
+	}
+}
@@ -0,0 +1,86 @@
+package tailer
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+)
+
+const checkpointVersion = 1
+
+// checkpoint is the persisted read positions of the files, e.g.
+//
+//	{"version":1,"files":{"/var/log/app.log":{"offset":1024,"fingerprint":"5e88...","fingerprint_size":1024}}}
+//
+// The offset is the position up to which the lines have been delivered to loggly.
+type checkpoint struct {
+	Version int                        `json:"version"`
+	Files   map[string]checkpointEntry `json:"files"`
+}
+
+type checkpointEntry struct {
+	Offset          int64  `json:"offset"`
+	Fingerprint     string `json:"fingerprint"`
+	FingerprintSize int64  `json:"fingerprint_size"`
+}
+
+// loadCheckpoint reads the checkpoint file. If the file doesn't exist, this returns the empty checkpoint.
+func loadCheckpoint(path string) (*checkpoint, error) {
+	cp := &checkpoint{Version: checkpointVersion, Files: make(map[string]checkpointEntry)}
+	if path == "" {
+		return cp, nil
+	}
+
+	b, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return cp, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(b, cp); err != nil {
+		return nil, err
+	}
+	if cp.Files == nil {
+		cp.Files = make(map[string]checkpointEntry)
+	}
+	return cp, nil
+}
+
+// save writes the checkpoint file atomically; this writes into the temporary file and renames that.
+func (cp *checkpoint) save(path string) error {
+	if path == "" {
+		return nil
+	}
+
+	b, err := json.Marshal(cp)
+	if err != nil {
+		return err
+	}
+
+	tmp, err := ioutil.TempFile(filepath.Dir(path), filepath.Base(path)+".tmp")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(b); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+func (cp *checkpoint) equal(files map[string]checkpointEntry) bool {
+	return reflect.DeepEqual(cp.Files, files)
+}
@@ -0,0 +1,245 @@
+package tailer
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"os"
+
+	"github.com/moznion/logglily/api"
+	"github.com/moznion/logglily/logger"
+)
+
+// fingerprintSize is the size of the head of the file that identifies the file
+const fingerprintSize = 1024
+
+// follower follows a file. This reads the complete lines from the offset and sends them through its own bulk logger.
+//
+// The lines are buffered in the logger until that posts them, so `pending` holds the end offsets of the buffered lines in order.
+// When the logger posts the payload successfully, the offsets of the posted lines are confirmed.
+// When the logger fails, the logger is recreated and the reading rewinds to the confirmed offset;
+// so the lines are neither lost nor duplicated across the failures and the restarts.
+type follower struct {
+	tailer *Tailer
+	path   string
+	tags   []string
+	fields logger.Message
+
+	file            *os.File
+	info            os.FileInfo
+	offset          int64
+	confirmed       int64
+	fingerprint     string
+	fingerprintSize int64
+
+	logger  *logger.SyncBulkLogger
+	writer  *logger.Writer
+	buf     []byte
+	pending []int64
+	lineEnd int64
+	posted  int
+	sendErr error
+}
+
+// confirmingClient notifies the number of the lines of the posted payload.
+// That is tentative; the follower confirms them only if the logger reports the success.
+type confirmingClient struct {
+	api.Client
+	follower *follower
+}
+
+func (c *confirmingClient) LogAsBulk(body []byte) (*http.Response, error) {
+	resp, err := c.Client.LogAsBulk(body)
+	if err == nil {
+		c.follower.posted += bytes.Count(body, []byte{'\n'}) + 1
+	}
+	return resp, err
+}
+
+func (f *follower) open(offset int64, fingerprint string, fingerprintSize int64) error {
+	file, err := os.Open(f.path)
+	if err != nil {
+		return err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+
+	f.file = file
+	f.info = info
+	f.offset = offset
+	f.confirmed = offset
+	f.fingerprint = fingerprint
+	f.fingerprintSize = fingerprintSize
+	f.pending = nil
+	if fingerprint == "" {
+		if _, err := f.checkFingerprint(); err != nil {
+			file.Close()
+			return err
+		}
+	}
+	return f.resetLogger()
+}
+
+func (f *follower) close() {
+	if f.file != nil {
+		f.file.Close()
+		f.file = nil
+	}
+}
+
+// resetLogger recreates the logger; the lines that the previous one buffered are dropped.
+func (f *follower) resetLogger() error {
+	l, err := f.tailer.newLogger(f.tags)
+	if err != nil {
+		return err
+	}
+	l.APIClient = &confirmingClient{Client: l.APIClient, follower: f}
+
+	w := logger.NewWriter(logger.SinkFunc(f.send))
+	w.SetFields(f.fields)
+	w.SetMessageKey(f.tailer.messageKey)
+
+	f.logger = l
+	f.writer = w
+	f.pending = nil
+	return nil
+}
+
+func (f *follower) send(message logger.Message) error {
+	f.pending = append(f.pending, f.lineEnd)
+	f.posted = 0
+	if _, err := f.logger.Log(message); err != nil {
+		f.sendErr = err
+		return err
+	}
+	f.confirm(f.posted)
+	return nil
+}
+
+func (f *follower) confirm(lines int) {
+	if lines <= 0 {
+		return
+	}
+	if lines > len(f.pending) {
+		lines = len(f.pending)
+	}
+	f.confirmed = f.pending[lines-1]
+	f.pending = f.pending[lines:]
+}
+
+// rewind forgets the unconfirmed lines to read them again.
+func (f *follower) rewind() error {
+	f.offset = f.confirmed
+	return f.resetLogger()
+}
+
+// checkFingerprint verifies the head of the file. This returns false if the head is changed; i.e. the file is truncated and rewritten.
+// The fingerprint grows until fingerprintSize as the file grows.
+func (f *follower) checkFingerprint() (bool, error) {
+	head := make([]byte, fingerprintSize)
+	n, err := f.file.ReadAt(head, 0)
+	if err != nil && err != io.EOF {
+		return false, err
+	}
+	head = head[:n]
+
+	if f.fingerprint != "" {
+		if int64(len(head)) < f.fingerprintSize || hashHead(head[:f.fingerprintSize]) != f.fingerprint {
+			return false, nil
+		}
+	}
+	if f.fingerprint == "" || int64(len(head)) > f.fingerprintSize {
+		f.fingerprint = hashHead(head)
+		f.fingerprintSize = int64(len(head))
+	}
+	return true, nil
+}
+
+func hashHead(head []byte) string {
+	sum := sha256.Sum256(head)
+	return hex.EncodeToString(sum[:])
+}
+
+// readLines reads the complete lines from the offset and sends them, and flushes the logger.
+// The line that doesn't end with newline yet is left for the next time, unless that is too long.
+func (f *follower) readLines() error {
+	info, err := f.file.Stat()
+	if err != nil {
+		return err
+	}
+	if info.Size() == f.offset {
+		// nothing has been written; the logger has nothing to flush either, because each call flushes that
+		return nil
+	}
+
+	if f.buf == nil {
+		f.buf = make([]byte, f.tailer.maxReadBytes)
+	}
+	buf := f.buf
+	for {
+		n, err := f.file.ReadAt(buf, f.offset)
+		if err != nil && err != io.EOF {
+			return err
+		}
+		chunk := buf[:n]
+
+		consumed := 0
+		for consumed < len(chunk) {
+			i := bytes.IndexByte(chunk[consumed:], '\n')
+			if i < 0 {
+				if consumed == 0 && len(chunk) == len(buf) {
+					// the line is longer than the buffer; send that as it is
+					i = len(chunk)
+				} else {
+					break
+				}
+			}
+
+			line := chunk[consumed : consumed+i]
+			consumed += i
+			if consumed < len(chunk) {
+				consumed++ // newline
+			}
+
+			f.lineEnd = f.offset + int64(consumed)
+			f.sendErr = nil
+			f.writer.Write(append(line, '\n'))
+			if f.sendErr != nil {
+				return f.fail(f.sendErr)
+			}
+		}
+		f.offset += int64(consumed)
+
+		if consumed == 0 || n < len(buf) {
+			break
+		}
+	}
+
+	f.posted = 0
+	if _, err := f.logger.Flush(); err != nil {
+		return f.fail(err)
+	}
+	f.confirmed = f.offset
+	f.pending = nil
+	return nil
+}
+
+func (f *follower) fail(err error) error {
+	if rewindErr := f.rewind(); rewindErr != nil {
+		return rewindErr
+	}
+	return err
+}
+
+func (f *follower) entry() checkpointEntry {
+	return checkpointEntry{
+		Offset:          f.confirmed,
+		Fingerprint:     f.fingerprint,
+		FingerprintSize: f.fingerprintSize,
+	}
+}
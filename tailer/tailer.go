@@ -0,0 +1,335 @@
+// Package tailer follows the log files and ships the lines into loggly through the bulk loggers.
+//
+// This is for the processes that only write the log files. Tailer follows the files that match the glob patterns,
+// and it survives both of rename-style rotation (e.g. logrotate's default) and copytruncate-style rotation.
+// The read offsets are persisted into the checkpoint file after the lines are delivered,
+// so the restarts neither lose nor duplicate the lines.
+package tailer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/moznion/logglily/api"
+	"github.com/moznion/logglily/logger"
+)
+
+const (
+	defaultPollInterval = time.Second
+	defaultMaxReadBytes = 1024 * 1024
+	defaultBulkByteSize = 5 * 1024 * 1024
+)
+
+// FileConfig is the setting of the files to follow.
+type FileConfig struct {
+	// Pattern is the path or the glob pattern of the files, e.g. "/var/log/app/*.log".
+	// NOTE: The pattern should not match the rotated files (e.g. "app.log.1"), or they are shipped again as the new files.
+	Pattern string
+	// Tags are the tags for the matched files; they are added to Config.Tags
+	Tags []string
+	// Fields are the static fields that are added into each message
+	Fields logger.Message
+}
+
+// Config is the setting of Tailer.
+type Config struct {
+	Files    []FileConfig
+	Token    string
+	Tags     []string
+	Insecure bool
+
+	// CheckpointPath is the path of the checkpoint file. If this is empty, the offsets are not persisted.
+	CheckpointPath string
+	// PollInterval is the interval to check the files. The default is 1 second.
+	PollInterval time.Duration
+	// StartAtEnd skips the existing contents of the files that are not in the checkpoint at the start.
+	// The files that appear after that are always read from the beginning.
+	StartAtEnd bool
+	// MessageKey is the key to wrap the plain text lines under. The default is "message".
+	MessageKey string
+	// FileKey is the key of the path of the file in each message. The default is "file".
+	FileKey string
+	// MaxReadBytes is the size to read at once; the line that is longer than this is split. The default is 1MiB.
+	MaxReadBytes int
+
+	// OnError is called with the errors of the files; the failed files are retried at the next poll.
+	OnError func(err error)
+	// APIClient creates the API client for the tags if it is not nil. This is mainly for the tests.
+	APIClient func(tags []string) api.Client
+}
+
+// Tailer follows the files.
+//
+// NOTE: Each file has its own SyncBulkLogger because the tags are the part of the endpoint URL of loggly.
+// The periodic flushing of the loggers is disabled; Tailer flushes them at each poll to confirm the delivery.
+type Tailer struct {
+	config       Config
+	pollInterval time.Duration
+	messageKey   string
+	fileKey      string
+	maxReadBytes int
+
+	checkpoint *checkpoint
+	followers  map[string]*follower
+	started    bool
+}
+
+// New creates a new Tailer. This loads the checkpoint file if that exists.
+func New(config Config) (*Tailer, error) {
+	if len(config.Files) == 0 {
+		return nil, errors.New("no file is given")
+	}
+	if config.Token == "" && config.APIClient == nil {
+		return nil, errors.New("token is required")
+	}
+
+	cp, err := loadCheckpoint(config.CheckpointPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load the checkpoint: %s", err)
+	}
+
+	t := &Tailer{
+		config:       config,
+		pollInterval: config.PollInterval,
+		messageKey:   config.MessageKey,
+		fileKey:      config.FileKey,
+		maxReadBytes: config.MaxReadBytes,
+		checkpoint:   cp,
+		followers:    make(map[string]*follower),
+	}
+	if t.pollInterval <= 0 {
+		t.pollInterval = defaultPollInterval
+	}
+	if t.messageKey == "" {
+		t.messageKey = "message"
+	}
+	if t.fileKey == "" {
+		t.fileKey = "file"
+	}
+	if t.maxReadBytes <= 0 {
+		t.maxReadBytes = defaultMaxReadBytes
+	}
+	return t, nil
+}
+
+// Run polls the files until the context is done. This polls once more before returning,
+// so the lines that were written before the cancellation are shipped.
+func (t *Tailer) Run(ctx context.Context) error {
+	defer t.Close()
+
+	ticker := time.NewTicker(t.pollInterval)
+	defer ticker.Stop()
+	for {
+		if err := t.Poll(); err != nil {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return t.Poll()
+		case <-ticker.C:
+		}
+	}
+}
+
+// Poll checks the files once; this finds the new files, handles the rotations, ships the new lines and saves the checkpoint.
+// The errors of the files are notified to OnError, and only the error of the checkpoint is returned.
+func (t *Tailer) Poll() error {
+	paths := t.discover()
+	for _, path := range paths {
+		if _, ok := t.followers[path]; ok {
+			continue
+		}
+		f, err := t.follow(path)
+		if err != nil {
+			t.onError(fmt.Errorf("%s: %s", path, err))
+			continue
+		}
+		t.followers[path] = f
+	}
+	t.started = true
+
+	for path, f := range t.followers {
+		removed, err := t.poll(f)
+		if err != nil {
+			t.onError(fmt.Errorf("%s: %s", path, err))
+		}
+		if removed {
+			f.close()
+			delete(t.followers, path)
+		}
+	}
+
+	return t.saveCheckpoint(paths)
+}
+
+// Close closes the files.
+func (t *Tailer) Close() {
+	for _, f := range t.followers {
+		f.close()
+	}
+}
+
+// discover returns the files that match the patterns.
+func (t *Tailer) discover() []string {
+	var paths []string
+	seen := make(map[string]bool)
+	for _, fc := range t.config.Files {
+		matches, err := filepath.Glob(fc.Pattern)
+		if err != nil {
+			t.onError(fmt.Errorf("%s: %s", fc.Pattern, err))
+			continue
+		}
+		for _, path := range matches {
+			if seen[path] {
+				continue
+			}
+			if info, err := os.Stat(path); err != nil || !info.Mode().IsRegular() {
+				continue
+			}
+			seen[path] = true
+			paths = append(paths, path)
+		}
+	}
+	return paths
+}
+
+// fileConfig returns the setting of the first pattern that matches the path.
+func (t *Tailer) fileConfig(path string) FileConfig {
+	for _, fc := range t.config.Files {
+		if ok, _ := filepath.Match(fc.Pattern, path); ok {
+			return fc
+		}
+	}
+	return FileConfig{}
+}
+
+func (t *Tailer) follow(path string) (*follower, error) {
+	fc := t.fileConfig(path)
+
+	fields := make(logger.Message, len(fc.Fields)+1)
+	for k, v := range fc.Fields {
+		fields[k] = v
+	}
+	fields[t.fileKey] = path
+
+	f := &follower{
+		tailer: t,
+		path:   path,
+		tags:   append(append([]string{}, t.config.Tags...), fc.Tags...),
+		fields: fields,
+	}
+
+	entry, ok := t.checkpoint.Files[path]
+	if !ok {
+		if err := f.open(0, "", 0); err != nil {
+			return nil, err
+		}
+		if !t.started && t.config.StartAtEnd {
+			f.offset = f.info.Size()
+			f.confirmed = f.offset
+		}
+		return f, nil
+	}
+
+	if err := f.open(entry.Offset, entry.Fingerprint, entry.FingerprintSize); err != nil {
+		return nil, err
+	}
+	if ok, err := f.checkFingerprint(); err != nil {
+		f.close()
+		return nil, err
+	} else if !ok || f.info.Size() < entry.Offset {
+		// the file has been replaced while stopping
+		f.close()
+		if err := f.open(0, "", 0); err != nil {
+			return nil, err
+		}
+	}
+	return f, nil
+}
+
+// poll handles the rotation of the file and ships the new lines. This returns true if the file has gone.
+func (t *Tailer) poll(f *follower) (bool, error) {
+	info, statErr := os.Stat(f.path)
+	if statErr != nil || !os.SameFile(f.info, info) {
+		// renamed or removed; ship the rest of the old file, and then follow the new one
+		if err := f.readLines(); err != nil {
+			return false, err
+		}
+		if statErr != nil {
+			return true, nil
+		}
+
+		f.close()
+		if err := f.open(0, "", 0); err != nil {
+			return true, err
+		}
+		return false, f.readLines()
+	}
+
+	current, err := f.file.Stat()
+	if err != nil {
+		return false, err
+	}
+	ok, err := f.checkFingerprint()
+	if err != nil {
+		return false, err
+	}
+	if current.Size() < f.offset || !ok {
+		// truncated (copytruncate)
+		f.offset = 0
+		f.confirmed = 0
+		f.fingerprint = ""
+		f.fingerprintSize = 0
+		if _, err := f.checkFingerprint(); err != nil {
+			return false, err
+		}
+		if err := f.resetLogger(); err != nil {
+			return false, err
+		}
+	}
+	return false, f.readLines()
+}
+
+func (t *Tailer) newLogger(tags []string) (*logger.SyncBulkLogger, error) {
+	l, err := logger.NewSyncBulkLogger(tags, t.config.Token, !t.config.Insecure, defaultBulkByteSize, 0)
+	if err != nil {
+		return nil, err
+	}
+	if t.config.APIClient != nil {
+		l.APIClient = t.config.APIClient(tags)
+	}
+	return l, nil
+}
+
+// saveCheckpoint saves the offsets of the followed files.
+// The entries of the discovered files that are not followed (i.e. failed to open for now) are kept, to resume them later.
+func (t *Tailer) saveCheckpoint(discovered []string) error {
+	files := make(map[string]checkpointEntry, len(discovered))
+	for _, path := range discovered {
+		if entry, ok := t.checkpoint.Files[path]; ok {
+			files[path] = entry
+		}
+	}
+	for path, f := range t.followers {
+		files[path] = f.entry()
+	}
+	if t.checkpoint.equal(files) {
+		return nil
+	}
+
+	t.checkpoint.Files = files
+	if err := t.checkpoint.save(t.config.CheckpointPath); err != nil {
+		return fmt.Errorf("failed to save the checkpoint: %s", err)
+	}
+	return nil
+}
+
+func (t *Tailer) onError(err error) {
+	if t.config.OnError != nil {
+		t.config.OnError(err)
+	}
+}
@@ -0,0 +1,359 @@
+package tailer
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/moznion/logglily/api"
+	internalAPI "github.com/moznion/logglily/internal/api"
+)
+
+type recorder struct {
+	client internalAPI.RecordingClient
+	taken  int
+	errors []error
+}
+
+func (r *recorder) config(dir string, files ...FileConfig) Config {
+	return Config{
+		Files:          files,
+		Tags:           []string{"base"},
+		CheckpointPath: filepath.Join(dir, "checkpoint.json"),
+		OnError:        func(err error) { r.errors = append(r.errors, err) },
+		APIClient: func(tags []string) api.Client {
+			return r.client.Labeled(strings.Join(tags, ","))
+		},
+	}
+}
+
+func (r *recorder) take() []string {
+	lines := r.client.Lines()[r.taken:]
+	r.taken += len(lines)
+	return lines
+}
+
+func writeFile(t *testing.T, path string, content string) {
+	if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func appendFile(t *testing.T, path string, content string) {
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY|os.O_CREATE, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if _, err := f.WriteString(content); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func poll(t *testing.T, tl *Tailer) {
+	if err := tl.Poll(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func assertLines(t *testing.T, r *recorder, expected ...string) {
+	got := r.take()
+	if len(got) == 0 && len(expected) == 0 {
+		return
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("lines == %q but wants %q", got, expected)
+	}
+}
+
+func TestTailerShouldShipCompleteLinesWithTagsAndFields(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "tailer")
+	defer os.RemoveAll(dir)
+
+	app := filepath.Join(dir, "app.log")
+	web := filepath.Join(dir, "web.log")
+	writeFile(t, app, "plain\n{\"message\":\"json\"}\npart")
+	writeFile(t, web, "request\n")
+
+	r := &recorder{}
+	tl, err := New(r.config(dir,
+		FileConfig{Pattern: app, Tags: []string{"app"}},
+		FileConfig{Pattern: filepath.Join(dir, "w*.log"), Tags: []string{"web"}, Fields: map[string]interface{}{"role": "front"}},
+	))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tl.Close()
+
+	poll(t, tl)
+	got := r.take()
+	expected := []string{
+		`base,app {"file":"` + app + `","message":"plain"}`,
+		`base,app {"file":"` + app + `","message":"json"}`,
+		`base,web {"file":"` + web + `","message":"request","role":"front"}`,
+	}
+	if len(got) != len(expected) {
+		t.Fatalf("lines == %q but wants %q", got, expected)
+	}
+	for _, line := range expected {
+		found := false
+		for _, g := range got {
+			found = found || g == line
+		}
+		if !found {
+			t.Errorf("%q is not shipped: %q", line, got)
+		}
+	}
+
+	appendFile(t, app, "ial\n")
+	poll(t, tl)
+	assertLines(t, r, `base,app {"file":"`+app+`","message":"partial"}`)
+
+	poll(t, tl)
+	assertLines(t, r)
+}
+
+func TestTailerShouldFollowRenamedFile(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "tailer")
+	defer os.RemoveAll(dir)
+
+	app := filepath.Join(dir, "app.log")
+	writeFile(t, app, "1\n")
+
+	r := &recorder{}
+	tl, err := New(r.config(dir, FileConfig{Pattern: app}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tl.Close()
+	poll(t, tl)
+	r.take()
+
+	if err := os.Rename(app, app+".1"); err != nil {
+		t.Fatal(err)
+	}
+	appendFile(t, app+".1", "2\n") // written before the process reopens the file
+	writeFile(t, app, "3\n")
+
+	poll(t, tl)
+	assertLines(t, r, `base {"file":"`+app+`","message":"2"}`, `base {"file":"`+app+`","message":"3"}`)
+
+	appendFile(t, app, "4\n")
+	poll(t, tl)
+	assertLines(t, r, `base {"file":"`+app+`","message":"4"}`)
+}
+
+func TestTailerShouldFollowRemovedAndRecreatedFile(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "tailer")
+	defer os.RemoveAll(dir)
+
+	app := filepath.Join(dir, "app.log")
+	writeFile(t, app, "1\n")
+
+	r := &recorder{}
+	tl, err := New(r.config(dir, FileConfig{Pattern: app}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tl.Close()
+	poll(t, tl)
+	r.take()
+
+	if err := os.Rename(app, app+".1"); err != nil {
+		t.Fatal(err)
+	}
+	poll(t, tl)
+	assertLines(t, r)
+
+	writeFile(t, app, "2\n")
+	poll(t, tl)
+	assertLines(t, r, `base {"file":"`+app+`","message":"2"}`)
+}
+
+func TestTailerShouldFollowTruncatedFile(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "tailer")
+	defer os.RemoveAll(dir)
+
+	app := filepath.Join(dir, "app.log")
+	writeFile(t, app, "first line\n")
+
+	r := &recorder{}
+	tl, err := New(r.config(dir, FileConfig{Pattern: app}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tl.Close()
+	poll(t, tl)
+	r.take()
+
+	// copytruncate; shorter than before
+	if err := os.Truncate(app, 0); err != nil {
+		t.Fatal(err)
+	}
+	appendFile(t, app, "2\n")
+	poll(t, tl)
+	assertLines(t, r, `base {"file":"`+app+`","message":"2"}`)
+
+	// copytruncate; longer than before until the next poll
+	if err := os.Truncate(app, 0); err != nil {
+		t.Fatal(err)
+	}
+	appendFile(t, app, "3\nlonger than before\n")
+	poll(t, tl)
+	assertLines(t, r, `base {"file":"`+app+`","message":"3"}`, `base {"file":"`+app+`","message":"longer than before"}`)
+}
+
+func TestTailerShouldResumeFromCheckpoint(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "tailer")
+	defer os.RemoveAll(dir)
+
+	app := filepath.Join(dir, "app.log")
+	writeFile(t, app, "1\n")
+
+	r := &recorder{}
+	tl, err := New(r.config(dir, FileConfig{Pattern: app}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	poll(t, tl)
+	tl.Close()
+	assertLines(t, r, `base {"file":"`+app+`","message":"1"}`)
+
+	appendFile(t, app, "2\n")
+	tl, err = New(r.config(dir, FileConfig{Pattern: app}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	poll(t, tl)
+	tl.Close()
+	assertLines(t, r, `base {"file":"`+app+`","message":"2"}`)
+
+	// replaced by the other file that is longer than the offset while stopping
+	writeFile(t, app, "another file\n")
+	tl, err = New(r.config(dir, FileConfig{Pattern: app}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	poll(t, tl)
+	tl.Close()
+	assertLines(t, r, `base {"file":"`+app+`","message":"another file"}`)
+}
+
+func TestTailerShouldKeepCheckpointOfFileThatIsNotFollowed(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "tailer")
+	defer os.RemoveAll(dir)
+
+	app := filepath.Join(dir, "app.log")
+	worker := filepath.Join(dir, "worker.log")
+	writeFile(t, app, "1\n")
+	writeFile(t, worker, "2\n")
+
+	r := &recorder{}
+	tl, err := New(r.config(dir, FileConfig{Pattern: filepath.Join(dir, "*.log")}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tl.Close()
+	poll(t, tl)
+	expected := tl.checkpoint.Files[worker]
+
+	// following the file has failed for now, e.g. it is not permitted to open that temporarily
+	tl.followers[worker].close()
+	delete(tl.followers, worker)
+	if err := tl.saveCheckpoint(tl.discover()); err != nil {
+		t.Fatal(err)
+	}
+	if got, ok := tl.checkpoint.Files[worker]; !ok || got != expected {
+		t.Errorf("checkpoint of %s == %+v but wants %+v", worker, got, expected)
+	}
+
+	// the entry of the file that has gone is dropped
+	os.Remove(worker)
+	if err := tl.saveCheckpoint(tl.discover()); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := tl.checkpoint.Files[worker]; ok {
+		t.Errorf("checkpoint of %s should be dropped", worker)
+	}
+}
+
+func TestTailerShouldStartAtEnd(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "tailer")
+	defer os.RemoveAll(dir)
+
+	app := filepath.Join(dir, "app.log")
+	writeFile(t, app, "old\n")
+
+	r := &recorder{}
+	config := r.config(dir, FileConfig{Pattern: filepath.Join(dir, "*.log")})
+	config.StartAtEnd = true
+	tl, err := New(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tl.Close()
+
+	poll(t, tl)
+	assertLines(t, r)
+
+	appendFile(t, app, "new\n")
+	other := filepath.Join(dir, "other.log")
+	writeFile(t, other, "created\n")
+	poll(t, tl)
+	got := r.take()
+	if len(got) != 2 {
+		t.Errorf("lines == %q", got)
+	}
+}
+
+func TestTailerShouldRetryFailedLinesWithoutAdvancingCheckpoint(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "tailer")
+	defer os.RemoveAll(dir)
+
+	app := filepath.Join(dir, "app.log")
+	writeFile(t, app, "1\n2\n")
+
+	r := &recorder{}
+	r.client.Err = errors.New("network is down")
+	tl, err := New(r.config(dir, FileConfig{Pattern: app}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	poll(t, tl)
+	tl.Close()
+	if len(r.errors) != 1 || !strings.Contains(r.errors[0].Error(), "network is down") {
+		t.Errorf("errors == %v", r.errors)
+	}
+	assertLines(t, r)
+
+	// restarts after the failure
+	r.client.Err = nil
+	tl, err = New(r.config(dir, FileConfig{Pattern: app}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tl.Close()
+	poll(t, tl)
+	assertLines(t, r, `base {"file":"`+app+`","message":"1"}`, `base {"file":"`+app+`","message":"2"}`)
+}
+
+func TestFollowerShouldConfirmOnlyPostedLines(t *testing.T) {
+	f := &follower{pending: []int64{2, 4, 6}}
+	f.confirm(2)
+	if f.confirmed != 4 || !reflect.DeepEqual(f.pending, []int64{6}) {
+		t.Errorf("confirmed == %d, pending == %v", f.confirmed, f.pending)
+	}
+}
+
+func TestNewShouldValidateConfig(t *testing.T) {
+	if _, err := New(Config{Token: "token"}); err == nil {
+		t.Error("no file should be an error")
+	}
+	if _, err := New(Config{Files: []FileConfig{{Pattern: "*.log"}}}); err == nil {
+		t.Error("no token should be an error")
+	}
+}